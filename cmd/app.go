@@ -23,6 +23,10 @@ func StartServer(port string) error {
 	handlefunc.AggregationHandler(mux, newdb)
 	handlefunc.InvHandler(mux, newdb)
 	handlefunc.MenuHandler(mux, newdb)
+	handlefunc.ModifierHandler(mux, newdb)
+	handlefunc.CategoryHandler(mux, newdb)
+	handlefunc.SupplierHandler(mux, newdb)
+	handlefunc.StocktakeHandler(mux, newdb)
 
 	// Set up server port and log the server start
 	port = fmt.Sprintf(":%s", port)
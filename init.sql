@@ -1,45 +1,225 @@
 
 CREATE TYPE order_status AS ENUM ('open', 'close', 'rejected');
 
+CREATE TYPE station_type AS ENUM ('bar', 'kitchen');
+
+CREATE TYPE purchase_order_status AS ENUM ('draft', 'sent', 'partially_received', 'received');
+
+CREATE TYPE stocktake_status AS ENUM ('open', 'finalized');
+
+--Справочник единиц измерения: единицы одной размерности переводятся друг в друга через коэффициент к базовой единице.
+CREATE TABLE units (
+    unit VARCHAR(20) PRIMARY KEY,
+    dimension VARCHAR(20) NOT NULL,
+    factor FLOAT NOT NULL CHECK (factor > 0)
+);
+
+INSERT INTO units (unit, dimension, factor) VALUES
+('g', 'mass', 1),
+('kg', 'mass', 1000),
+('ml', 'volume', 1),
+('l', 'volume', 1000),
+('liters', 'volume', 1000),
+('pieces', 'count', 1);
+
 CREATE TABLE inventory (
     ingredient_id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
     quantity FLOAT NOT NULL,
-    price INTEGER ,
-    unit VARCHAR(20) NOT NULL
+    --Цена за единицу измерения; четыре знака, чтобы цена за грамм или миллилитр не округлялась до нуля.
+    price DECIMAL(10, 4) NOT NULL DEFAULT 0 CHECK (price >= 0),
+    unit VARCHAR(20) NOT NULL REFERENCES units(unit),
+    allergens TEXT[] NOT NULL DEFAULT '{}',
+    --Пищевая ценность на единицу измерения ингредиента: ккал, сахар и жир в граммах, кофеин в мг.
+    kcal FLOAT NOT NULL DEFAULT 0 CHECK (kcal >= 0),
+    sugar FLOAT NOT NULL DEFAULT 0 CHECK (sugar >= 0),
+    fat FLOAT NOT NULL DEFAULT 0 CHECK (fat >= 0),
+    caffeine FLOAT NOT NULL DEFAULT 0 CHECK (caffeine >= 0),
+    --Точка заказа: остаток, при котором ингредиент пора заказывать; норма запаса - до какого остатка дозаказывать.
+    reorder_point FLOAT CHECK (reorder_point >= 0),
+    par_level FLOAT CHECK (par_level >= 0),
+    --Порядок списания партий: fifo - сначала самые ранние поступления, fefo - сначала с ближайшим сроком годности.
+    consumption VARCHAR(4) NOT NULL DEFAULT 'fifo' CHECK (consumption IN ('fifo', 'fefo')),
+    archived_at TIMESTAMP,
+    CHECK (par_level IS NULL OR reorder_point IS NULL OR par_level >= reorder_point)
 );
 
+CREATE TABLE categories (
+    category_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    parent_id INT REFERENCES categories(category_id),
+    sort_order INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CHECK (parent_id IS NULL OR parent_id <> category_id)
+);
+
+CREATE UNIQUE INDEX idx_categories_name ON categories (LOWER(name));
+
+--Позиция меню с archived_at скрыта из меню и не принимается в новых заказах, но остаётся в истории заказов.
 CREATE TABLE menu_items (
     product_id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
     description TEXT,
     price DECIMAL(10, 2) NOT NULL,
-    category VARCHAR(50),
-    allergens TEXT[]
+    category_id INT REFERENCES categories(category_id),
+    prep_time INT NOT NULL DEFAULT 60,
+    station station_type NOT NULL DEFAULT 'bar',
+    archived_at TIMESTAMP
+);
+
+--Окна доступности позиции меню или категории: дни недели (ISO, 1 = понедельник), время и даты.
+--Пустое поле не ограничивает; время начала позже времени конца означает окно через полночь.
+CREATE TABLE availability_windows (
+    window_id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    category_id INT REFERENCES categories(category_id) ON DELETE CASCADE,
+    days_of_week INT[],
+    start_time TIME,
+    end_time TIME,
+    start_date DATE,
+    end_date DATE,
+    CHECK ((product_id IS NULL) <> (category_id IS NULL)),
+    CHECK ((start_time IS NULL) = (end_time IS NULL)),
+    CHECK (days_of_week <@ ARRAY[1, 2, 3, 4, 5, 6, 7]),
+    CHECK (start_date IS NULL OR end_date IS NULL OR start_date <= end_date)
 );
 
+CREATE TYPE menu_version_status AS ENUM ('draft', 'published', 'retired');
+
+--Версии меню: черновик хранит снимок позиций (items) и редактируется отдельно от живого меню,
+--публикация переносит снимок в menu_items. Опубликованная версия одна; прежние помечаются retired
+--и могут быть опубликованы снова для отката. publish_at откладывает публикацию черновика.
+CREATE TABLE menu_versions (
+    version_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    status menu_version_status NOT NULL DEFAULT 'draft',
+    based_on INT REFERENCES menu_versions(version_id) ON DELETE SET NULL,
+    items JSONB NOT NULL DEFAULT '[]',
+    publish_at TIMESTAMP,
+    published_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_menu_versions_published ON menu_versions (status) WHERE status = 'published';
+
+--Текущая опубликованная версия меню, NULL пока версий нет.
+CREATE OR REPLACE FUNCTION current_menu_version()
+RETURNS INT AS $$
+    SELECT version_id FROM menu_versions WHERE status = 'published';
+$$ LANGUAGE sql STABLE;
+
+--menu_version_id: версия меню, по которой заказ был оценён.
 CREATE TABLE orders (
     order_id SERIAL PRIMARY KEY,
     customer_name VARCHAR(100) NOT NULL,
     status order_status NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    estimated_ready_at TIMESTAMP,
+    menu_version_id INT DEFAULT current_menu_version() REFERENCES menu_versions(version_id),
+    --Срок резерва ингредиентов открытого заказа; NULL - ингредиенты списаны при открытии заказа.
+    reserved_until TIMESTAMP
+);
+
+CREATE TABLE menu_item_variants (
+    variant_id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL CHECK (price > 0),
+    recipe_multiplier FLOAT NOT NULL DEFAULT 1 CHECK (recipe_multiplier > 0),
+    --Время снятия варианта с меню; вариант остаётся для истории заказов и версий меню.
+    archived_at TIMESTAMP,
+    UNIQUE (product_id, name)
 );
 
 CREATE TABLE order_items (
     order_item_id SERIAL PRIMARY KEY,
     order_id INT REFERENCES orders(order_id) ON DELETE CASCADE,
-    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    product_id INT REFERENCES menu_items(product_id),
+    variant_id INT REFERENCES menu_item_variants(variant_id),
     quantity INT NOT NULL,
     item_details JSONB
 );
 
+--Количество в рецептах задаётся в своей единице (unit); stock_quantity - то же количество в единице склада,
+--его заполняет триггер recipe_stock_quantity_trigger, и именно оно списывается со склада.
 CREATE TABLE menu_item_ingredients (
     product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
-    ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    ingredient_id INT REFERENCES inventory(ingredient_id),
     quantity FLOAT NOT NULL,
+    unit VARCHAR(20) NOT NULL REFERENCES units(unit),
+    stock_quantity FLOAT NOT NULL,
     PRIMARY KEY (product_id, ingredient_id)
 );
 
+CREATE TABLE menu_item_variant_ingredients (
+    variant_id INT REFERENCES menu_item_variants(variant_id) ON DELETE CASCADE,
+    ingredient_id INT REFERENCES inventory(ingredient_id),
+    quantity FLOAT NOT NULL,
+    unit VARCHAR(20) NOT NULL REFERENCES units(unit),
+    stock_quantity FLOAT NOT NULL,
+    PRIMARY KEY (variant_id, ingredient_id)
+);
+
+CREATE TABLE modifier_groups (
+    group_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    min_select INT NOT NULL DEFAULT 0 CHECK (min_select >= 0),
+    max_select INT NOT NULL DEFAULT 1 CHECK (max_select >= min_select)
+);
+
+CREATE TABLE modifier_options (
+    option_id SERIAL PRIMARY KEY,
+    group_id INT REFERENCES modifier_groups(group_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    UNIQUE (group_id, name)
+);
+
+CREATE TABLE modifier_option_ingredients (
+    option_id INT REFERENCES modifier_options(option_id) ON DELETE CASCADE,
+    ingredient_id INT REFERENCES inventory(ingredient_id),
+    quantity FLOAT NOT NULL,
+    unit VARCHAR(20) NOT NULL REFERENCES units(unit),
+    stock_quantity FLOAT NOT NULL,
+    PRIMARY KEY (option_id, ingredient_id)
+);
+
+CREATE TABLE menu_item_modifier_groups (
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    group_id INT REFERENCES modifier_groups(group_id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, group_id)
+);
+
+CREATE TABLE order_item_modifiers (
+    order_item_id INT REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    option_id INT REFERENCES modifier_options(option_id),
+    PRIMARY KEY (order_item_id, option_id)
+);
+
+--Комбо-наборы: позиция меню с слотами, каждый слот заполняется одной из позиций-вариантов на выбор.
+CREATE TABLE bundle_slots (
+    slot_id SERIAL PRIMARY KEY,
+    bundle_id INT NOT NULL REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    UNIQUE (bundle_id, name)
+);
+
+CREATE TABLE bundle_slot_options (
+    slot_id INT NOT NULL REFERENCES bundle_slots(slot_id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES menu_items(product_id),
+    PRIMARY KEY (slot_id, product_id)
+);
+
+--Компоненты, выбранные в строке заказа комбо-набора; слот может быть удалён позже, компонент остаётся.
+CREATE TABLE order_item_components (
+    order_item_component_id SERIAL PRIMARY KEY,
+    order_item_id INT NOT NULL REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    slot_id INT REFERENCES bundle_slots(slot_id) ON DELETE SET NULL,
+    product_id INT NOT NULL REFERENCES menu_items(product_id),
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
+
 CREATE TABLE price_history (
     history_id SERIAL PRIMARY KEY,
     product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
@@ -48,6 +228,94 @@ CREATE TABLE price_history (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE scheduled_price_changes (
+    schedule_id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    new_price DECIMAL(10, 2) NOT NULL CHECK (new_price > 0),
+    effective_at TIMESTAMP NOT NULL,
+    applied_at TIMESTAMP,
+    last_error TEXT,
+    --Число неудачных попыток применить изменение; после последней попытки заполняется failed_at.
+    attempts INT NOT NULL DEFAULT 0,
+    failed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_scheduled_price_changes_due ON scheduled_price_changes(effective_at) WHERE applied_at IS NULL AND failed_at IS NULL;
+
+CREATE TABLE margin_alerts (
+    alert_id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    old_price DECIMAL(10, 2) NOT NULL,
+    new_price DECIMAL(10, 2) NOT NULL,
+    cost DECIMAL(10, 2) NOT NULL,
+    margin_percent FLOAT NOT NULL,
+    threshold FLOAT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+--Поставщики и ингредиенты, которые они поставляют, с ценой за единицу склада.
+CREATE TABLE suppliers (
+    supplier_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    contact_name VARCHAR(100) NOT NULL DEFAULT '',
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    lead_time_days INT NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_suppliers_name ON suppliers (LOWER(name));
+
+CREATE TABLE supplier_ingredients (
+    supplier_id INT REFERENCES suppliers(supplier_id) ON DELETE CASCADE,
+    ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    unit_cost DECIMAL(10, 4) NOT NULL CHECK (unit_cost >= 0),
+    PRIMARY KEY (supplier_id, ingredient_id)
+);
+
+--Заказы поставщикам: черновик -> отправлен -> частично получен -> получен. Количество в единице склада ингредиента.
+CREATE TABLE purchase_orders (
+    purchase_order_id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(supplier_id),
+    status purchase_order_status NOT NULL DEFAULT 'draft',
+    expected_at DATE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    received_at TIMESTAMP
+);
+
+CREATE TABLE purchase_order_lines (
+    line_id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(purchase_order_id) ON DELETE CASCADE,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id),
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10, 4) NOT NULL CHECK (unit_cost >= 0),
+    received_quantity FLOAT NOT NULL DEFAULT 0 CHECK (received_quantity >= 0 AND received_quantity <= quantity),
+    UNIQUE (purchase_order_id, ingredient_id)
+);
+
+--Каждая приёмка строки заказа поставщику: сколько пришло и по какой цене.
+CREATE TABLE purchase_order_receipts (
+    receipt_id SERIAL PRIMARY KEY,
+    line_id INT NOT NULL REFERENCES purchase_order_lines(line_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10, 4) NOT NULL,
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+--События нехватки: остаток ингредиента опустился до точки заказа или ниже.
+CREATE TABLE stock_alerts (
+    alert_id SERIAL PRIMARY KEY,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL,
+    reorder_point FLOAT NOT NULL,
+    par_level FLOAT,
+    reason VARCHAR(50),
+    order_id INT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE order_status_history (
     history_id SERIAL PRIMARY KEY,
     order_id INT REFERENCES orders(order_id) ON DELETE CASCADE,
@@ -55,13 +323,387 @@ CREATE TABLE order_status_history (
     changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE stations (
+    station station_type PRIMARY KEY,
+    active_staff INT NOT NULL DEFAULT 1 CHECK (active_staff >= 0)
+);
+
+INSERT INTO stations (station, active_staff) VALUES ('bar', 1), ('kitchen', 1);
+
+--Журнал движения склада: каждое изменение inventory.quantity записывается триггером со знаковой дельтой,
+--причиной, заказом (без внешнего ключа, чтобы история пережила удаление заказа) и остатком после движения.
 CREATE TABLE inventory_transactions (
     transaction_id SERIAL PRIMARY KEY,
     ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
     quantity_change FLOAT NOT NULL,
     reason TEXT,
+    order_id INT,
+    quantity_after FLOAT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_inventory_transactions_ingredient ON inventory_transactions (ingredient_id, created_at);
+
+--Партии ингредиента: поступление, срок годности и себестоимость единицы. Сумма остатков партий
+--совпадает с inventory.quantity, её поддерживает триггер inventory_lot_trigger.
+CREATE TABLE inventory_lots (
+    lot_id SERIAL PRIMARY KEY,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL CHECK (quantity >= 0),
+    remaining FLOAT NOT NULL CHECK (remaining >= 0),
+    unit_cost DECIMAL(10, 4) NOT NULL DEFAULT 0,
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATE,
+    --Причина движения, создавшего партию, как в журнале: 'delivery', 'purchase order', 'initial stock' и т.д.
+    reason TEXT,
+    --Время списания просроченного остатка партии.
+    expired_at TIMESTAMP
+);
+
+CREATE INDEX idx_inventory_lots_open ON inventory_lots (ingredient_id, received_at) WHERE remaining > 0;
+
+--Журнал списаний: пролитое, переделанные напитки, испорченное и просроченное. Списывается ингредиент
+--или порция позиции меню целиком по рецепту; название сохраняется на случай удаления.
+CREATE TABLE waste_log (
+    waste_id SERIAL PRIMARY KEY,
+    ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE SET NULL,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE SET NULL,
+    variant_id INT REFERENCES menu_item_variants(variant_id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('spill', 'remake', 'spoilage', 'expired', 'breakage', 'other')),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_waste_log_created ON waste_log (created_at);
+
+--Списанные ингредиенты каждой записи журнала с себестоимостью единицы на момент списания;
+--название и единица сохраняются на случай удаления ингредиента.
+CREATE TABLE waste_lines (
+    line_id SERIAL PRIMARY KEY,
+    waste_id INT NOT NULL REFERENCES waste_log(waste_id) ON DELETE CASCADE,
+    ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    unit VARCHAR(20) NOT NULL,
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10, 4) NOT NULL DEFAULT 0,
+    UNIQUE (waste_id, ingredient_id)
+);
+
+--Резерв ингредиентов под открытые заказы: закрытие заказа списывает резерв, отмена или истечение срока
+--его снимает.
+CREATE TABLE inventory_reservations (
+    order_id INT NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (order_id, ingredient_id)
+);
+
+CREATE INDEX idx_inventory_reservations_ingredient ON inventory_reservations (ingredient_id);
+
+--Сессии инвентаризации: пока сессия открыта, счётчики вносят пересчитанные остатки, при завершении
+--остатки заменяются суммой пересчётов. Открытой может быть только одна сессия.
+CREATE TABLE stocktakes (
+    stocktake_id SERIAL PRIMARY KEY,
+    status stocktake_status NOT NULL DEFAULT 'open',
+    note TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finalized_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_stocktakes_open ON stocktakes (status) WHERE status = 'open';
+
+--Пересчёты ингредиента в сессии: каждый счётчик считает свою зону, повторный пересчёт заменяет предыдущий.
+CREATE TABLE stocktake_entries (
+    entry_id SERIAL PRIMARY KEY,
+    stocktake_id INT NOT NULL REFERENCES stocktakes(stocktake_id) ON DELETE CASCADE,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    counter VARCHAR(100) NOT NULL,
+    counted_quantity FLOAT NOT NULL CHECK (counted_quantity >= 0),
+    counted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (stocktake_id, ingredient_id, counter)
+);
+
+--Пересчёт остатка ингредиента: единственный способ задать остаток абсолютным значением; расхождение сохраняется.
+CREATE TABLE stock_counts (
+    count_id SERIAL PRIMARY KEY,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    --Сессия инвентаризации; NULL для пересчёта одного ингредиента.
+    stocktake_id INT REFERENCES stocktakes(stocktake_id) ON DELETE CASCADE,
+    expected_quantity FLOAT NOT NULL,
+    counted_quantity FLOAT NOT NULL CHECK (counted_quantity >= 0),
+    variance FLOAT GENERATED ALWAYS AS (counted_quantity - expected_quantity) STORED,
+    --Себестоимость единицы на момент пересчёта для оценки расхождения в деньгах.
+    unit_cost DECIMAL(10, 4) NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
+
+CREATE INDEX idx_stock_counts_stocktake ON stock_counts (stocktake_id);
+--Рецепт позиции меню с учётом варианта: переопределённый рецепт варианта или базовый рецепт, умноженный на коэффициент.
+CREATE OR REPLACE FUNCTION recipe_for(p_product_id INT, p_variant_id INT)
+RETURNS TABLE (ingredient_id INT, quantity FLOAT) AS $$
+    SELECT vi.ingredient_id, vi.stock_quantity
+    FROM menu_item_variant_ingredients vi
+    WHERE vi.variant_id = p_variant_id
+    UNION ALL
+    SELECT mii.ingredient_id, mii.stock_quantity * COALESCE(v.recipe_multiplier, 1)
+    FROM menu_item_ingredients mii
+    LEFT JOIN menu_item_variants v ON v.variant_id = p_variant_id
+    WHERE mii.product_id = p_product_id
+        AND NOT EXISTS (
+            SELECT 1 FROM menu_item_variant_ingredients vi WHERE vi.variant_id = p_variant_id
+        );
+$$ LANGUAGE sql STABLE;
+
+--Себестоимость позиции меню по рецепту и цене ингредиентов.
+CREATE VIEW menu_item_costs AS
+WITH recipe_costs AS (
+    SELECT
+        mi.product_id,
+        COALESCE(SUM(mii.stock_quantity * i.price), 0) AS cost
+    FROM menu_items mi
+    LEFT JOIN menu_item_ingredients mii ON mi.product_id = mii.product_id
+    LEFT JOIN inventory i ON mii.ingredient_id = i.ingredient_id
+    GROUP BY mi.product_id
+),
+--Для слота комбо-набора берётся самый дорогой из вариантов.
+slot_costs AS (
+    SELECT s.bundle_id, s.quantity * MAX(rc.cost) AS cost
+    FROM bundle_slots s
+    JOIN bundle_slot_options o ON s.slot_id = o.slot_id
+    JOIN recipe_costs rc ON o.product_id = rc.product_id
+    GROUP BY s.bundle_id, s.slot_id, s.quantity
+)
+SELECT
+    rc.product_id,
+    rc.cost + COALESCE((SELECT SUM(sc.cost) FROM slot_costs sc WHERE sc.bundle_id = rc.product_id), 0) AS cost
+FROM recipe_costs rc;
+
+--Доступна ли позиция меню в момент p_at: у самой позиции и у каждой её категории вверх по дереву,
+--где заданы окна, должно совпасть хотя бы одно окно. Позиция без окон доступна всегда.
+CREATE OR REPLACE FUNCTION menu_item_available_at(p_product_id INT, p_at TIMESTAMP)
+RETURNS BOOLEAN AS $$
+    WITH RECURSIVE scopes AS (
+        SELECT mi.product_id, NULL::INT AS category_id, mi.category_id AS parent_id
+        FROM menu_items mi
+        WHERE mi.product_id = p_product_id
+        UNION ALL
+        SELECT NULL::INT, c.category_id, c.parent_id
+        FROM categories c
+        JOIN scopes s ON c.category_id = s.parent_id
+    )
+    SELECT NOT EXISTS (
+        SELECT 1
+        FROM scopes s
+        WHERE EXISTS (
+            SELECT 1 FROM availability_windows w
+            WHERE w.product_id = s.product_id OR w.category_id = s.category_id
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM availability_windows w
+            WHERE (w.product_id = s.product_id OR w.category_id = s.category_id)
+            AND (w.days_of_week IS NULL OR EXTRACT(ISODOW FROM p_at)::INT = ANY(w.days_of_week))
+            AND (w.start_date IS NULL OR p_at::DATE >= w.start_date)
+            AND (w.end_date IS NULL OR p_at::DATE <= w.end_date)
+            AND (
+                w.start_time IS NULL
+                OR (w.start_time <= w.end_time AND p_at::TIME >= w.start_time AND p_at::TIME < w.end_time)
+                OR (w.start_time > w.end_time AND (p_at::TIME >= w.start_time OR p_at::TIME < w.end_time))
+            )
+        )
+    );
+$$ LANGUAGE sql STABLE;
+
+--Аллергены позиции меню из рецепта, вариантов и компонентов комбо-набора, и отдельно из модификаторов, доступных для позиции.
+CREATE VIEW menu_item_allergens AS
+SELECT
+    mi.product_id,
+    ARRAY(
+        SELECT DISTINCT allergen
+        FROM (
+            SELECT mii.ingredient_id
+            FROM menu_item_ingredients mii
+            WHERE mii.product_id = mi.product_id AND mii.quantity > 0
+            UNION
+            SELECT vi.ingredient_id
+            FROM menu_item_variant_ingredients vi
+            JOIN menu_item_variants v ON vi.variant_id = v.variant_id
+            WHERE v.product_id = mi.product_id AND vi.quantity > 0
+            UNION
+            SELECT cmi.ingredient_id
+            FROM bundle_slots bs
+            JOIN bundle_slot_options bo ON bs.slot_id = bo.slot_id
+            JOIN menu_item_ingredients cmi ON bo.product_id = cmi.product_id
+            WHERE bs.bundle_id = mi.product_id AND cmi.quantity > 0
+        ) r
+        JOIN inventory i ON r.ingredient_id = i.ingredient_id
+        CROSS JOIN UNNEST(i.allergens) AS allergen
+        ORDER BY allergen
+    ) AS allergens,
+    ARRAY(
+        SELECT DISTINCT allergen
+        FROM menu_item_modifier_groups mg
+        JOIN modifier_options mo ON mg.group_id = mo.group_id
+        JOIN modifier_option_ingredients moi ON mo.option_id = moi.option_id AND moi.quantity > 0
+        JOIN inventory i ON moi.ingredient_id = i.ingredient_id
+        CROSS JOIN UNNEST(i.allergens) AS allergen
+        WHERE mg.product_id = mi.product_id
+        ORDER BY allergen
+    ) AS modifier_allergens
+FROM menu_items mi;
+
+--Пищевая ценность порции позиции меню: базовый рецепт (variant_id NULL) и каждый вариант.
+CREATE VIEW menu_item_nutrition AS
+SELECT
+    s.product_id,
+    s.variant_id,
+    COALESCE(SUM(r.quantity * i.kcal), 0) AS kcal,
+    COALESCE(SUM(r.quantity * i.sugar), 0) AS sugar,
+    COALESCE(SUM(r.quantity * i.fat), 0) AS fat,
+    COALESCE(SUM(r.quantity * i.caffeine), 0) AS caffeine
+FROM (
+    SELECT product_id, NULL::INT AS variant_id FROM menu_items
+    UNION ALL
+    SELECT product_id, variant_id FROM menu_item_variants
+) s
+LEFT JOIN LATERAL recipe_for(s.product_id, s.variant_id) r ON TRUE
+LEFT JOIN inventory i ON r.ingredient_id = i.ingredient_id
+GROUP BY s.product_id, s.variant_id;
+
+--Изменение пищевой ценности порции при выборе опции модификатора; для замен может быть отрицательным.
+CREATE VIEW modifier_option_nutrition AS
+SELECT
+    mo.option_id,
+    COALESCE(SUM(moi.stock_quantity * i.kcal), 0) AS kcal,
+    COALESCE(SUM(moi.stock_quantity * i.sugar), 0) AS sugar,
+    COALESCE(SUM(moi.stock_quantity * i.fat), 0) AS fat,
+    COALESCE(SUM(moi.stock_quantity * i.caffeine), 0) AS caffeine
+FROM modifier_options mo
+LEFT JOIN modifier_option_ingredients moi ON mo.option_id = moi.option_id
+LEFT JOIN inventory i ON moi.ingredient_id = i.ingredient_id
+GROUP BY mo.option_id;
+
+--Ингредиенты, необходимые для каждой строки заказа, включая рецепты компонентов комбо-набора.
+CREATE VIEW order_line_ingredients AS
+SELECT oi.order_item_id, oi.order_id, r.ingredient_id, r.quantity * oi.quantity AS quantity
+FROM order_items oi
+CROSS JOIN LATERAL recipe_for(oi.product_id, oi.variant_id) r
+UNION ALL
+SELECT oi.order_item_id, oi.order_id, moi.ingredient_id, moi.stock_quantity * oi.quantity AS quantity
+FROM order_items oi
+JOIN order_item_modifiers oim ON oi.order_item_id = oim.order_item_id
+JOIN modifier_option_ingredients moi ON oim.option_id = moi.option_id
+UNION ALL
+SELECT oi.order_item_id, oi.order_id, r.ingredient_id, r.quantity * oic.quantity * oi.quantity AS quantity
+FROM order_items oi
+JOIN order_item_components oic ON oi.order_item_id = oic.order_item_id
+CROSS JOIN LATERAL recipe_for(oic.product_id, NULL) r;
+
+--Остаток на складе, резерв открытых заказов и доступный для новых заказов остаток.
+CREATE VIEW inventory_stock AS
+SELECT
+    i.ingredient_id,
+    i.quantity AS on_hand,
+    COALESCE(r.reserved, 0) AS reserved,
+    i.quantity - COALESCE(r.reserved, 0) AS available
+FROM inventory i
+LEFT JOIN (
+    SELECT ingredient_id, SUM(quantity) AS reserved
+    FROM inventory_reservations
+    GROUP BY ingredient_id
+) r ON i.ingredient_id = r.ingredient_id;
+
+--Аллергены каждой строки заказа по ингредиентам, которые остаются в напитке после модификаторов.
+CREATE VIEW order_line_allergens AS
+SELECT li.order_item_id, li.order_id, ARRAY_AGG(DISTINCT allergen ORDER BY allergen) AS allergens
+FROM (
+    SELECT order_item_id, order_id, ingredient_id
+    FROM order_line_ingredients
+    GROUP BY order_item_id, order_id, ingredient_id
+    HAVING SUM(quantity) > 0
+) li
+JOIN inventory i ON li.ingredient_id = i.ingredient_id
+CROSS JOIN UNNEST(i.allergens) AS allergen
+GROUP BY li.order_item_id, li.order_id;
+
+--Пищевая ценность одной порции строки заказа с учётом варианта, модификаторов и компонентов комбо-набора.
+CREATE VIEW order_line_nutrition AS
+SELECT
+    li.order_item_id,
+    li.order_id,
+    SUM(li.quantity * i.kcal) / NULLIF(oi.quantity, 0) AS kcal,
+    SUM(li.quantity * i.sugar) / NULLIF(oi.quantity, 0) AS sugar,
+    SUM(li.quantity * i.fat) / NULLIF(oi.quantity, 0) AS fat,
+    SUM(li.quantity * i.caffeine) / NULLIF(oi.quantity, 0) AS caffeine
+FROM order_line_ingredients li
+JOIN order_items oi ON li.order_item_id = oi.order_item_id
+JOIN inventory i ON li.ingredient_id = i.ingredient_id
+GROUP BY li.order_item_id, li.order_id, oi.quantity;
+
+--Цена и сумма каждой строки заказа с учётом варианта и модификаторов.
+CREATE VIEW order_line_totals AS
+SELECT
+    oi.order_item_id,
+    oi.order_id,
+    oi.product_id,
+    oi.variant_id,
+    oi.quantity,
+    mi.name || COALESCE(' (' || v.name || ')', '') AS item_name,
+    COALESCE(v.price, mi.price) + COALESCE(m.price_delta, 0) AS unit_price,
+    (COALESCE(v.price, mi.price) + COALESCE(m.price_delta, 0)) * oi.quantity AS line_total
+FROM order_items oi
+JOIN menu_items mi ON oi.product_id = mi.product_id
+LEFT JOIN menu_item_variants v ON oi.variant_id = v.variant_id
+LEFT JOIN LATERAL (
+    SELECT SUM(mo.price_delta) AS price_delta
+    FROM order_item_modifiers oim
+    JOIN modifier_options mo ON oim.option_id = mo.option_id
+    WHERE oim.order_item_id = oi.order_item_id
+) m ON TRUE;
+
+--Продажи, отнесённые к компонентам: выручка строки комбо-набора делится между компонентами
+--пропорционально их цене в меню, остальные строки остаются как есть.
+CREATE VIEW order_line_component_sales AS
+SELECT t.order_item_id, t.order_id, t.product_id, t.item_name, t.quantity, t.line_total AS revenue, NULL::INT AS bundle_id
+FROM order_line_totals t
+WHERE NOT EXISTS (SELECT 1 FROM order_item_components oic WHERE oic.order_item_id = t.order_item_id)
+UNION ALL
+SELECT
+    t.order_item_id,
+    t.order_id,
+    mi.product_id,
+    mi.name,
+    oic.quantity * t.quantity,
+    COALESCE(
+        t.line_total * mi.price * oic.quantity / NULLIF(SUM(mi.price * oic.quantity) OVER (PARTITION BY t.order_item_id), 0),
+        t.line_total / COUNT(*) OVER (PARTITION BY t.order_item_id)
+    ),
+    t.product_id
+FROM order_line_totals t
+JOIN order_item_components oic ON t.order_item_id = oic.order_item_id
+JOIN menu_items mi ON oic.product_id = mi.product_id;
+
+--Вариант в строке заказа должен принадлежать заказанной позиции меню; снятый с меню вариант нельзя заказать.
+CREATE OR REPLACE FUNCTION check_order_item_variant()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.variant_id IS NOT NULL AND NOT EXISTS (
+        SELECT 1 FROM menu_item_variants
+        WHERE variant_id = NEW.variant_id AND product_id = NEW.product_id
+            AND (archived_at IS NULL OR TG_OP = 'UPDATE')
+    ) THEN
+        RAISE EXCEPTION 'Variant % does not belong to menu item %', NEW.variant_id, NEW.product_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER order_item_variant_check
+BEFORE INSERT OR UPDATE ON order_items
+FOR EACH ROW
+EXECUTE FUNCTION check_order_item_variant();
+
 --Автоматическое создание записи в price_history при обновлении цены товара в таблице menu_items.
 CREATE OR REPLACE FUNCTION log_price_change()
 RETURNS TRIGGER AS $$
@@ -79,6 +721,245 @@ AFTER UPDATE ON menu_items
 FOR EACH ROW
 EXECUTE FUNCTION log_price_change();
 
+--Причина и заказ для движений склада до конца текущей транзакции; их читает inventory_movement_trigger.
+CREATE OR REPLACE FUNCTION set_inventory_movement(p_reason TEXT, p_order_id INT)
+RETURNS VOID AS $$
+BEGIN
+    PERFORM set_config('inventory.reason', p_reason, true);
+    PERFORM set_config('inventory.order_id', COALESCE(p_order_id::TEXT, ''), true);
+END;
+$$ LANGUAGE plpgsql;
+
+--Автоматическая запись в inventory_transactions при любом изменении остатка. Без заданной причины
+--новый ингредиент записывается как 'initial stock', изменение остатка как 'manual update'. Смена единицы
+--не является движением: вместо записи журнал ингредиента пересчитывается в новую единицу.
+CREATE OR REPLACE FUNCTION log_inventory_movement()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta FLOAT := NEW.quantity - CASE WHEN TG_OP = 'INSERT' THEN 0 ELSE OLD.quantity END;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.unit IS DISTINCT FROM OLD.unit THEN
+        UPDATE inventory_transactions
+        SET quantity_change = convert_unit(quantity_change, OLD.unit, NEW.unit),
+            quantity_after = convert_unit(quantity_after, OLD.unit, NEW.unit)
+        WHERE ingredient_id = NEW.ingredient_id;
+        RETURN NEW;
+    END IF;
+    IF delta <> 0 THEN
+        INSERT INTO inventory_transactions (ingredient_id, quantity_change, reason, order_id, quantity_after)
+        VALUES (
+            NEW.ingredient_id,
+            delta,
+            COALESCE(
+                NULLIF(current_setting('inventory.reason', true), ''),
+                CASE WHEN TG_OP = 'INSERT' THEN 'initial stock' ELSE 'manual update' END
+            ),
+            NULLIF(current_setting('inventory.order_id', true), '')::INT,
+            NEW.quantity
+        );
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER inventory_movement_trigger
+AFTER INSERT OR UPDATE OF quantity, unit ON inventory
+FOR EACH ROW
+EXECUTE FUNCTION log_inventory_movement();
+
+--Срок годности партии, которую создаст следующее поступление в этой транзакции; NULL - без срока.
+CREATE OR REPLACE FUNCTION set_inventory_lot(p_expires_at DATE)
+RETURNS VOID AS $$
+BEGIN
+    PERFORM set_config('inventory.lot_expires_at', COALESCE(p_expires_at::TEXT, ''), true);
+END;
+$$ LANGUAGE plpgsql;
+
+--Поддерживает партии при любом изменении остатка: поступление (новый ингредиент, delivery, purchase order)
+--создаёт партию по текущей цене с причиной поступления, прочий прирост возвращается в последнюю партию, расход списывается
+--с партий по правилу ингредиента. Просроченные, но ещё не списанные партии расходует только списание;
+--если партий не хватает, изменение отклоняется. Смена единицы пересчитывает партии. При inventory.lot_sync = 'off'
+--партии уже изменены вызывающим кодом.
+CREATE OR REPLACE FUNCTION sync_inventory_lots()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta FLOAT;
+    reason TEXT := COALESCE(NULLIF(current_setting('inventory.reason', true), ''), '');
+    expires DATE := NULLIF(current_setting('inventory.lot_expires_at', true), '')::DATE;
+    needed FLOAT;
+    taken FLOAT;
+    lot RECORD;
+    latest INT;
+BEGIN
+    IF current_setting('inventory.lot_sync', true) = 'off' THEN
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.unit IS DISTINCT FROM OLD.unit THEN
+        UPDATE inventory_lots
+        SET quantity = convert_unit(quantity, OLD.unit, NEW.unit),
+            remaining = convert_unit(remaining, OLD.unit, NEW.unit),
+            unit_cost = unit_cost / convert_unit(1, OLD.unit, NEW.unit)
+        WHERE ingredient_id = NEW.ingredient_id;
+        RETURN NEW;
+    END IF;
+    delta := NEW.quantity - CASE WHEN TG_OP = 'INSERT' THEN 0 ELSE OLD.quantity END;
+    IF delta > 0 THEN
+        IF TG_OP = 'UPDATE' AND reason NOT IN ('delivery', 'purchase order') THEN
+            SELECT lot_id INTO latest
+            FROM inventory_lots
+            WHERE ingredient_id = NEW.ingredient_id AND expired_at IS NULL
+            ORDER BY received_at DESC, lot_id DESC
+            LIMIT 1;
+        END IF;
+        IF latest IS NULL THEN
+            INSERT INTO inventory_lots (ingredient_id, quantity, remaining, unit_cost, expires_at, reason)
+            VALUES (
+                NEW.ingredient_id, delta, delta, NEW.price, expires,
+                COALESCE(NULLIF(reason, ''), CASE WHEN TG_OP = 'INSERT' THEN 'initial stock' ELSE 'manual update' END)
+            );
+        ELSE
+            UPDATE inventory_lots SET remaining = remaining + delta WHERE lot_id = latest;
+        END IF;
+    ELSIF delta < 0 THEN
+        needed := -delta;
+        FOR lot IN
+            SELECT lot_id, remaining
+            FROM inventory_lots
+            WHERE ingredient_id = NEW.ingredient_id AND remaining > 0
+                AND (expires_at IS NULL OR expires_at >= CURRENT_DATE OR reason = 'waste')
+            ORDER BY CASE WHEN NEW.consumption = 'fefo' THEN expires_at END NULLS LAST, received_at, lot_id
+            FOR UPDATE
+        LOOP
+            EXIT WHEN needed <= 0;
+            taken := LEAST(lot.remaining, needed);
+            UPDATE inventory_lots SET remaining = remaining - taken WHERE lot_id = lot.lot_id;
+            needed := needed - taken;
+        END LOOP;
+        IF needed > 0 THEN
+            RAISE EXCEPTION 'not enough unexpired stock in the lots of ingredient %', NEW.ingredient_id;
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER inventory_lot_trigger
+AFTER INSERT OR UPDATE OF quantity, unit ON inventory
+FOR EACH ROW
+EXECUTE FUNCTION sync_inventory_lots();
+
+--Когда изменение остатка пересекает точку заказа сверху вниз, записывается событие в stock_alerts
+--и публикуется уведомление в канал stock_alerts.
+CREATE OR REPLACE FUNCTION raise_stock_alert()
+RETURNS TRIGGER AS $$
+DECLARE
+    alert stock_alerts%ROWTYPE;
+BEGIN
+    INSERT INTO stock_alerts (ingredient_id, quantity, reorder_point, par_level, reason, order_id)
+    VALUES (
+        NEW.ingredient_id,
+        NEW.quantity,
+        NEW.reorder_point,
+        NEW.par_level,
+        NULLIF(current_setting('inventory.reason', true), ''),
+        NULLIF(current_setting('inventory.order_id', true), '')::INT
+    )
+    RETURNING * INTO alert;
+    PERFORM pg_notify('stock_alerts', json_build_object(
+        'alert_id', alert.alert_id,
+        'ingredient_id', alert.ingredient_id,
+        'name', NEW.name,
+        'quantity', alert.quantity,
+        'unit', NEW.unit,
+        'reorder_point', alert.reorder_point,
+        'par_level', alert.par_level,
+        'reason', alert.reason,
+        'order_id', alert.order_id
+    )::TEXT);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_alert_trigger
+AFTER UPDATE OF quantity ON inventory
+FOR EACH ROW
+WHEN (NEW.reorder_point IS NOT NULL AND NEW.quantity <= NEW.reorder_point AND OLD.quantity > NEW.reorder_point)
+EXECUTE FUNCTION raise_stock_alert();
+
+--Остаток ингредиента на момент p_at: текущий остаток минус все движения после этого момента.
+CREATE OR REPLACE FUNCTION inventory_level_at(p_ingredient_id INT, p_at TIMESTAMP)
+RETURNS FLOAT AS $$
+    SELECT i.quantity - COALESCE((
+        SELECT SUM(t.quantity_change)
+        FROM inventory_transactions t
+        WHERE t.ingredient_id = i.ingredient_id AND t.created_at > p_at
+    ), 0)
+    FROM inventory i
+    WHERE i.ingredient_id = p_ingredient_id;
+$$ LANGUAGE sql STABLE;
+
+--Перевод количества между единицами одной размерности; для несовместимых единиц - ошибка.
+CREATE OR REPLACE FUNCTION convert_unit(p_quantity FLOAT, p_from VARCHAR, p_to VARCHAR)
+RETURNS FLOAT AS $$
+DECLARE
+    converted FLOAT;
+BEGIN
+    SELECT p_quantity * f.factor / t.factor INTO converted
+    FROM units f
+    JOIN units t ON f.dimension = t.dimension
+    WHERE f.unit = p_from AND t.unit = p_to;
+    IF converted IS NULL AND p_quantity IS NOT NULL THEN
+        RAISE EXCEPTION 'Unit % cannot be converted to %', p_from, p_to;
+    END IF;
+    RETURN converted;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+--Строка рецепта без единицы получает единицу склада ингредиента; количество переводится в единицу склада.
+CREATE OR REPLACE FUNCTION set_recipe_stock_quantity()
+RETURNS TRIGGER AS $$
+DECLARE
+    stock_unit VARCHAR(20);
+BEGIN
+    SELECT unit INTO stock_unit FROM inventory WHERE ingredient_id = NEW.ingredient_id;
+    NEW.unit := COALESCE(NEW.unit, stock_unit);
+    NEW.stock_quantity := convert_unit(NEW.quantity, NEW.unit, stock_unit);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER recipe_stock_quantity_trigger
+BEFORE INSERT OR UPDATE ON menu_item_ingredients
+FOR EACH ROW
+EXECUTE FUNCTION set_recipe_stock_quantity();
+
+CREATE TRIGGER variant_stock_quantity_trigger
+BEFORE INSERT OR UPDATE ON menu_item_variant_ingredients
+FOR EACH ROW
+EXECUTE FUNCTION set_recipe_stock_quantity();
+
+CREATE TRIGGER modifier_stock_quantity_trigger
+BEFORE INSERT OR UPDATE ON modifier_option_ingredients
+FOR EACH ROW
+EXECUTE FUNCTION set_recipe_stock_quantity();
+
+--При смене единицы склада ингредиента рецепты пересчитывают stock_quantity в новую единицу.
+CREATE OR REPLACE FUNCTION refresh_recipe_stock_quantities()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE menu_item_ingredients SET unit = unit WHERE ingredient_id = NEW.ingredient_id;
+    UPDATE menu_item_variant_ingredients SET unit = unit WHERE ingredient_id = NEW.ingredient_id;
+    UPDATE modifier_option_ingredients SET unit = unit WHERE ingredient_id = NEW.ingredient_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER inventory_unit_trigger
+AFTER UPDATE OF unit ON inventory
+FOR EACH ROW
+WHEN (OLD.unit IS DISTINCT FROM NEW.unit)
+EXECUTE FUNCTION refresh_recipe_stock_quantities();
+
 --Автоматическое создание записи в order_status_history при изменении статуса заказа.
 CREATE OR REPLACE FUNCTION log_order_status_change()
 RETURNS TRIGGER AS $$
@@ -96,6 +977,37 @@ AFTER UPDATE ON orders
 FOR EACH ROW
 EXECUTE FUNCTION log_order_status_change();
 
+--Пересчёт ожидаемого времени готовности открытых заказов по очереди на каждой станции (NULL - все открытые заказы).
+CREATE OR REPLACE FUNCTION refresh_order_eta(p_order_id INT)
+RETURNS VOID AS $$
+BEGIN
+    WITH station_work AS (
+        SELECT o.order_id, o.created_at, mi.station, SUM(mi.prep_time * oi.quantity) AS work
+        FROM orders o
+        JOIN order_items oi ON o.order_id = oi.order_id
+        JOIN menu_items mi ON oi.product_id = mi.product_id
+        WHERE o.status = 'open'
+        GROUP BY o.order_id, o.created_at, mi.station
+    ),
+    queued AS (
+        SELECT order_id, station,
+            SUM(work) OVER (PARTITION BY station ORDER BY created_at, order_id) AS cumulative
+        FROM station_work
+    ),
+    eta AS (
+        SELECT q.order_id, MAX(q.cumulative::FLOAT / GREATEST(s.active_staff, 1)) AS seconds
+        FROM queued q
+        JOIN stations s ON q.station = s.station
+        GROUP BY q.order_id
+    )
+    UPDATE orders
+    SET estimated_ready_at = CURRENT_TIMESTAMP + make_interval(secs => eta.seconds)
+    FROM eta
+    WHERE orders.order_id = eta.order_id
+        AND (p_order_id IS NULL OR orders.order_id = p_order_id);
+END;
+$$ LANGUAGE plpgsql;
+
 --Если в inventory вводится отрицательное значение для quantity, операция будет отклонена.
 CREATE OR REPLACE FUNCTION check_inventory_quantity()
 RETURNS TRIGGER AS $$
@@ -1,36 +1,42 @@
-INSERT INTO inventory (name, quantity, unit, price) VALUES
-('Coffee Beans', 50, 'kg', 100),
-('Milk', 200, 'liters', 120),
-('Sugar', 100, 'kg', 130),
-('Chocolate Syrup', 20, 'liters', 1000),
-('Vanilla Syrup', 15, 'liters', 1200),
-('Caramel Syrup', 10, 'liters', 1000),
-('Whipped Cream', 30, 'kg',2000),
-('Tea Leaves', 40, 'kg', 800),
-('Paper Cups', 1000, 'pieces', 500),
-('Lids', 1000, 'pieces', 300),
-('Straws', 1000, 'pieces', 50),
-('Espresso Machine Filters', 25, 'pieces', 500),
-('Cocoa Powder', 10, 'kg', 300),
-('Green Tea Powder', 8, 'kg', 350),
-('Oat Milk', 50, 'liters', 400),
-('Almond Milk', 40, 'liters', 600),
-('Honey', 20, 'kg', 800),
-('Cinnamon Powder', 5, 'kg', 700),
-('Ice Cubes', 500, 'kg', 5000),
-('Napkins', 2000, 'pieces', 10);
-
-INSERT INTO menu_items (name, description, price, category, allergens) VALUES
-('Espresso', 'Strong black coffee', 2.50, 'Coffee', ARRAY['None']),
-('Cappuccino', 'Coffee with steamed milk foam', 3.50, 'Coffee', ARRAY['Milk']),
-('Latte', 'Espresso with steamed milk', 3.80, 'Coffee', ARRAY['Milk']),
-('Mocha', 'Coffee with chocolate and milk', 4.00, 'Coffee', ARRAY['Milk', 'Chocolate']),
-('Americano', 'Diluted espresso', 2.70, 'Coffee', ARRAY['None']),
-('Caramel Macchiato', 'Espresso with caramel and milk', 4.50, 'Coffee', ARRAY['Milk']),
-('Green Tea Latte', 'Steamed milk with matcha', 4.00, 'Tea', ARRAY['Milk']),
-('Hot Chocolate', 'Rich chocolate drink', 3.50, 'Beverage', ARRAY['Milk', 'Chocolate']),
-('Iced Coffee', 'Cold brewed coffee', 3.00, 'Cold Beverage', ARRAY['None']),
-('Honey Lemon Tea', 'Herbal tea with honey and lemon', 2.80, 'Tea', ARRAY['Honey']);
+INSERT INTO inventory (name, quantity, unit, price, allergens, kcal, sugar, fat, caffeine) VALUES
+('Coffee Beans', 50, 'kg', 100, '{}', 110, 0, 0, 7000),
+('Milk', 200, 'liters', 120, ARRAY['milk'], 640, 48, 36, 0),
+('Sugar', 100, 'kg', 130, '{}', 4000, 1000, 0, 0),
+('Chocolate Syrup', 20, 'liters', 1000, ARRAY['milk', 'soy'], 2800, 650, 10, 130),
+('Vanilla Syrup', 15, 'liters', 1200, '{}', 2700, 670, 0, 0),
+('Caramel Syrup', 10, 'liters', 1000, ARRAY['milk'], 2700, 650, 10, 0),
+('Whipped Cream', 30, 'kg',2000, ARRAY['milk'], 2570, 125, 220, 0),
+('Tea Leaves', 40, 'kg', 800, '{}', 1000, 0, 0, 20000),
+('Paper Cups', 1000, 'pieces', 500, '{}', 0, 0, 0, 0),
+('Lids', 1000, 'pieces', 300, '{}', 0, 0, 0, 0),
+('Straws', 1000, 'pieces', 50, '{}', 0, 0, 0, 0),
+('Espresso Machine Filters', 25, 'pieces', 500, '{}', 0, 0, 0, 0),
+('Cocoa Powder', 10, 'kg', 300, '{}', 2280, 18, 137, 2300),
+('Green Tea Powder', 8, 'kg', 350, '{}', 3240, 0, 53, 32000),
+('Oat Milk', 50, 'liters', 400, ARRAY['gluten'], 480, 40, 15, 0),
+('Almond Milk', 40, 'liters', 600, ARRAY['nuts'], 150, 0, 11, 0),
+('Honey', 20, 'kg', 800, '{}', 3040, 820, 0, 0),
+('Cinnamon Powder', 5, 'kg', 700, '{}', 2470, 22, 12, 0),
+('Ice Cubes', 500, 'kg', 5000, '{}', 0, 0, 0, 0),
+('Napkins', 2000, 'pieces', 10, '{}', 0, 0, 0, 0);
+
+INSERT INTO categories (name, parent_id, sort_order) VALUES
+('Coffee', NULL, 1),
+('Tea', NULL, 2),
+('Beverage', NULL, 3),
+('Cold Beverage', 3, 1);
+
+INSERT INTO menu_items (name, description, price, category_id) VALUES
+('Espresso', 'Strong black coffee', 2.50, 1),
+('Cappuccino', 'Coffee with steamed milk foam', 3.50, 1),
+('Latte', 'Espresso with steamed milk', 3.80, 1),
+('Mocha', 'Coffee with chocolate and milk', 4.00, 1),
+('Americano', 'Diluted espresso', 2.70, 1),
+('Caramel Macchiato', 'Espresso with caramel and milk', 4.50, 1),
+('Green Tea Latte', 'Steamed milk with matcha', 4.00, 2),
+('Hot Chocolate', 'Rich chocolate drink', 3.50, 3),
+('Iced Coffee', 'Cold brewed coffee', 3.00, 4),
+('Honey Lemon Tea', 'Herbal tea with honey and lemon', 2.80, 2);
 
 INSERT INTO orders (customer_name, status) VALUES
 ('John Doe', 'open'),
@@ -103,28 +109,82 @@ INSERT INTO inventory_transactions (ingredient_id, quantity_change, reason, crea
 (9, -50, 'Paper cups for orders', '2024-01-09'),
 (10, -50, 'Lids usage', '2024-01-10');
 
-INSERT INTO menu_item_ingredients (product_id, ingredient_id, quantity) VALUES
-(1, 1, 1),
-(2, 1, 1),
-(2, 2, 1),
-(3, 1, 1),
-(3, 2, 1),
-(4, 1, 1),
-(4, 2, 1),
-(4, 3, 1),
-(5, 1, 1),
-(5, 4, 1),
-(6, 1, 1),
-(6, 2, 1),
-(6, 5, 1),
-(7, 6, 1),
-(7, 2, 1),
-(8, 3, 1),
-(8, 2, 1),
-(9, 1, 1),
-(9, 4, 1),
-(9, 7, 1),
-(10, 8, 1),
-(10, 9, 1),
-(10, 10, 1);
+INSERT INTO menu_item_ingredients (product_id, ingredient_id, quantity, unit) VALUES
+(1, 1, 18, 'g'),
+(2, 1, 18, 'g'),
+(2, 2, 150, 'ml'),
+(3, 1, 18, 'g'),
+(3, 2, 250, 'ml'),
+(4, 1, 18, 'g'),
+(4, 2, 200, 'ml'),
+(4, 4, 30, 'ml'),
+(5, 1, 18, 'g'),
+(6, 1, 18, 'g'),
+(6, 2, 200, 'ml'),
+(6, 5, 20, 'ml'),
+(6, 6, 10, 'ml'),
+(7, 14, 3, 'g'),
+(7, 2, 250, 'ml'),
+(8, 13, 20, 'g'),
+(8, 3, 15, 'g'),
+(8, 2, 250, 'ml'),
+(9, 1, 18, 'g'),
+(9, 19, 150, 'g'),
+(9, 2, 100, 'ml'),
+(10, 8, 2.5, 'g'),
+(10, 17, 15, 'g'),
+(10, 9, 1, 'pieces'),
+(10, 10, 1, 'pieces');
+
+
+INSERT INTO menu_item_variants (product_id, name, price, recipe_multiplier) VALUES
+(2, 'Small', 3.00, 0.75),
+(2, 'Medium', 3.50, 1),
+(2, 'Large', 4.20, 1.5),
+(3, 'Small', 3.30, 0.75),
+(3, 'Medium', 3.80, 1),
+(3, 'Large', 4.50, 1.5),
+(9, 'Regular', 3.00, 1),
+(9, 'Large', 3.80, 1.5);
+
+INSERT INTO menu_item_variant_ingredients (variant_id, ingredient_id, quantity, unit) VALUES
+(8, 1, 27, 'g'),
+(8, 19, 200, 'g'),
+(8, 2, 150, 'ml');
+
+INSERT INTO modifier_groups (name, min_select, max_select) VALUES
+('Milk choice', 1, 1),
+('Syrups', 0, 3);
+
+INSERT INTO modifier_options (group_id, name, price_delta) VALUES
+(1, 'Whole milk', 0),
+(1, 'Oat milk', 0.50),
+(1, 'Almond milk', 0.60),
+(2, 'Vanilla', 0.40),
+(2, 'Caramel', 0.40),
+(2, 'Chocolate', 0.40);
+
+INSERT INTO modifier_option_ingredients (option_id, ingredient_id, quantity, unit) VALUES
+(2, 2, -100, 'ml'),
+(2, 15, 100, 'ml'),
+(3, 2, -100, 'ml'),
+(3, 16, 100, 'ml'),
+(4, 5, 20, 'ml'),
+(5, 6, 20, 'ml'),
+(6, 4, 20, 'ml');
+
+INSERT INTO menu_item_modifier_groups (product_id, group_id) VALUES
+(2, 1), (2, 2),
+(3, 1), (3, 2),
+(6, 1);
+
+INSERT INTO menu_items (name, description, price, category_id) VALUES
+('Coffee & Tea Duo', 'Any coffee with a honey lemon tea', 5.50, 3);
+
+INSERT INTO bundle_slots (bundle_id, name, quantity) VALUES
+(11, 'Coffee', 1),
+(11, 'Tea', 1);
 
+INSERT INTO bundle_slot_options (slot_id, product_id) VALUES
+(1, 2), (1, 3), (1, 5),
+(2, 10);
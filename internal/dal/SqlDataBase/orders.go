@@ -0,0 +1,63 @@
+package SqlDataBase
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Recalculates the estimated ready time of open orders from the queue at each station.
+// A nil orderID refreshes every open order, otherwise only the given one.
+func RefreshOrderETA(tx *sql.Tx, orderID *int) error {
+	_, err := tx.Exec(`SELECT refresh_order_eta($1)`, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to refresh order ETA: %w", err)
+	}
+	return nil
+}
+
+// Runs queries on the database or inside a transaction
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Rejects items ordered outside the availability windows of the item or its categories
+func CheckOrdersInWindow(db Querier, productsIds []int) error {
+	stmt := `
+	SELECT DISTINCT product_id
+	FROM UNNEST($1::INT[]) AS product_id
+	WHERE NOT menu_item_available_at(product_id, LOCALTIMESTAMP);
+	`
+	rows, err := db.Query(stmt, pq.Array(productsIds))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	unavailable := []string{}
+	for rows.Next() {
+		var productId string
+		err := rows.Scan(&productId)
+		if err != nil {
+			return err
+		}
+		unavailable = append(unavailable, productId)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(unavailable) > 0 {
+		return fmt.Errorf("These items are not available at this time %s", strings.Join(unavailable, ", "))
+	}
+	return nil
+}
+
+// Converts an INT[] column read into a pq.Int64Array; an empty array gives an empty slice, not nil
+func ToIntSlice(values pq.Int64Array) []int {
+	ints := make([]int, 0, len(values))
+	for _, value := range values {
+		ints = append(ints, int(value))
+	}
+	return ints
+}
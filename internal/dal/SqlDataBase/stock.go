@@ -0,0 +1,21 @@
+package SqlDataBase
+
+import "database/sql"
+
+// Locks the inventory rows of the ingredients an order needs, so concurrent orders check and take the same
+// stock one after another. Rows are locked in ingredient order to keep concurrent orders from deadlocking.
+func LockOrderIngredients(tx *sql.Tx, orderID int) error {
+	stmt := `
+	SELECT ingredient_id
+	FROM inventory
+	WHERE ingredient_id IN (
+		SELECT ingredient_id
+		FROM order_line_ingredients
+		WHERE order_id = $1
+	)
+	ORDER BY ingredient_id
+	FOR UPDATE;
+	`
+	_, err := tx.Exec(stmt, orderID)
+	return err
+}
@@ -1,6 +1,8 @@
 package dal
 
 import (
+	"database/sql"
+
 	"frapuccino/internal/dal/SqlDataBase"
 	"frapuccino/models"
 )
@@ -8,7 +10,14 @@ import (
 // AggregationsRepository defines the interface for reading JSON data for orders and menu items
 type AggregationsRepository interface {
 	RepositoryTotalSales() (float64, error)
-	RepositoryPopularItem() (error, []models.Popular)
+	RepositoryPopularItem(byComponent bool) (error, []models.Popular)
+	RepositoryPrepTime() ([]models.PrepTimeReport, error)
+	RepositoryMenuMargins() ([]models.MenuMargin, error)
+	RepositoryMarginAlerts() ([]models.MarginAlert, error)
+	RepositoryStockAlerts() ([]models.StockAlert, error)
+	RepositoryShrinkage(from, to *string, ingredientId int) ([]models.ShrinkageReport, error)
+	RepositoryWaste(from, to *string, period string) ([]models.WastePeriodTotal, error)
+	RepositoryInventoryValuation(asOf *string, method string) ([]models.IngredientValuation, error)
 }
 
 type aggregationsRepository struct {
@@ -24,13 +33,11 @@ func (r aggregationsRepository) RepositoryTotalSales() (float64, error) {
 	var res float64
 	stmt := `
 	SELECT 
-    COALESCE(SUM(oi.quantity * m.price), 0) AS total_sales
+    COALESCE(SUM(t.line_total), 0) AS total_sales
 FROM 
     orders o
 JOIN 
-    order_items oi ON o.order_id = oi.order_id
-JOIN 
-    menu_items m ON oi.product_id = m.product_id
+    order_line_totals t ON o.order_id = t.order_id
 WHERE 
     o.status = 'close';
 
@@ -44,21 +51,34 @@ WHERE
 	return res, nil
 }
 
-func (r aggregationsRepository) RepositoryPopularItem() (error, []models.Popular) {
+func (r aggregationsRepository) RepositoryPopularItem(byComponent bool) (error, []models.Popular) {
 	res := []models.Popular{}
 	query := `
 	SELECT 
-		m.name AS popular_item,
-		SUM(oi.quantity) AS quantity
+		t.item_name AS popular_item,
+		SUM(t.quantity) AS quantity,
+		SUM(t.line_total) AS revenue
+	FROM 
+		order_line_totals t
+	GROUP BY 
+		t.item_name
+	ORDER BY 
+		quantity DESC;
+`
+	if byComponent {
+		query = `
+	SELECT 
+		s.item_name AS popular_item,
+		SUM(s.quantity) AS quantity,
+		SUM(s.revenue) AS revenue
 	FROM 
-		order_items oi
-	JOIN 
-		menu_items m ON oi.product_id = m.product_id
+		order_line_component_sales s
 	GROUP BY 
-		m.name
+		s.item_name
 	ORDER BY 
 		quantity DESC;
 `
+	}
 	rows, err := r.newDB.Db.Query(query)
 	if err != nil {
 		return err, nil
@@ -66,7 +86,7 @@ func (r aggregationsRepository) RepositoryPopularItem() (error, []models.Popular
 	defer rows.Close()
 	for rows.Next() {
 		var item models.Popular
-		err = rows.Scan(&item.PopularSales, &item.Quantity)
+		err = rows.Scan(&item.PopularSales, &item.Quantity, &item.Revenue)
 		if err != nil {
 			return err, nil
 		}
@@ -77,3 +97,350 @@ func (r aggregationsRepository) RepositoryPopularItem() (error, []models.Popular
 	}
 	return nil, res
 }
+
+// Compares the prep time estimated from menu items with the actual time between open and close in order_status_history
+func (r aggregationsRepository) RepositoryPrepTime() ([]models.PrepTimeReport, error) {
+	res := []models.PrepTimeReport{}
+	query := `
+	WITH estimated AS (
+		SELECT order_id, MAX(work) AS estimated_seconds
+		FROM (
+			SELECT oi.order_id, mi.station, SUM(mi.prep_time * oi.quantity) AS work
+			FROM order_items oi
+			JOIN menu_items mi ON oi.product_id = mi.product_id
+			GROUP BY oi.order_id, mi.station
+		) station_work
+		GROUP BY order_id
+	),
+	actual AS (
+		SELECT
+			o.order_id,
+			o.customer_name,
+			EXTRACT(EPOCH FROM
+				MAX(h.changed_at) FILTER (WHERE h.status = 'close')
+				- COALESCE(MIN(h.changed_at) FILTER (WHERE h.status = 'open'), o.created_at)
+			)::INT AS actual_seconds
+		FROM orders o
+		JOIN order_status_history h ON o.order_id = h.order_id
+		GROUP BY o.order_id, o.customer_name, o.created_at
+		HAVING COUNT(*) FILTER (WHERE h.status = 'close') > 0
+	)
+	SELECT a.order_id, a.customer_name, e.estimated_seconds, a.actual_seconds
+	FROM actual a
+	JOIN estimated e ON a.order_id = e.order_id
+	ORDER BY a.order_id;
+`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.PrepTimeReport
+		err = rows.Scan(&item.OrderID, &item.CustomerName, &item.EstimatedSeconds, &item.ActualSeconds)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Returns the price and recipe cost of every menu item
+func (r aggregationsRepository) RepositoryMenuMargins() ([]models.MenuMargin, error) {
+	res := []models.MenuMargin{}
+	query := `
+	SELECT mi.product_id, mi.name, mi.price, c.cost
+	FROM menu_items mi
+	JOIN menu_item_costs c ON mi.product_id = c.product_id
+	ORDER BY mi.product_id;
+`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.MenuMargin
+		err = rows.Scan(&item.ProductID, &item.Name, &item.Price, &item.Cost)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r aggregationsRepository) RepositoryMarginAlerts() ([]models.MarginAlert, error) {
+	res := []models.MarginAlert{}
+	query := `
+	SELECT
+		a.alert_id,
+		a.product_id,
+		mi.name,
+		a.old_price,
+		a.new_price,
+		a.cost,
+		a.margin_percent,
+		a.threshold,
+		a.created_at
+	FROM margin_alerts a
+	JOIN menu_items mi ON a.product_id = mi.product_id
+	ORDER BY a.created_at DESC, a.alert_id DESC;
+`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alert models.MarginAlert
+		err = rows.Scan(
+			&alert.AlertID,
+			&alert.ProductID,
+			&alert.Name,
+			&alert.OldPrice,
+			&alert.NewPrice,
+			&alert.Cost,
+			&alert.MarginPercent,
+			&alert.Threshold,
+			&alert.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, alert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r aggregationsRepository) RepositoryStockAlerts() ([]models.StockAlert, error) {
+	res := []models.StockAlert{}
+	query := `
+	SELECT
+		a.alert_id,
+		a.ingredient_id,
+		i.name,
+		a.quantity,
+		i.unit,
+		a.reorder_point,
+		a.par_level,
+		a.reason,
+		a.order_id,
+		a.created_at
+	FROM stock_alerts a
+	JOIN inventory i ON a.ingredient_id = i.ingredient_id
+	ORDER BY a.created_at DESC, a.alert_id DESC;
+`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alert models.StockAlert
+		var parLevel sql.NullFloat64
+		var reason sql.NullString
+		var orderId sql.NullInt64
+		err = rows.Scan(
+			&alert.AlertID,
+			&alert.IngredientID,
+			&alert.Name,
+			&alert.Quantity,
+			&alert.Unit,
+			&alert.ReorderPoint,
+			&parLevel,
+			&reason,
+			&orderId,
+			&alert.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		alert.ParLevel = nullableFloat(parLevel)
+		alert.Reason = nullableString(reason)
+		alert.OrderID = nullableInt(orderId)
+		res = append(res, alert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Sums the variance of every stocktake finalized between from and to, optionally for one ingredient, oldest first
+func (r aggregationsRepository) RepositoryShrinkage(from, to *string, ingredientId int) ([]models.ShrinkageReport, error) {
+	res := []models.ShrinkageReport{}
+	query := `
+	SELECT
+		s.stocktake_id,
+		s.finalized_at,
+		COUNT(c.count_id),
+		COALESCE(SUM(-c.variance * c.unit_cost) FILTER (WHERE c.variance < 0), 0),
+		COALESCE(SUM(c.variance * c.unit_cost) FILTER (WHERE c.variance > 0), 0),
+		COALESCE(SUM(c.variance * c.unit_cost), 0)
+	FROM stocktakes s
+	JOIN stock_counts c ON s.stocktake_id = c.stocktake_id
+	WHERE s.status = 'finalized'
+		AND ($1::TIMESTAMP IS NULL OR s.finalized_at >= $1)
+		AND ($2::TIMESTAMP IS NULL OR s.finalized_at <= $2)
+		AND ($3 = 0 OR c.ingredient_id = $3)
+	GROUP BY s.stocktake_id, s.finalized_at
+	ORDER BY s.finalized_at, s.stocktake_id;
+`
+	rows, err := r.newDB.Db.Query(query, from, to, ingredientId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var report models.ShrinkageReport
+		err = rows.Scan(
+			&report.StocktakeID,
+			&report.FinalizedAt,
+			&report.CountedItems,
+			&report.ShrinkageValue,
+			&report.SurplusValue,
+			&report.VarianceValue,
+		)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Sums the cost of waste logged between from and to per reason and per day, week or month
+func (r aggregationsRepository) RepositoryWaste(from, to *string, period string) ([]models.WastePeriodTotal, error) {
+	res := []models.WastePeriodTotal{}
+	query := `
+	SELECT
+		date_trunc($3, w.created_at)::DATE,
+		w.reason,
+		COUNT(DISTINCT w.waste_id),
+		COALESCE(SUM(l.quantity * l.unit_cost), 0)
+	FROM waste_log w
+	LEFT JOIN waste_lines l ON w.waste_id = l.waste_id
+	WHERE ($1::TIMESTAMP IS NULL OR w.created_at >= $1)
+		AND ($2::TIMESTAMP IS NULL OR w.created_at <= $2)
+	GROUP BY 1, w.reason
+	ORDER BY 1, w.reason;
+`
+	rows, err := r.newDB.Db.Query(query, from, to, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var total models.WastePeriodTotal
+		err = rows.Scan(&total.PeriodStart, &total.Reason, &total.Entries, &total.Cost)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Values the stock on hand of every ingredient, at a past time reconstructed from the ledger when asOf is set.
+// Average weighs the cost of the deliveries and purchase order receipts so far, told apart from opening stock by
+// the reason stored on their lots; fifo values the stock at the lots it is made of, which for a past time are the
+// most recent lots, and last uses the current price. Stock not covered by a lot or bought yet is valued at the
+// current price.
+func (r aggregationsRepository) RepositoryInventoryValuation(asOf *string, method string) ([]models.IngredientValuation, error) {
+	res := []models.IngredientValuation{}
+	query := `
+	WITH levels AS (
+		SELECT
+			i.ingredient_id,
+			i.name,
+			i.unit,
+			i.price::FLOAT8 AS price,
+			CASE WHEN $1::TIMESTAMP IS NULL THEN i.quantity ELSE inventory_level_at(i.ingredient_id, $1) END AS quantity
+		FROM inventory i
+	),
+	receipts AS (
+		SELECT
+			l.ingredient_id,
+			l.quantity,
+			l.remaining,
+			l.unit_cost::FLOAT8 AS unit_cost,
+			SUM(l.quantity) OVER (PARTITION BY l.ingredient_id ORDER BY l.received_at DESC, l.lot_id DESC) - l.quantity AS newer
+		FROM inventory_lots l
+		WHERE $1::TIMESTAMP IS NULL OR l.received_at <= $1
+	),
+	purchases AS (
+		SELECT l.ingredient_id, SUM(l.quantity * l.unit_cost) / NULLIF(SUM(l.quantity), 0) AS average_cost
+		FROM inventory_lots l
+		WHERE ($1::TIMESTAMP IS NULL OR l.received_at <= $1)
+			AND l.reason IN ('delivery', 'purchase order')
+		GROUP BY l.ingredient_id
+	),
+	costs AS (
+		SELECT
+			v.ingredient_id,
+			SUM(r.quantity) AS received,
+			SUM(GREATEST(LEAST(r.quantity, v.quantity - r.newer), 0) * r.unit_cost) AS newest_value,
+			SUM(r.remaining) AS remaining,
+			SUM(r.remaining * r.unit_cost) AS remaining_value
+		FROM levels v
+		JOIN receipts r ON v.ingredient_id = r.ingredient_id
+		GROUP BY v.ingredient_id
+	),
+	valued AS (
+		SELECT
+			v.ingredient_id,
+			v.name,
+			v.unit,
+			v.quantity,
+			CASE $2::TEXT
+				WHEN 'last' THEN v.quantity * v.price
+				WHEN 'average' THEN v.quantity * COALESCE(p.average_cost, v.price)
+				WHEN 'fifo' THEN CASE
+					WHEN $1::TIMESTAMP IS NULL THEN COALESCE(c.remaining_value, 0) + GREATEST(v.quantity - COALESCE(c.remaining, 0), 0) * v.price
+					ELSE COALESCE(c.newest_value, 0) + GREATEST(v.quantity - COALESCE(c.received, 0), 0) * v.price
+				END
+			END AS value
+		FROM levels v
+		LEFT JOIN costs c ON v.ingredient_id = c.ingredient_id
+		LEFT JOIN purchases p ON v.ingredient_id = p.ingredient_id
+		WHERE v.quantity > 0
+	)
+	SELECT ingredient_id, name, unit, quantity, value / quantity, value
+	FROM valued
+	ORDER BY value DESC, name;
+`
+	rows, err := r.newDB.Db.Query(query, asOf, method)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.IngredientValuation
+		err = rows.Scan(&item.IngredientID, &item.Name, &item.Unit, &item.Quantity, &item.UnitCost, &item.Value)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,73 @@
+package dal
+
+import (
+	"database/sql"
+
+	"frapuccino/models"
+)
+
+// Computes for every menu item how many servings the stock not reserved by open orders allows and which ingredient runs out first
+func (r *jsonMenuRepository) GetMenuAvailability() ([]models.MenuAvailability, error) {
+	query := `
+	SELECT DISTINCT ON (mi.product_id)
+		mi.product_id,
+		mi.name,
+		mi.price,
+		FLOOR(GREATEST(s.available, 0) / mii.stock_quantity)::INT AS servings,
+		i.ingredient_id,
+		i.name,
+		s.available,
+		i.unit,
+		mii.stock_quantity
+	FROM menu_items mi
+	LEFT JOIN menu_item_ingredients mii ON mi.product_id = mii.product_id AND mii.quantity > 0
+	LEFT JOIN inventory i ON mii.ingredient_id = i.ingredient_id
+	LEFT JOIN inventory_stock s ON i.ingredient_id = s.ingredient_id
+	WHERE mi.archived_at IS NULL
+	ORDER BY mi.product_id, FLOOR(s.available / mii.stock_quantity) ASC NULLS LAST;
+	`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	availability := []models.MenuAvailability{}
+	for rows.Next() {
+		var item models.MenuAvailability
+		var servings, ingredientId sql.NullInt64
+		var ingredientName, unit sql.NullString
+		var quantity, perServing sql.NullFloat64
+		err := rows.Scan(
+			&item.ProductID,
+			&item.Name,
+			&item.Price,
+			&servings,
+			&ingredientId,
+			&ingredientName,
+			&quantity,
+			&unit,
+			&perServing,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.Available = true
+		if servings.Valid {
+			count := int(servings.Int64)
+			item.Servings = &count
+			item.Available = count > 0
+			item.LimitingIngredient = &models.LimitingIngredient{
+				IngredientID: int(ingredientId.Int64),
+				Name:         ingredientName.String,
+				Quantity:     quantity.Float64,
+				Unit:         unit.String,
+				PerServing:   perServing.Float64,
+			}
+		}
+		availability = append(availability, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
@@ -0,0 +1,103 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// Replaces the availability windows of a menu item or category; column is product_id or category_id
+func saveAvailabilityWindows(tx *sql.Tx, column string, id int, windows []models.AvailabilityWindow) error {
+	_, err := tx.Exec(fmt.Sprintf(`DELETE FROM availability_windows WHERE %s = $1`, column), id)
+	if err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf(`
+	INSERT INTO availability_windows (%s, days_of_week, start_time, end_time, start_date, end_date)
+	VALUES ($1, $2, $3, $4, $5, $6);
+	`, column)
+	for _, window := range windows {
+		var days any
+		if len(window.DaysOfWeek) > 0 {
+			days = pq.Array(window.DaysOfWeek)
+		}
+		_, err = tx.Exec(stmt, id, days, window.StartTime, window.EndTime, window.StartDate, window.EndDate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads availability windows keyed by product_id or category_id, for one owner when id is given
+func readAvailabilityWindows(db *sql.DB, column string, id *int) (map[int][]models.AvailabilityWindow, error) {
+	query := fmt.Sprintf(`
+	SELECT
+		%[1]s,
+		days_of_week,
+		TO_CHAR(start_time, 'HH24:MI'),
+		TO_CHAR(end_time, 'HH24:MI'),
+		TO_CHAR(start_date, 'YYYY-MM-DD'),
+		TO_CHAR(end_date, 'YYYY-MM-DD')
+	FROM availability_windows
+	WHERE %[1]s IS NOT NULL AND ($1::INT IS NULL OR %[1]s = $1)
+	ORDER BY window_id;
+	`, column)
+	rows, err := db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	windows := make(map[int][]models.AvailabilityWindow)
+	for rows.Next() {
+		var ownerId int
+		var days pq.Int64Array
+		var startTime, endTime, startDate, endDate sql.NullString
+		err := rows.Scan(&ownerId, &days, &startTime, &endTime, &startDate, &endDate)
+		if err != nil {
+			return nil, err
+		}
+		windows[ownerId] = append(windows[ownerId], models.AvailabilityWindow{
+			DaysOfWeek: SqlDataBase.ToIntSlice(days),
+			StartTime:  nullableString(startTime),
+			EndTime:    nullableString(endTime),
+			StartDate:  nullableString(startDate),
+			EndDate:    nullableString(endDate),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// Returns which menu items fall inside their availability windows at the given local time, or now when at is nil
+func (r *jsonMenuRepository) GetProductsAvailableAt(at *string) (map[int]bool, error) {
+	query := `
+	SELECT product_id, menu_item_available_at(product_id, COALESCE($1::TIMESTAMP, LOCALTIMESTAMP))
+	FROM menu_items;
+	`
+	rows, err := r.newDB.Db.Query(query, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	available := make(map[int]bool)
+	for rows.Next() {
+		var productId int
+		var ok bool
+		err := rows.Scan(&productId, &ok)
+		if err != nil {
+			return nil, err
+		}
+		available[productId] = ok
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return available, nil
+}
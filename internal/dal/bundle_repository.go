@@ -0,0 +1,118 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// Upserts the slots of a bundle by name, replaces their options and removes slots no longer listed.
+// Bundles cannot contain themselves or other bundles, and an item used as a component cannot become a bundle
+func saveBundleSlots(tx *sql.Tx, bundleId int, slots []models.BundleSlot) error {
+	slotStmt := `
+	INSERT INTO bundle_slots (bundle_id, name, quantity)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (bundle_id, name) DO UPDATE
+	SET quantity = EXCLUDED.quantity
+	RETURNING slot_id;
+	`
+	deleteOptionsStmt := `DELETE FROM bundle_slot_options WHERE slot_id = $1`
+	optionStmt := `
+	INSERT INTO bundle_slot_options (slot_id, product_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING;
+	`
+	names := []string{}
+	for _, slot := range slots {
+		quantity := slot.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		var slotId int
+		err := tx.QueryRow(slotStmt, bundleId, slot.Name, quantity).Scan(&slotId)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(deleteOptionsStmt, slotId)
+		if err != nil {
+			return err
+		}
+		for _, productId := range slot.Options {
+			_, err = tx.Exec(optionStmt, slotId, productId)
+			if err != nil {
+				var pqErr *pq.Error
+				if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+					return errors.New("bundle component is not in menu")
+				}
+				return err
+			}
+		}
+		names = append(names, slot.Name)
+	}
+	removeStmt := `
+	DELETE FROM bundle_slots
+	WHERE bundle_id = $1 AND NOT (name = ANY($2::TEXT[]));
+	`
+	_, err := tx.Exec(removeStmt, bundleId, pq.Array(names))
+	if err != nil {
+		return err
+	}
+	if len(slots) == 0 {
+		return nil
+	}
+	nestedStmt := `
+	SELECT EXISTS (
+		SELECT 1
+		FROM bundle_slots s
+		JOIN bundle_slot_options o ON s.slot_id = o.slot_id
+		WHERE s.bundle_id = $1
+		AND (o.product_id = $1 OR EXISTS (SELECT 1 FROM bundle_slots n WHERE n.bundle_id = o.product_id))
+	) OR EXISTS (SELECT 1 FROM bundle_slot_options WHERE product_id = $1);
+	`
+	var nested bool
+	err = tx.QueryRow(nestedStmt, bundleId).Scan(&nested)
+	if err != nil {
+		return err
+	}
+	if nested {
+		return errors.New("bundles cannot be nested inside other bundles")
+	}
+	return nil
+}
+
+// Reads bundle slots grouped by bundle ID, for one bundle or for the whole menu when productId is nil
+func (r *jsonMenuRepository) readBundleSlots(productId *int) (map[int][]models.BundleSlot, error) {
+	query := `
+	SELECT s.bundle_id, s.slot_id, s.name, s.quantity, COALESCE(array_agg(o.product_id ORDER BY o.product_id) FILTER (WHERE o.product_id IS NOT NULL), '{}')
+	FROM bundle_slots s
+	LEFT JOIN bundle_slot_options o ON s.slot_id = o.slot_id
+	WHERE ($1::INT IS NULL OR s.bundle_id = $1)
+	GROUP BY s.bundle_id, s.slot_id, s.name, s.quantity
+	ORDER BY s.bundle_id, s.slot_id;
+	`
+	rows, err := r.newDB.Db.Query(query, productId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	slots := make(map[int][]models.BundleSlot)
+	for rows.Next() {
+		var bundleId int
+		var slot models.BundleSlot
+		var options pq.Int64Array
+		err := rows.Scan(&bundleId, &slot.SlotID, &slot.Name, &slot.Quantity, &options)
+		if err != nil {
+			return nil, err
+		}
+		slot.Options = SqlDataBase.ToIntSlice(options)
+		slots[bundleId] = append(slots[bundleId], slot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
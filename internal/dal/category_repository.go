@@ -0,0 +1,222 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// CategoryRepository defines the methods for storing menu categories
+type CategoryRepository interface {
+	PostCategory(category models.Category) error
+	GetCategories() ([]models.Category, error)
+	GetCategoryID(id int) (models.Category, error)
+	GetCategoryByName(name string) (models.Category, error)
+	UpdateCategory(id int, category models.Category) error
+	DeleteCategory(id int) error
+	IsAncestor(ancestorID, id int) (bool, error)
+}
+
+type categoryRepository struct {
+	newDB *SqlDataBase.DB
+}
+
+// NewCategoryRepository creates and returns a new instance of categoryRepository
+func NewCategoryRepository(db *SqlDataBase.DB) CategoryRepository {
+	return &categoryRepository{newDB: db}
+}
+
+func (r *categoryRepository) PostCategory(category models.Category) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	stmt := `
+	INSERT INTO categories (name, parent_id, sort_order, active)
+	VALUES ($1, $2, $3, $4)
+	RETURNING category_id;
+	`
+	var categoryId int
+	err = tx.QueryRow(stmt, category.Name, category.ParentID, category.SortOrder, category.Active).Scan(&categoryId)
+	if err != nil {
+		err = categoryError(err)
+		return err
+	}
+	err = saveAvailabilityWindows(tx, "category_id", categoryId, category.Availability)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *categoryRepository) GetCategories() ([]models.Category, error) {
+	query := `
+	SELECT category_id, name, parent_id, sort_order, active
+	FROM categories
+	ORDER BY sort_order, name;
+	`
+	return r.readCategories(query)
+}
+
+func (r *categoryRepository) GetCategoryID(id int) (models.Category, error) {
+	query := `
+	SELECT category_id, name, parent_id, sort_order, active
+	FROM categories
+	WHERE category_id = $1;
+	`
+	categories, err := r.readCategories(query, id)
+	if err != nil {
+		return models.Category{}, err
+	}
+	if len(categories) == 0 {
+		return models.Category{}, fmt.Errorf("category with ID %d not found", id)
+	}
+	return categories[0], nil
+}
+
+// Looks a category up by name ignoring case and surrounding spaces
+func (r *categoryRepository) GetCategoryByName(name string) (models.Category, error) {
+	query := `
+	SELECT category_id, name, parent_id, sort_order, active
+	FROM categories
+	WHERE LOWER(name) = LOWER(TRIM($1));
+	`
+	categories, err := r.readCategories(query, name)
+	if err != nil {
+		return models.Category{}, err
+	}
+	if len(categories) == 0 {
+		return models.Category{}, fmt.Errorf("category %q not found", name)
+	}
+	return categories[0], nil
+}
+
+func (r *categoryRepository) UpdateCategory(id int, category models.Category) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	stmt := `
+	UPDATE categories
+	SET name = $1, parent_id = $2, sort_order = $3, active = $4
+	WHERE category_id = $5;
+	`
+	res, err := tx.Exec(stmt, category.Name, category.ParentID, category.SortOrder, category.Active, id)
+	if err != nil {
+		err = categoryError(err)
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		err = fmt.Errorf("category with ID %d not found", id)
+		return err
+	}
+	err = saveAvailabilityWindows(tx, "category_id", id, category.Availability)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *categoryRepository) DeleteCategory(id int) error {
+	res, err := r.newDB.Db.Exec(`DELETE FROM categories WHERE category_id = $1`, id)
+	if err != nil {
+		return categoryError(err)
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		return fmt.Errorf("category with ID %d not found", id)
+	}
+	return nil
+}
+
+// Reports whether ancestorID is id itself or one of its parents, which would make a cycle if set as its child
+func (r *categoryRepository) IsAncestor(ancestorID, id int) (bool, error) {
+	query := `
+	WITH RECURSIVE ancestors AS (
+		SELECT category_id, parent_id FROM categories WHERE category_id = $1
+		UNION ALL
+		SELECT c.category_id, c.parent_id
+		FROM categories c
+		JOIN ancestors a ON c.category_id = a.parent_id
+	)
+	SELECT EXISTS(SELECT 1 FROM ancestors WHERE category_id = $2);
+	`
+	var exists bool
+	err := r.newDB.Db.QueryRow(query, id, ancestorID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (r *categoryRepository) readCategories(query string, args ...any) ([]models.Category, error) {
+	rows, err := r.newDB.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	categories := []models.Category{}
+	for rows.Next() {
+		var category models.Category
+		var parentId sql.NullInt64
+		var active bool
+		err := rows.Scan(&category.CategoryID, &category.Name, &parentId, &category.SortOrder, &active)
+		if err != nil {
+			return nil, err
+		}
+		category.ParentID = nullableInt(parentId)
+		category.Active = &active
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	windows, err := readAvailabilityWindows(r.newDB.Db, "category_id", nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := range categories {
+		categories[i].Availability = windows[categories[i].CategoryID]
+	}
+	return categories, nil
+}
+
+// Translates constraint violations into readable errors
+func categoryError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return errors.New("category with this name already exists")
+		case "23503":
+			return errors.New("category is referenced by menu items or subcategories, or its parent does not exist")
+		}
+	}
+	return err
+}
@@ -0,0 +1,30 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"frapuccino/models"
+)
+
+// Returns the cost of goods of a menu item from its recipe and ingredient unit costs
+func (r *jsonMenuRepository) GetMenuItemCost(id int) (float64, error) {
+	var cost float64
+	err := r.newDB.Db.QueryRow(`SELECT cost FROM menu_item_costs WHERE product_id = $1`, id).Scan(&cost)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("menu item with ID %d not found", id)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return cost, nil
+}
+
+func (r *jsonMenuRepository) PostMarginAlert(alert models.MarginAlert) error {
+	stmt := `
+	INSERT INTO margin_alerts (product_id, old_price, new_price, cost, margin_percent, threshold)
+	VALUES ($1, $2, $3, $4, $5, $6);
+	`
+	_, err := r.newDB.Db.Exec(stmt, alert.ProductID, alert.OldPrice, alert.NewPrice, alert.Cost, alert.MarginPercent, alert.Threshold)
+	return err
+}
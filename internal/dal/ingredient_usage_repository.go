@@ -0,0 +1,145 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"frapuccino/models"
+)
+
+// Lists the recipes, variant overrides and modifier options that use an ingredient
+func (j *jsonInvRepository) GetUsages(id int) ([]models.IngredientUsage, error) {
+	query := `
+	SELECT 'recipe', mi.product_id, NULL::INT, NULL::INT, mi.name, mii.quantity, mii.unit
+	FROM menu_item_ingredients mii
+	JOIN menu_items mi ON mii.product_id = mi.product_id
+	WHERE mii.ingredient_id = $1
+	UNION ALL
+	SELECT 'variant', mi.product_id, v.variant_id, NULL, mi.name || ' (' || v.name || ')', vi.quantity, vi.unit
+	FROM menu_item_variant_ingredients vi
+	JOIN menu_item_variants v ON vi.variant_id = v.variant_id
+	JOIN menu_items mi ON v.product_id = mi.product_id
+	WHERE vi.ingredient_id = $1
+	UNION ALL
+	SELECT 'modifier', NULL, NULL, o.option_id, g.name || ': ' || o.name, moi.quantity, moi.unit
+	FROM modifier_option_ingredients moi
+	JOIN modifier_options o ON moi.option_id = o.option_id
+	JOIN modifier_groups g ON o.group_id = g.group_id
+	WHERE moi.ingredient_id = $1
+	ORDER BY 1, 5;
+	`
+	rows, err := j.newDB.Db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	usages := []models.IngredientUsage{}
+	for rows.Next() {
+		var usage models.IngredientUsage
+		var productId, variantId, optionId sql.NullInt64
+		err := rows.Scan(&usage.Source, &productId, &variantId, &optionId, &usage.Name, &usage.Quantity, &usage.Unit)
+		if err != nil {
+			return nil, err
+		}
+		usage.ProductID = nullableInt(productId)
+		usage.VariantID = nullableInt(variantId)
+		usage.OptionID = nullableInt(optionId)
+		usages = append(usages, usage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// Archives an ingredient no recipe uses, keeping its history, or restores it when archived is false
+func (j *jsonInvRepository) ArchiveItem(id int, archived bool) error {
+	if archived {
+		usages, err := j.GetUsages(id)
+		if err != nil {
+			return err
+		}
+		if len(usages) > 0 {
+			return ErrIngredientInUse
+		}
+	}
+	stmt := `
+	UPDATE inventory
+	SET archived_at = CASE WHEN $2 THEN COALESCE(archived_at, CURRENT_TIMESTAMP) ELSE NULL END
+	WHERE ingredient_id = $1;
+	`
+	res, err := j.newDB.Db.Exec(stmt, id, archived)
+	if err != nil {
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		return fmt.Errorf("inventory item with ID %d not found", id)
+	}
+	return nil
+}
+
+// Rewrites every recipe, variant override and modifier option to use the substitute instead of the ingredient,
+// adding to the substitute's quantity where it is already used, and archives the ingredient in the same transaction.
+// Recipes keep their units, so the substitute must be stocked in a unit of the same dimension
+func (j *jsonInvRepository) ReplaceItem(id int, replacement models.IngredientReplacement) error {
+	tx, err := j.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	stmts := []string{
+		`
+	INSERT INTO menu_item_ingredients (product_id, ingredient_id, quantity, unit)
+	SELECT product_id, $2, quantity * $3, unit FROM menu_item_ingredients WHERE ingredient_id = $1
+	ON CONFLICT (product_id, ingredient_id) DO UPDATE
+	SET quantity = menu_item_ingredients.quantity + convert_unit(EXCLUDED.quantity, EXCLUDED.unit, menu_item_ingredients.unit);
+	`,
+		`
+	INSERT INTO menu_item_variant_ingredients (variant_id, ingredient_id, quantity, unit)
+	SELECT variant_id, $2, quantity * $3, unit FROM menu_item_variant_ingredients WHERE ingredient_id = $1
+	ON CONFLICT (variant_id, ingredient_id) DO UPDATE
+	SET quantity = menu_item_variant_ingredients.quantity + convert_unit(EXCLUDED.quantity, EXCLUDED.unit, menu_item_variant_ingredients.unit);
+	`,
+		`
+	INSERT INTO modifier_option_ingredients (option_id, ingredient_id, quantity, unit)
+	SELECT option_id, $2, quantity * $3, unit FROM modifier_option_ingredients WHERE ingredient_id = $1
+	ON CONFLICT (option_id, ingredient_id) DO UPDATE
+	SET quantity = modifier_option_ingredients.quantity + convert_unit(EXCLUDED.quantity, EXCLUDED.unit, modifier_option_ingredients.unit);
+	`,
+	}
+	for _, stmt := range stmts {
+		_, err = tx.Exec(stmt, id, replacement.SubstituteID, replacement.Ratio)
+		if err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"menu_item_ingredients", "menu_item_variant_ingredients", "modifier_option_ingredients"} {
+		_, err = tx.Exec(`DELETE FROM `+table+` WHERE ingredient_id = $1`, id)
+		if err != nil {
+			return err
+		}
+	}
+	res, err := tx.Exec(`UPDATE inventory SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP) WHERE ingredient_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		err = fmt.Errorf("inventory item with ID %d not found", id)
+		return err
+	}
+	return nil
+}
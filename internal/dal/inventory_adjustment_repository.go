@@ -0,0 +1,137 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"frapuccino/models"
+)
+
+// Applies a signed change to an ingredient's stock in one statement, so it adds up with concurrent order deductions,
+// and records it in the ledger under the adjustment reason; waste also goes into the waste log. Returns the new stock level
+func (j *jsonInvRepository) AdjustItem(id int, adjustment models.InventoryAdjustment) (float64, error) {
+	tx, err := j.newDB.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	_, err = tx.Exec(`SELECT set_inventory_movement($1, NULL)`, adjustment.Reason)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`SELECT set_inventory_lot($1)`, adjustment.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	err = lockUnreserved(tx, id, -adjustment.Delta)
+	if err != nil {
+		return 0, err
+	}
+	var quantity, price float64
+	err = tx.QueryRow(`
+	UPDATE inventory
+	SET quantity = quantity + $2
+	WHERE ingredient_id = $1 AND quantity + $2 >= 0
+	RETURNING quantity, price;
+	`, id, adjustment.Delta).Scan(&quantity, &price)
+	if err == sql.ErrNoRows {
+		err = j.missingOr(id, ErrNegativeStock)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if adjustment.Reason == "waste" {
+		err = logIngredientWaste(tx, id, -adjustment.Delta, "other", "stock adjustment", price)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return quantity, nil
+}
+
+// Sets an ingredient's stock to a counted level, recording the variance against the level it replaced
+func (j *jsonInvRepository) CountItem(id int, counted float64) (models.StockCount, error) {
+	count := models.StockCount{IngredientID: id, CountedQuantity: counted}
+	tx, err := j.newDB.Db.Begin()
+	if err != nil {
+		return count, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = tx.QueryRow(`SELECT quantity FROM inventory WHERE ingredient_id = $1 FOR UPDATE`, id).Scan(&count.ExpectedQuantity)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("inventory item with ID %d not found", id)
+	}
+	if err != nil {
+		return count, err
+	}
+	err = lockUnreserved(tx, id, count.ExpectedQuantity-counted)
+	if err != nil {
+		return count, err
+	}
+	_, err = tx.Exec(`SELECT set_inventory_movement($1, NULL)`, "stocktake")
+	if err != nil {
+		return count, err
+	}
+	_, err = tx.Exec(`UPDATE inventory SET quantity = $2 WHERE ingredient_id = $1`, id, counted)
+	if err != nil {
+		return count, err
+	}
+	err = tx.QueryRow(`
+	INSERT INTO stock_counts (ingredient_id, expected_quantity, counted_quantity, unit_cost)
+	SELECT $1, $2, $3, price FROM inventory WHERE ingredient_id = $1
+	RETURNING count_id, variance, created_at;
+	`, id, count.ExpectedQuantity, counted).Scan(&count.CountID, &count.Variance, &count.CreatedAt)
+	return count, err
+}
+
+// Locks an ingredient's stock and rejects taking quantity from it when that would leave less than open orders
+// have reserved. The reservations are read after the lock, so orders placed meanwhile are counted.
+func lockUnreserved(tx *sql.Tx, id int, quantity float64) error {
+	var onHand float64
+	err := tx.QueryRow(`SELECT quantity FROM inventory WHERE ingredient_id = $1 FOR UPDATE`, id).Scan(&onHand)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("inventory item with ID %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	if quantity <= 0 {
+		return nil
+	}
+	if onHand < quantity {
+		return ErrNegativeStock
+	}
+	var reserved float64
+	err = tx.QueryRow(`SELECT reserved FROM inventory_stock WHERE ingredient_id = $1`, id).Scan(&reserved)
+	if err != nil {
+		return err
+	}
+	if onHand-quantity < reserved {
+		return ErrReservedStock
+	}
+	return nil
+}
+
+// Tells a missing ingredient apart from a statement that matched nothing for another reason
+func (j *jsonInvRepository) missingOr(id int, otherwise error) error {
+	exists, err := j.CheckIfExists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("inventory item with ID %d not found", id)
+	}
+	return otherwise
+}
@@ -0,0 +1,55 @@
+package dal
+
+import (
+	"database/sql"
+
+	"frapuccino/models"
+)
+
+// Lists the active ingredients at or below their reorder point with the time each last crossed it, lowest stock
+// relative to the reorder point first
+func (j *jsonInvRepository) GetLowStock() ([]models.LowStockItem, error) {
+	query := `
+	SELECT
+		i.ingredient_id,
+		i.name,
+		i.quantity,
+		i.unit,
+		i.reorder_point,
+		i.par_level,
+		(SELECT MAX(a.created_at) FROM stock_alerts a WHERE a.ingredient_id = i.ingredient_id)
+	FROM inventory i
+	WHERE i.archived_at IS NULL AND i.quantity <= i.reorder_point
+	ORDER BY i.quantity / NULLIF(i.reorder_point, 0) NULLS FIRST, i.name;
+	`
+	rows, err := j.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.LowStockItem{}
+	for rows.Next() {
+		var item models.LowStockItem
+		var parLevel sql.NullFloat64
+		var alertedAt sql.NullString
+		err := rows.Scan(
+			&item.IngredientID,
+			&item.Name,
+			&item.Quantity,
+			&item.Unit,
+			&item.ReorderPoint,
+			&parLevel,
+			&alertedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.ParLevel = nullableFloat(parLevel)
+		item.AlertedAt = nullableString(alertedAt)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,137 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"frapuccino/models"
+)
+
+// Lists the lots of an ingredient, oldest first; used up and expired lots are only included when asked for
+func (j *jsonInvRepository) GetLots(id int, includeClosed bool) ([]models.InventoryLot, error) {
+	query := `
+	SELECT l.lot_id, l.ingredient_id, i.name, i.unit, l.quantity, l.remaining, l.unit_cost, l.received_at, l.expires_at, l.expired_at
+	FROM inventory_lots l
+	JOIN inventory i ON l.ingredient_id = i.ingredient_id
+	WHERE l.ingredient_id = $1 AND ($2 OR l.remaining > 0)
+	ORDER BY l.received_at, l.lot_id;
+	`
+	return j.readLots(query, id, includeClosed)
+}
+
+// Lists the lots with stock left that expire within the given number of days, including expired ones not yet
+// written off, soonest first
+func (j *jsonInvRepository) GetExpiringLots(days int) ([]models.InventoryLot, error) {
+	query := `
+	SELECT l.lot_id, l.ingredient_id, i.name, i.unit, l.quantity, l.remaining, l.unit_cost, l.received_at, l.expires_at, l.expired_at
+	FROM inventory_lots l
+	JOIN inventory i ON l.ingredient_id = i.ingredient_id
+	WHERE l.remaining > 0 AND l.expires_at <= CURRENT_DATE + $1::INT AND i.archived_at IS NULL
+	ORDER BY l.expires_at, i.name, l.lot_id;
+	`
+	return j.readLots(query, days)
+}
+
+// Writes off what is left of every lot past its expiry date: the stock goes down through the ledger under 'expired',
+// the lot is closed and the loss is recorded in the waste log. Returns the lots written off
+func (j *jsonInvRepository) ExpireLots() ([]models.InventoryLot, error) {
+	tx, err := j.newDB.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	rows, err := tx.Query(`
+	SELECT l.lot_id, l.ingredient_id, i.name, i.unit, l.quantity, l.remaining, l.unit_cost, l.received_at, l.expires_at, l.expired_at
+	FROM inventory_lots l
+	JOIN inventory i ON l.ingredient_id = i.ingredient_id
+	WHERE l.remaining > 0 AND l.expires_at < CURRENT_DATE
+	ORDER BY l.ingredient_id, l.lot_id
+	FOR UPDATE OF l;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var lots []models.InventoryLot
+	lots, err = scanLots(rows)
+	if err != nil || len(lots) == 0 {
+		return lots, err
+	}
+	_, err = tx.Exec(`SELECT set_inventory_movement($1, NULL)`, "expired")
+	if err != nil {
+		return nil, err
+	}
+	// The lot is closed here, so the lot trigger must not consume other lots for the stock that goes
+	_, err = tx.Exec(`SELECT set_config('inventory.lot_sync', 'off', true)`)
+	if err != nil {
+		return nil, err
+	}
+	for _, lot := range lots {
+		_, err = tx.Exec(`
+		UPDATE inventory SET quantity = GREATEST(quantity - $2, 0) WHERE ingredient_id = $1;
+		`, lot.IngredientID, lot.Remaining)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`
+		UPDATE inventory_lots SET remaining = 0, expired_at = CURRENT_TIMESTAMP WHERE lot_id = $1;
+		`, lot.LotID)
+		if err != nil {
+			return nil, err
+		}
+		err = logIngredientWaste(tx, lot.IngredientID, lot.Remaining, "expired", fmt.Sprintf("lot %d", lot.LotID), lot.UnitCost)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(`SELECT set_config('inventory.lot_sync', '', true)`)
+	if err != nil {
+		return nil, err
+	}
+	return lots, nil
+}
+
+func (j *jsonInvRepository) readLots(query string, args ...any) ([]models.InventoryLot, error) {
+	rows, err := j.newDB.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanLots(rows)
+}
+
+func scanLots(rows *sql.Rows) ([]models.InventoryLot, error) {
+	defer rows.Close()
+	lots := []models.InventoryLot{}
+	for rows.Next() {
+		var lot models.InventoryLot
+		var expiresAt, expiredAt sql.NullString
+		err := rows.Scan(
+			&lot.LotID,
+			&lot.IngredientID,
+			&lot.Name,
+			&lot.Unit,
+			&lot.Quantity,
+			&lot.Remaining,
+			&lot.UnitCost,
+			&lot.ReceivedAt,
+			&expiresAt,
+			&expiredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lot.ExpiresAt = nullableString(expiresAt)
+		lot.ExpiredAt = nullableString(expiredAt)
+		lot.Value = lot.Remaining * lot.UnitCost
+		lots = append(lots, lot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return lots, nil
+}
@@ -1,8 +1,13 @@
 package dal
 
 import (
+	"database/sql"
+	"errors"
+
 	"frapuccino/internal/dal/SqlDataBase"
 	"frapuccino/models"
+
+	"github.com/lib/pq"
 )
 
 // InventoryRepository defines the methods for reading and writing inventory data.
@@ -14,8 +19,30 @@ type InventoryRepository interface {
 	DeleteItem(int) error
 	CheckIfExists(ingredientID int) (bool, error)
 	CheckIfNameExists(name string) (bool, error)
+	GetUsages(id int) ([]models.IngredientUsage, error)
+	ArchiveItem(id int, archived bool) error
+	ReplaceItem(id int, replacement models.IngredientReplacement) error
+	GetTransactions(id int, from, to *string) ([]models.InventoryTransaction, error)
+	GetLevelAt(id int, at *string) (float64, error)
+	AdjustItem(id int, adjustment models.InventoryAdjustment) (float64, error)
+	CountItem(id int, counted float64) (models.StockCount, error)
+	GetLowStock() ([]models.LowStockItem, error)
+	GetLots(id int, includeClosed bool) ([]models.InventoryLot, error)
+	GetExpiringLots(days int) ([]models.InventoryLot, error)
+	ExpireLots() ([]models.InventoryLot, error)
+	LogWaste(entry models.WasteEntry) (models.WasteEntry, error)
+	GetWaste(from, to *string) ([]models.WasteEntry, error)
 }
 
+// Returned when deleting or archiving an ingredient that recipes, variants or modifiers still use
+var ErrIngredientInUse = errors.New("ingredient is used by recipes, replace it with a substitute first")
+
+// Returned when an adjustment would take more stock than there is
+var ErrNegativeStock = errors.New("adjustment would make the stock negative")
+
+// Returned when taking stock would leave less than open orders have reserved
+var ErrReservedStock = errors.New("stock is reserved by open orders")
+
 // jsonInvRepository implements the InventoryRepository interface using JSON file storage.
 type jsonInvRepository struct {
 	newDB *SqlDataBase.DB
@@ -27,7 +54,10 @@ func NewJSONInvRepository(db *SqlDataBase.DB) InventoryRepository {
 }
 
 func (j *jsonInvRepository) ReadJSONInv() ([]models.InventoryItem, error) {
-	rows, err := j.newDB.Db.Query(`SELECT ingredient_id, name, quantity, unit FROM inventory`)
+	rows, err := j.newDB.Db.Query(`
+	SELECT i.ingredient_id, i.name, i.quantity, s.reserved, s.available, i.unit, i.price, i.allergens, i.kcal, i.sugar, i.fat, i.caffeine, i.reorder_point, i.par_level, i.consumption, i.archived_at
+	FROM inventory i
+	JOIN inventory_stock s ON i.ingredient_id = s.ingredient_id`)
 	if err != nil {
 		return nil, err
 	}
@@ -36,10 +66,32 @@ func (j *jsonInvRepository) ReadJSONInv() ([]models.InventoryItem, error) {
 	var items []models.InventoryItem
 	for rows.Next() {
 		var item models.InventoryItem
-		err := rows.Scan(&item.IngredientID, &item.Name, &item.Quantity, &item.Unit)
+		var archivedAt sql.NullString
+		var reorderPoint, parLevel sql.NullFloat64
+		err := rows.Scan(
+			&item.IngredientID,
+			&item.Name,
+			&item.Quantity,
+			&item.Reserved,
+			&item.Available,
+			&item.Unit,
+			&item.Price,
+			pq.Array(&item.Allergens),
+			&item.Nutrition.Kcal,
+			&item.Nutrition.Sugar,
+			&item.Nutrition.Fat,
+			&item.Nutrition.Caffeine,
+			&reorderPoint,
+			&parLevel,
+			&item.Consumption,
+			&archivedAt,
+		)
 		if err != nil {
 			return nil, err
 		}
+		item.ReorderPoint = nullableFloat(reorderPoint)
+		item.ParLevel = nullableFloat(parLevel)
+		item.ArchivedAt = nullableString(archivedAt)
 		items = append(items, item)
 	}
 
@@ -60,9 +112,12 @@ func (r *jsonInvRepository) WriteJSONInv(newInventory []models.InventoryItem) er
 		}
 	}()
 
-	query := `INSERT INTO inventory (name, quantity, unit) VALUES ($1, $2, $3)`
+	query := `
+	INSERT INTO inventory (name, quantity, unit, price, allergens, kcal, sugar, fat, caffeine, reorder_point, par_level, consumption)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE(NULLIF($12, ''), 'fifo'))`
 	for _, item := range newInventory {
-		_, err := r.newDB.Db.Exec(query, item.Name, item.Quantity, item.Unit)
+		_, err := r.newDB.Db.Exec(query, item.Name, item.Quantity, item.Unit, item.Price, pq.Array(allergensOrEmpty(item.Allergens)),
+			item.Nutrition.Kcal, item.Nutrition.Sugar, item.Nutrition.Fat, item.Nutrition.Caffeine, item.ReorderPoint, item.ParLevel, item.Consumption)
 		if err != nil {
 			return err
 		}
@@ -84,8 +139,11 @@ func (j *jsonInvRepository) AddItems(item models.InventoryItem) error {
 		}
 	}()
 
-	query := `INSERT INTO inventory (name, quantity, unit) VALUES ($1, $2, $3)`
-	_, err1 := tx.Exec(query, item.Name, item.Quantity, item.Unit)
+	query := `
+	INSERT INTO inventory (name, quantity, unit, price, allergens, kcal, sugar, fat, caffeine, reorder_point, par_level, consumption)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE(NULLIF($12, ''), 'fifo'))`
+	_, err1 := tx.Exec(query, item.Name, item.Quantity, item.Unit, item.Price, pq.Array(allergensOrEmpty(item.Allergens)),
+		item.Nutrition.Kcal, item.Nutrition.Sugar, item.Nutrition.Fat, item.Nutrition.Caffeine, item.ReorderPoint, item.ParLevel, item.Consumption)
 	if err1 != nil {
 		return err
 	}
@@ -106,10 +164,22 @@ func (j *jsonInvRepository) UpdateItem(id int, item models.InventoryItem) error
 		}
 	}()
 
-	query := `UPDATE inventory SET name = $1, quantity = $2, unit = $3 WHERE ingredient_id = $4`
-	_, err1 := j.newDB.Db.Exec(query, item.Name, item.Quantity, item.Unit, id)
-	if err1 != nil {
-		return err1
+	// The quantity is only converted when the unit changes, which converts the ledger instead of logging a movement;
+	// otherwise it changes through adjustments and stock counts so concurrent order deductions are not lost. The other
+	// per-unit values are given in the current unit and are converted along with it: price and nutrition per unit,
+	// reorder point and par level as quantities
+	query := `
+	UPDATE inventory
+	SET name = $1, quantity = convert_unit(quantity, unit, $2), unit = $2,
+		price = $3 / convert_unit(1, unit, $2), allergens = $4,
+		kcal = $5 / convert_unit(1, unit, $2), sugar = $6 / convert_unit(1, unit, $2),
+		fat = $7 / convert_unit(1, unit, $2), caffeine = $8 / convert_unit(1, unit, $2),
+		reorder_point = convert_unit($9, unit, $2), par_level = convert_unit($10, unit, $2), consumption = $11
+	WHERE ingredient_id = $12`
+	_, err = tx.Exec(query, item.Name, item.Unit, item.Price, pq.Array(allergensOrEmpty(item.Allergens)),
+		item.Nutrition.Kcal, item.Nutrition.Sugar, item.Nutrition.Fat, item.Nutrition.Caffeine, item.ReorderPoint, item.ParLevel, item.Consumption, id)
+	if err != nil {
+		return err
 	}
 	return nil
 }
@@ -128,9 +198,13 @@ func (j *jsonInvRepository) DeleteItem(id int) error {
 		}
 	}()
 	query := `DELETE FROM inventory WHERE ingredient_id = $1`
-	_, err1 := j.newDB.Db.Exec(query, id)
-	if err1 != nil {
-		return err1
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			err = ErrIngredientInUse
+		}
+		return err
 	}
 	return nil
 }
@@ -154,3 +228,11 @@ func (j *jsonInvRepository) CheckIfNameExists(name string) (bool, error) {
 	}
 	return exists, nil
 }
+
+// The allergens column is NOT NULL, so an ingredient without allergens is stored as an empty array
+func allergensOrEmpty(allergens []string) []string {
+	if allergens == nil {
+		return []string{}
+	}
+	return allergens
+}
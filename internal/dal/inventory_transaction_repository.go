@@ -0,0 +1,58 @@
+package dal
+
+import (
+	"database/sql"
+
+	"frapuccino/models"
+)
+
+// Reads the ledger movements of an ingredient between from and to, either of which may be open
+func (j *jsonInvRepository) GetTransactions(id int, from, to *string) ([]models.InventoryTransaction, error) {
+	query := `
+	SELECT transaction_id, ingredient_id, quantity_change, quantity_after, COALESCE(reason, ''), order_id, created_at
+	FROM inventory_transactions
+	WHERE ingredient_id = $1
+		AND ($2::TIMESTAMP IS NULL OR created_at >= $2)
+		AND ($3::TIMESTAMP IS NULL OR created_at <= $3)
+	ORDER BY created_at, transaction_id;
+	`
+	rows, err := j.newDB.Db.Query(query, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transactions := []models.InventoryTransaction{}
+	for rows.Next() {
+		var transaction models.InventoryTransaction
+		var quantityAfter sql.NullFloat64
+		var orderId sql.NullInt64
+		err := rows.Scan(
+			&transaction.TransactionID,
+			&transaction.IngredientID,
+			&transaction.QuantityChange,
+			&quantityAfter,
+			&transaction.Reason,
+			&orderId,
+			&transaction.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if quantityAfter.Valid {
+			transaction.QuantityAfter = &quantityAfter.Float64
+		}
+		transaction.OrderID = nullableInt(orderId)
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// Reconstructs the stock level of an ingredient at a past local time, or returns the current level when at is nil
+func (j *jsonInvRepository) GetLevelAt(id int, at *string) (float64, error) {
+	var level float64
+	err := j.newDB.Db.QueryRow(`SELECT inventory_level_at($1, COALESCE($2::TIMESTAMP, LOCALTIMESTAMP))`, id, at).Scan(&level)
+	return level, err
+}
@@ -0,0 +1,216 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// Deducts wasted stock through the ledger under 'waste' and records it in the waste log valued at the current cost.
+// A menu item deducts its whole recipe, or its variant's, for every serving
+func (j *jsonInvRepository) LogWaste(entry models.WasteEntry) (models.WasteEntry, error) {
+	tx, err := j.newDB.Db.Begin()
+	if err != nil {
+		return entry, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	var lines []models.WasteLine
+	if entry.MenuItemID != nil {
+		entry.Name, lines, err = wastedRecipe(tx, *entry.MenuItemID, entry.VariantID, entry.Quantity)
+	} else {
+		err = tx.QueryRow(`SELECT name FROM inventory WHERE ingredient_id = $1`, *entry.IngredientID).Scan(&entry.Name)
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("inventory item with ID %d not found", *entry.IngredientID)
+		}
+		lines = []models.WasteLine{{IngredientID: *entry.IngredientID, Quantity: entry.Quantity}}
+	}
+	if err != nil {
+		return entry, err
+	}
+	_, err = tx.Exec(`SELECT set_inventory_movement($1, NULL)`, "waste")
+	if err != nil {
+		return entry, err
+	}
+	err = tx.QueryRow(`
+	INSERT INTO waste_log (ingredient_id, product_id, variant_id, name, quantity, reason, note)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING waste_id, created_at;
+	`, entry.IngredientID, entry.MenuItemID, entry.VariantID, entry.Name, entry.Quantity, entry.Reason, entry.Note).Scan(&entry.WasteID, &entry.CreatedAt)
+	if err != nil {
+		return entry, err
+	}
+	entry.Lines = []models.WasteLine{}
+	entry.Cost = 0
+	for _, line := range lines {
+		err = lockUnreserved(tx, line.IngredientID, line.Quantity)
+		if err != nil {
+			return entry, err
+		}
+		err = tx.QueryRow(`
+		UPDATE inventory
+		SET quantity = quantity - $2
+		WHERE ingredient_id = $1 AND quantity >= $2
+		RETURNING name, unit, price;
+		`, line.IngredientID, line.Quantity).Scan(&line.Name, &line.Unit, &line.UnitCost)
+		if err == sql.ErrNoRows {
+			err = j.missingOr(line.IngredientID, ErrNegativeStock)
+		}
+		if err != nil {
+			return entry, err
+		}
+		err = insertWasteLine(tx, entry.WasteID, line)
+		if err != nil {
+			return entry, err
+		}
+		line.Cost = line.Quantity * line.UnitCost
+		entry.Cost += line.Cost
+		entry.Lines = append(entry.Lines, line)
+	}
+	return entry, nil
+}
+
+// Lists the waste log between optional from and to times, newest first
+func (j *jsonInvRepository) GetWaste(from, to *string) ([]models.WasteEntry, error) {
+	rows, err := j.newDB.Db.Query(`
+	SELECT waste_id, ingredient_id, product_id, variant_id, name, quantity, reason, note, created_at
+	FROM waste_log
+	WHERE ($1::TIMESTAMP IS NULL OR created_at >= $1)
+		AND ($2::TIMESTAMP IS NULL OR created_at <= $2)
+	ORDER BY created_at DESC, waste_id DESC;
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []models.WasteEntry{}
+	index := make(map[int]int)
+	ids := []int64{}
+	for rows.Next() {
+		entry := models.WasteEntry{Lines: []models.WasteLine{}}
+		var ingredientId, productId, variantId sql.NullInt64
+		err := rows.Scan(&entry.WasteID, &ingredientId, &productId, &variantId, &entry.Name, &entry.Quantity, &entry.Reason, &entry.Note, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.IngredientID = nullableInt(ingredientId)
+		entry.MenuItemID = nullableInt(productId)
+		entry.VariantID = nullableInt(variantId)
+		index[entry.WasteID] = len(entries)
+		ids = append(ids, int64(entry.WasteID))
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	lineRows, err := j.newDB.Db.Query(`
+	SELECT waste_id, ingredient_id, name, unit, quantity, unit_cost
+	FROM waste_lines
+	WHERE waste_id = ANY($1)
+	ORDER BY name;
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer lineRows.Close()
+	for lineRows.Next() {
+		var wasteId int
+		var line models.WasteLine
+		var ingredientId sql.NullInt64
+		err := lineRows.Scan(&wasteId, &ingredientId, &line.Name, &line.Unit, &line.Quantity, &line.UnitCost)
+		if err != nil {
+			return nil, err
+		}
+		line.IngredientID = int(ingredientId.Int64)
+		line.Cost = line.Quantity * line.UnitCost
+		i := index[wasteId]
+		entries[i].Lines = append(entries[i].Lines, line)
+		entries[i].Cost += line.Cost
+	}
+	if err := lineRows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Reads the name of a menu item and the stock its recipe, or its variant's, takes for the wasted servings
+func wastedRecipe(tx *sql.Tx, productId int, variantId *int, servings float64) (string, []models.WasteLine, error) {
+	var name string
+	err := tx.QueryRow(`SELECT name FROM menu_items WHERE product_id = $1`, productId).Scan(&name)
+	if err == sql.ErrNoRows {
+		return name, nil, fmt.Errorf("menu item with ID %d not found", productId)
+	}
+	if err != nil {
+		return name, nil, err
+	}
+	if variantId != nil {
+		var variant string
+		err = tx.QueryRow(`SELECT name FROM menu_item_variants WHERE variant_id = $1 AND product_id = $2`, *variantId, productId).Scan(&variant)
+		if err == sql.ErrNoRows {
+			return name, nil, fmt.Errorf("variant with ID %d not found for menu item %d", *variantId, productId)
+		}
+		if err != nil {
+			return name, nil, err
+		}
+		name = fmt.Sprintf("%s (%s)", name, variant)
+	}
+	rows, err := tx.Query(`
+	SELECT ingredient_id, SUM(quantity) * $3
+	FROM recipe_for($1, $2)
+	GROUP BY ingredient_id
+	ORDER BY ingredient_id;
+	`, productId, variantId, servings)
+	if err != nil {
+		return name, nil, err
+	}
+	defer rows.Close()
+	var lines []models.WasteLine
+	for rows.Next() {
+		var line models.WasteLine
+		if err := rows.Scan(&line.IngredientID, &line.Quantity); err != nil {
+			return name, nil, err
+		}
+		if line.Quantity > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return name, nil, err
+	}
+	if len(lines) == 0 {
+		return name, nil, errors.New("menu item has no recipe to deduct")
+	}
+	return name, lines, nil
+}
+
+// Records waste of one ingredient that has already been deducted, valued at the given unit cost
+func logIngredientWaste(tx *sql.Tx, ingredientId int, quantity float64, reason, note string, unitCost float64) error {
+	var wasteId int
+	err := tx.QueryRow(`
+	INSERT INTO waste_log (ingredient_id, name, quantity, reason, note)
+	SELECT ingredient_id, name, $2, $3, $4 FROM inventory WHERE ingredient_id = $1
+	RETURNING waste_id;
+	`, ingredientId, quantity, reason, note).Scan(&wasteId)
+	if err != nil {
+		return err
+	}
+	return insertWasteLine(tx, wasteId, models.WasteLine{IngredientID: ingredientId, Quantity: quantity, UnitCost: unitCost})
+}
+
+// Records a wasted ingredient with its name and unit, so the line outlives the ingredient
+func insertWasteLine(tx *sql.Tx, wasteId int, line models.WasteLine) error {
+	_, err := tx.Exec(`
+	INSERT INTO waste_lines (waste_id, ingredient_id, name, unit, quantity, unit_cost)
+	SELECT $1, ingredient_id, name, unit, $3, $4 FROM inventory WHERE ingredient_id = $2;
+	`, wasteId, line.IngredientID, line.Quantity, line.UnitCost)
+	return err
+}
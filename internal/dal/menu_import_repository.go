@@ -0,0 +1,111 @@
+package dal
+
+import (
+	"database/sql"
+	"strings"
+
+	"frapuccino/models"
+)
+
+// Reads the names of active inventory ingredients by ID, which menu import and export use to reference recipes
+func (r *jsonMenuRepository) GetIngredientNames() (map[int]string, error) {
+	rows, err := r.newDB.Db.Query(`SELECT ingredient_id, name FROM inventory WHERE archived_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Writes imported menu items in one transaction: items with an ID update that item's fields and recipe, leaving its
+// variants, modifiers, availability and bundle slots alone, items without one are created, and categories given only
+// by name are created when missing
+func (r *jsonMenuRepository) ImportMenu(items []models.MenuItem) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	for _, item := range items {
+		categoryId := item.CategoryID
+		if categoryId == nil && strings.TrimSpace(item.Category) != "" {
+			categoryId, err = importCategory(tx, item.Category)
+			if err != nil {
+				return err
+			}
+		}
+		productId := item.ID
+		if productId == 0 {
+			err = tx.QueryRow(`
+			INSERT INTO menu_items (name, description, price, category_id, prep_time, station)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING product_id;
+			`, item.Name, item.Description, item.Price, categoryId, item.PrepTime, item.Station).Scan(&productId)
+		} else {
+			_, err = tx.Exec(`
+			UPDATE menu_items
+			SET name = $1, description = $2, price = $3, category_id = $4, prep_time = $5, station = $6
+			WHERE product_id = $7;
+			`, item.Name, item.Description, item.Price, categoryId, item.PrepTime, item.Station, productId)
+		}
+		if err != nil {
+			return err
+		}
+		err = saveIngredients(tx, productId, item.Ingredients)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finds a category by name ignoring case, creating it at the top level when it does not exist yet
+func importCategory(tx *sql.Tx, name string) (*int, error) {
+	var categoryId int
+	err := tx.QueryRow(`SELECT category_id FROM categories WHERE LOWER(name) = LOWER(TRIM($1))`, name).Scan(&categoryId)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`INSERT INTO categories (name) VALUES (TRIM($1)) RETURNING category_id`, name).Scan(&categoryId)
+	}
+	if err != nil {
+		return nil, categoryError(err)
+	}
+	return &categoryId, nil
+}
+
+// Replaces the base recipe of a menu item
+func saveIngredients(tx *sql.Tx, productId int, ingredients []models.MenuItemIngredient) error {
+	_, err := tx.Exec(`DELETE FROM menu_item_ingredients WHERE product_id = $1`, productId)
+	if err != nil {
+		return err
+	}
+	stmt := `
+	INSERT INTO menu_item_ingredients (product_id, ingredient_id, quantity, unit)
+	VALUES ($1, $2, $3, NULLIF($4, ''));
+	`
+	for _, ingredient := range ingredients {
+		_, err = tx.Exec(stmt, productId, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -12,13 +12,33 @@ import (
 	"github.com/lib/pq"
 )
 
+// Returned when deleting a menu item that past orders or bundles still reference
+var ErrMenuItemInUse = errors.New("menu item is referenced by orders or bundles, archive it instead")
+
+// Returned when changing the price of a menu item that is archived or no longer exists
+var ErrMenuItemNotOnMenu = errors.New("menu item is not on the menu")
+
 type (
 	MenuRepository interface {
 		PostRepoMenu(content models.MenuItem) error
 		UpdateMenu(id int, content models.MenuItem) error
 		DeleteMenuItem(id int) error
+		ArchiveMenuItem(id int, archived bool) error
 		GetMenuRepo() ([]models.MenuItem, error)
 		GetMenuItemID(id int) (models.MenuItem, error)
+		GetPriceHistory(id int) ([]models.PriceHistory, error)
+		PostScheduledPrice(change models.ScheduledPrice) (int, error)
+		GetScheduledPrices(productId int) ([]models.ScheduledPrice, error)
+		GetDuePriceChanges() ([]models.ScheduledPrice, error)
+		DeleteScheduledPrice(productId, scheduleId int) error
+		MarkScheduledPrice(scheduleId int, applyErr error, maxAttempts int) error
+		UpdateMenuPrice(productId int, price float64) (float64, error)
+		GetMenuAvailability() ([]models.MenuAvailability, error)
+		GetMenuItemCost(id int) (float64, error)
+		PostMarginAlert(alert models.MarginAlert) error
+		GetProductsAvailableAt(at *string) (map[int]bool, error)
+		GetIngredientNames() (map[int]string, error)
+		ImportMenu(items []models.MenuItem) error
 	}
 	jsonMenuRepository struct {
 		newDB *SqlDataBase.DB
@@ -42,28 +62,8 @@ func (m *jsonMenuRepository) PostRepoMenu(content models.MenuItem) error {
 			err = tx.Commit()
 		}
 	}()
-	productId := 0
-	stmt := `
-	INSERT INTO menu_items (name, description, price, category, allergens)
-	VALUES ($1, $2, $3, $4, $5)
-	RETURNING product_id;
-	`
-	row := tx.QueryRow(stmt, content.Name, content.Description, content.Price, content.Category, pq.Array(content.Allergens))
-	err = row.Scan(&productId)
-	if err != nil {
-		return err
-	}
-	ingredientStmt := `
-INSERT INTO menu_item_ingredients (product_id, ingredient_id, quantity)
-VALUES ($1, $2, $3)
-`
-	for _, ingredient := range content.Ingredients {
-		_, err := tx.Exec(ingredientStmt, productId, ingredient.IngredientID, ingredient.Quantity)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	_, err = insertMenuItem(tx, content)
+	return err
 }
 
 func (m *jsonMenuRepository) UpdateMenu(id int, content models.MenuItem) error {
@@ -81,11 +81,47 @@ func (m *jsonMenuRepository) UpdateMenu(id int, content models.MenuItem) error {
 			err = tx.Commit()
 		}
 	}()
+	err = updateMenuItem(tx, id, content)
+	return err
+}
+
+// Inserts a menu item with its recipe, variants, modifier groups, availability windows and bundle slots
+func insertMenuItem(tx *sql.Tx, content models.MenuItem) (int, error) {
+	productId := 0
+	stmt := `
+	INSERT INTO menu_items (name, description, price, category_id, prep_time, station)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING product_id;
+	`
+	row := tx.QueryRow(stmt, content.Name, content.Description, content.Price, content.CategoryID, content.PrepTime, content.Station)
+	err := row.Scan(&productId)
+	if err != nil {
+		return 0, err
+	}
+	ingredientStmt := `
+INSERT INTO menu_item_ingredients (product_id, ingredient_id, quantity, unit)
+VALUES ($1, $2, $3, NULLIF($4, ''))
+`
+	for _, ingredient := range content.Ingredients {
+		_, err := tx.Exec(ingredientStmt, productId, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = saveMenuItemDetails(tx, productId, content)
+	if err != nil {
+		return 0, err
+	}
+	return productId, nil
+}
+
+// Rewrites a menu item with its recipe, variants, modifier groups, availability windows and bundle slots
+func updateMenuItem(tx *sql.Tx, id int, content models.MenuItem) error {
 	deleteMenuQuery := `
 UPDATE menu_items
-SET name = $1,    description = $2, price = $3, category = $4, allergens = $5
-WHERE product_id = $6`
-	_, err = tx.Exec(deleteMenuQuery, content.Name, content.Description, content.Price, content.Category, pq.Array(content.Allergens), id)
+SET name = $1,    description = $2, price = $3, category_id = $4, prep_time = $5, station = $6
+WHERE product_id = $7`
+	_, err := tx.Exec(deleteMenuQuery, content.Name, content.Description, content.Price, content.CategoryID, content.PrepTime, content.Station, id)
 	if err != nil {
 		return err
 	}
@@ -97,18 +133,36 @@ WHERE product_id = $6`
 		return err
 	}
 	insertIngredientsQuery := `
-	INSERT INTO menu_item_ingredients (product_id, ingredient_id, quantity)
-	VALUES ($1, $2, $3)
+	INSERT INTO menu_item_ingredients (product_id, ingredient_id, quantity, unit)
+	VALUES ($1, $2, $3, NULLIF($4, ''))
 	`
 	for _, ingredient := range content.Ingredients {
-		_, err = tx.Exec(insertIngredientsQuery, id, ingredient.IngredientID, ingredient.Quantity)
+		_, err = tx.Exec(insertIngredientsQuery, id, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return saveMenuItemDetails(tx, id, content)
 }
 
+// Saves the parts of a menu item kept outside the menu_items row
+func saveMenuItemDetails(tx *sql.Tx, productId int, content models.MenuItem) error {
+	err := saveVariants(tx, productId, content.Variants)
+	if err != nil {
+		return err
+	}
+	err = saveModifierGroups(tx, productId, content.ModifierGroups)
+	if err != nil {
+		return err
+	}
+	err = saveAvailabilityWindows(tx, "product_id", productId, content.Availability)
+	if err != nil {
+		return err
+	}
+	return saveBundleSlots(tx, productId, content.BundleSlots)
+}
+
+// Deletes a menu item that no order references; referenced items have to be archived instead
 func (r *jsonMenuRepository) DeleteMenuItem(id int) error {
 	tx, err := r.newDB.Db.Begin()
 	if err != nil {
@@ -127,6 +181,10 @@ WHERE product_id =$1;
 	`
 	result, err := tx.Exec(query, id)
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			err = ErrMenuItemInUse
+		}
 		return err
 	}
 	oneRes, err := result.RowsAffected()
@@ -134,7 +192,29 @@ WHERE product_id =$1;
 		return err
 	}
 	if oneRes == 0 {
-		return errors.New("id incorrect")
+		err = errors.New("id incorrect")
+		return err
+	}
+	return nil
+}
+
+// Archives a menu item, keeping the time it was first archived, or restores it when archived is false
+func (r *jsonMenuRepository) ArchiveMenuItem(id int, archived bool) error {
+	stmt := `
+	UPDATE menu_items
+	SET archived_at = CASE WHEN $2 THEN COALESCE(archived_at, CURRENT_TIMESTAMP) ELSE NULL END
+	WHERE product_id = $1;
+	`
+	res, err := r.newDB.Db.Exec(stmt, id, archived)
+	if err != nil {
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		return fmt.Errorf("menu item with ID %d not found", id)
 	}
 	return nil
 }
@@ -147,11 +227,19 @@ func (r *jsonMenuRepository) GetMenuRepo() ([]models.MenuItem, error) {
 		mi.name,
 		mi.description,
 		mi.price,
-		mi.category,
-		mi.allergens,
+		mi.category_id,
+		c.name,
+		ma.allergens,
+		ma.modifier_allergens,
+		mi.prep_time,
+		mi.station,
+		mi.archived_at,
 		mii.ingredient_id,
-		mii.quantity
+		mii.quantity,
+		mii.unit
 	FROM menu_items mi
+	LEFT JOIN categories c ON mi.category_id = c.category_id
+	JOIN menu_item_allergens ma ON mi.product_id = ma.product_id
 	LEFT JOIN menu_item_ingredients mii ON mi.product_id = mii.product_id;
 	`
 	rows, err := r.newDB.Db.Query(query)
@@ -163,42 +251,80 @@ func (r *jsonMenuRepository) GetMenuRepo() ([]models.MenuItem, error) {
 	for rows.Next() {
 		var ingredientId sql.NullInt64
 		var quantity sql.NullFloat64
+		var unit sql.NullString
 		var productId int
-		var name, description, category string
-		var allergens []string
+		var name, description string
+		var categoryId sql.NullInt64
+		var category sql.NullString
+		var allergens, modifierAllergens []string
 		var price float64
+		var prepTime int
+		var station string
+		var archivedAt sql.NullString
 		err = rows.Scan(
 			&productId,
 			&name,
 			&description,
 			&price,
+			&categoryId,
 			&category,
 			pq.Array(&allergens),
+			pq.Array(&modifierAllergens),
+			&prepTime,
+			&station,
+			&archivedAt,
 			&ingredientId,
 			&quantity,
+			&unit,
 		)
 		if err != nil {
 			return nil, err
 		}
 		if _, exists := menuMap[productId]; !exists {
 			menuMap[productId] = &models.MenuItem{
-				ID:          productId,
-				Name:        name,
-				Description: description,
-				Price:       price,
-				Category:    category,
-				Allergens:   allergens,
-				Ingredients: []models.MenuItemIngredient{},
+				ID:                productId,
+				Name:              name,
+				Description:       description,
+				Price:             price,
+				CategoryID:        nullableInt(categoryId),
+				Category:          category.String,
+				Allergens:         allergens,
+				ModifierAllergens: modifierAllergens,
+				PrepTime:          &prepTime,
+				Station:           station,
+				ArchivedAt:        nullableString(archivedAt),
+				Ingredients:       []models.MenuItemIngredient{},
 			}
-
+		}
+		if ingredientId.Valid {
 			menuMap[productId].Ingredients = append(menuMap[productId].Ingredients, models.MenuItemIngredient{
 				IngredientID: int(ingredientId.Int64),
 				Quantity:     float64(quantity.Float64),
+				Unit:         unit.String,
 			})
-
 		}
 	}
-	for _, item := range menuMap {
+	variants, err := r.readVariants(nil)
+	if err != nil {
+		return nil, err
+	}
+	modifierGroups, err := r.readModifierGroups(nil)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := readAvailabilityWindows(r.newDB.Db, "product_id", nil)
+	if err != nil {
+		return nil, err
+	}
+	bundleSlots, err := r.readBundleSlots(nil)
+	if err != nil {
+		return nil, err
+	}
+	for productId, item := range menuMap {
+		item.Variants = variants[productId]
+		item.ModifierGroups = modifierGroups[productId]
+		item.Availability = windows[productId]
+		item.BundleSlots = bundleSlots[productId]
 		menu = append(menu, *item)
 	}
 	return menu, nil
@@ -212,11 +338,19 @@ func (r *jsonMenuRepository) GetMenuItemID(id int) (models.MenuItem, error) {
 		mi.name,
 		mi.description,
 		mi.price,
-		mi.category,
-		mi.allergens,
+		mi.category_id,
+		c.name,
+		ma.allergens,
+		ma.modifier_allergens,
+		mi.prep_time,
+		mi.station,
+		mi.archived_at,
 		mii.ingredient_id,
-		mii.quantity
+		mii.quantity,
+		mii.unit
 	FROM menu_items mi
+	LEFT JOIN categories c ON mi.category_id = c.category_id
+	JOIN menu_item_allergens ma ON mi.product_id = ma.product_id
 	LEFT JOIN menu_item_ingredients mii ON mi.product_id = mii.product_id
 	WHERE mi.product_id = $1;
 	`
@@ -225,54 +359,278 @@ func (r *jsonMenuRepository) GetMenuItemID(id int) (models.MenuItem, error) {
 	if err != nil {
 		return menuItem, err
 	}
+	defer rows.Close()
 	found := false
 	for rows.Next() {
 		var (
-			ingredientsID int
-			quantity      float64
+			ingredientsID sql.NullInt64
+			quantity      sql.NullFloat64
+			unit          sql.NullString
 		)
 		var (
-			productId   int
-			name        string
-			description string
-			price       float64
-			category    string
-			allergens   []string
+			productId    int
+			name         string
+			description  string
+			price        float64
+			categoryId   sql.NullInt64
+			category     sql.NullString
+			allergens    []string
+			modAllergens []string
+			prepTime     int
+			station      string
+			archivedAt   sql.NullString
 		)
 		err := rows.Scan(
 			&productId,
 			&name,
 			&description,
 			&price,
+			&categoryId,
 			&category,
 			pq.Array(&allergens),
+			pq.Array(&modAllergens),
+			&prepTime,
+			&station,
+			&archivedAt,
 			&ingredientsID,
 			&quantity,
+			&unit,
 		)
 		if err != nil {
 			return menuItem, err
 		}
 		if !found {
 			menuItem = models.MenuItem{
-				ID:          productId,
-				Name:        name,
-				Description: description,
-				Price:       price,
-				Category:    category,
-				Allergens:   allergens,
-				Ingredients: []models.MenuItemIngredient{},
+				ID:                productId,
+				Name:              name,
+				Description:       description,
+				Price:             price,
+				CategoryID:        nullableInt(categoryId),
+				Category:          category.String,
+				Allergens:         allergens,
+				ModifierAllergens: modAllergens,
+				PrepTime:          &prepTime,
+				Station:           station,
+				ArchivedAt:        nullableString(archivedAt),
+				Ingredients:       []models.MenuItemIngredient{},
 			}
 			found = true
 		}
-		if ingredientsID != 0 {
+		if ingredientsID.Valid {
 			menuItem.Ingredients = append(menuItem.Ingredients, models.MenuItemIngredient{
-				IngredientID: ingredientsID,
-				Quantity:     quantity,
+				IngredientID: int(ingredientsID.Int64),
+				Quantity:     quantity.Float64,
+				Unit:         unit.String,
 			})
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return menuItem, err
+	}
 	if !found {
 		return menuItem, fmt.Errorf("menu item with ID %d not found", id)
 	}
+	variants, err := r.readVariants(&id)
+	if err != nil {
+		return menuItem, err
+	}
+	menuItem.Variants = variants[id]
+	modifierGroups, err := r.readModifierGroups(&id)
+	if err != nil {
+		return menuItem, err
+	}
+	menuItem.ModifierGroups = modifierGroups[id]
+	windows, err := readAvailabilityWindows(r.newDB.Db, "product_id", &id)
+	if err != nil {
+		return menuItem, err
+	}
+	menuItem.Availability = windows[id]
+	bundleSlots, err := r.readBundleSlots(&id)
+	if err != nil {
+		return menuItem, err
+	}
+	menuItem.BundleSlots = bundleSlots[id]
+	err = r.readNutrition(&menuItem)
+	if err != nil {
+		return menuItem, err
+	}
 	return menuItem, nil
 }
+
+// Upserts the variants of a menu item by name, replaces their recipe overrides and archives variants no longer listed
+func saveVariants(tx *sql.Tx, productId int, variants []models.MenuItemVariant) error {
+	variantStmt := `
+	INSERT INTO menu_item_variants (product_id, name, price, recipe_multiplier)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (product_id, name) DO UPDATE
+	SET price = EXCLUDED.price, recipe_multiplier = EXCLUDED.recipe_multiplier, archived_at = NULL
+	RETURNING variant_id;
+	`
+	deleteOverrideStmt := `DELETE FROM menu_item_variant_ingredients WHERE variant_id = $1`
+	overrideStmt := `
+	INSERT INTO menu_item_variant_ingredients (variant_id, ingredient_id, quantity, unit)
+	VALUES ($1, $2, $3, NULLIF($4, ''))
+	`
+	names := []string{}
+	for _, variant := range variants {
+		multiplier := variant.RecipeMultiplier
+		if multiplier == 0 {
+			multiplier = 1
+		}
+		var variantId int
+		err := tx.QueryRow(variantStmt, productId, variant.Name, variant.Price, multiplier).Scan(&variantId)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(deleteOverrideStmt, variantId)
+		if err != nil {
+			return err
+		}
+		for _, ingredient := range variant.Ingredients {
+			_, err = tx.Exec(overrideStmt, variantId, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
+			if err != nil {
+				return err
+			}
+		}
+		names = append(names, variant.Name)
+	}
+	// Removed variants are archived rather than deleted: orders and older menu versions keep referring to them
+	removeStmt := `
+	UPDATE menu_item_variants
+	SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP)
+	WHERE product_id = $1 AND NOT (name = ANY($2::TEXT[]));
+	`
+	_, err := tx.Exec(removeStmt, productId, pq.Array(names))
+	return err
+}
+
+// Reads variants with their recipe overrides grouped by product ID, for one product or for the whole menu when productId is nil
+func (r *jsonMenuRepository) readVariants(productId *int) (map[int][]models.MenuItemVariant, error) {
+	query := `
+	SELECT
+		v.product_id,
+		v.variant_id,
+		v.name,
+		v.price,
+		v.recipe_multiplier,
+		vi.ingredient_id,
+		vi.quantity,
+		vi.unit
+	FROM menu_item_variants v
+	LEFT JOIN menu_item_variant_ingredients vi ON v.variant_id = vi.variant_id
+	WHERE ($1::INT IS NULL OR v.product_id = $1) AND v.archived_at IS NULL
+	ORDER BY v.product_id, v.price, v.variant_id;
+	`
+	rows, err := r.newDB.Db.Query(query, productId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	variants := make(map[int][]models.MenuItemVariant)
+	for rows.Next() {
+		var product int
+		var variant models.MenuItemVariant
+		var ingredientId sql.NullInt64
+		var quantity sql.NullFloat64
+		var unit sql.NullString
+		err := rows.Scan(
+			&product,
+			&variant.VariantID,
+			&variant.Name,
+			&variant.Price,
+			&variant.RecipeMultiplier,
+			&ingredientId,
+			&quantity,
+			&unit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list := variants[product]
+		if len(list) == 0 || list[len(list)-1].VariantID != variant.VariantID {
+			list = append(list, variant)
+		}
+		if ingredientId.Valid {
+			list[len(list)-1].Ingredients = append(list[len(list)-1].Ingredients, models.MenuItemIngredient{
+				IngredientID: int(ingredientId.Int64),
+				Quantity:     quantity.Float64,
+				Unit:         unit.String,
+			})
+		}
+		variants[product] = list
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// Replaces the modifier groups attached to a menu item, rejecting groups whose substitutions its recipe cannot cover
+func saveModifierGroups(tx *sql.Tx, productId int, groupIds []int) error {
+	_, err := tx.Exec(`DELETE FROM menu_item_modifier_groups WHERE product_id = $1`, productId)
+	if err != nil {
+		return err
+	}
+	stmt := `
+	INSERT INTO menu_item_modifier_groups (product_id, group_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING;
+	`
+	for _, groupId := range groupIds {
+		_, err = tx.Exec(stmt, productId, groupId)
+		if err != nil {
+			return err
+		}
+	}
+	return checkSubstitutions(tx, nil, &productId)
+}
+
+// Reads the attached modifier group IDs grouped by product ID, for one product or for the whole menu when productId is nil
+func (r *jsonMenuRepository) readModifierGroups(productId *int) (map[int][]int, error) {
+	query := `
+	SELECT product_id, group_id
+	FROM menu_item_modifier_groups
+	WHERE ($1::INT IS NULL OR product_id = $1)
+	ORDER BY product_id, group_id;
+	`
+	rows, err := r.newDB.Db.Query(query, productId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := make(map[int][]int)
+	for rows.Next() {
+		var product, group int
+		err := rows.Scan(&product, &group)
+		if err != nil {
+			return nil, err
+		}
+		groups[product] = append(groups[product], group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func nullableInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	res := int(value.Int64)
+	return &res
+}
+
+func nullableString(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func nullableFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
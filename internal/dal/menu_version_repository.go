@@ -0,0 +1,429 @@
+package dal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// Returned when editing, scheduling or deleting a menu version that is no longer a draft
+var ErrVersionNotDraft = errors.New("only draft menu versions can be changed")
+
+type MenuVersionRepository interface {
+	PostVersion(version models.MenuVersion) (int, error)
+	GetVersions() ([]models.MenuVersion, error)
+	GetVersion(id int) (models.MenuVersion, error)
+	UpdateVersionItems(id int, items []models.MenuItem) error
+	SchedulePublish(id int, publishAt *string) error
+	DeleteVersion(id int) error
+	GetDueVersions() ([]int, error)
+	PublishVersion(id int) error
+	PublishLiveEdit(name string, changed []models.MenuItem, removed []int) error
+}
+
+type menuVersionRepository struct {
+	newDB *SqlDataBase.DB
+}
+
+func NewMenuVersionRepository(db *SqlDataBase.DB) MenuVersionRepository {
+	return &menuVersionRepository{newDB: db}
+}
+
+// Stores a new menu version; a version stored as published is recorded as published now without touching the menu
+func (r *menuVersionRepository) PostVersion(version models.MenuVersion) (int, error) {
+	items, err := json.Marshal(versionItemsOrEmpty(version.Items))
+	if err != nil {
+		return 0, err
+	}
+	stmt := `
+	INSERT INTO menu_versions (name, status, based_on, items, published_at)
+	VALUES ($1, $2, $3, $4, CASE WHEN $2 = 'published' THEN CURRENT_TIMESTAMP END)
+	RETURNING version_id;
+	`
+	var id int
+	err = r.newDB.Db.QueryRow(stmt, version.Name, version.Status, version.BasedOn, items).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Lists menu versions without their items, newest first
+func (r *menuVersionRepository) GetVersions() ([]models.MenuVersion, error) {
+	query := `
+	SELECT version_id, name, status, based_on, publish_at, published_at, created_at
+	FROM menu_versions
+	ORDER BY version_id DESC;
+	`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := []models.MenuVersion{}
+	for rows.Next() {
+		version, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *menuVersionRepository) GetVersion(id int) (models.MenuVersion, error) {
+	query := `
+	SELECT version_id, name, status, based_on, publish_at, published_at, created_at, items
+	FROM menu_versions
+	WHERE version_id = $1;
+	`
+	var items []byte
+	row := r.newDB.Db.QueryRow(query, id)
+	version, err := scanVersion(row, &items)
+	if err == sql.ErrNoRows {
+		return version, fmt.Errorf("menu version with ID %d not found", id)
+	}
+	if err != nil {
+		return version, err
+	}
+	err = json.Unmarshal(items, &version.Items)
+	return version, err
+}
+
+// Replaces the items of a draft
+func (r *menuVersionRepository) UpdateVersionItems(id int, items []models.MenuItem) error {
+	content, err := json.Marshal(versionItemsOrEmpty(items))
+	if err != nil {
+		return err
+	}
+	res, err := r.newDB.Db.Exec(`UPDATE menu_versions SET items = $2 WHERE version_id = $1 AND status = 'draft'`, id, content)
+	if err != nil {
+		return err
+	}
+	return r.draftAffected(id, res)
+}
+
+// Sets or clears the time a draft is published at. The time carries its offset and is stored as the database's
+// local time, which the scheduler compares against
+func (r *menuVersionRepository) SchedulePublish(id int, publishAt *string) error {
+	stmt := `UPDATE menu_versions SET publish_at = $2::TIMESTAMPTZ::TIMESTAMP WHERE version_id = $1 AND status = 'draft'`
+	res, err := r.newDB.Db.Exec(stmt, id, publishAt)
+	if err != nil {
+		return err
+	}
+	return r.draftAffected(id, res)
+}
+
+// Deletes a draft; published and retired versions stay as the history orders refer to
+func (r *menuVersionRepository) DeleteVersion(id int) error {
+	res, err := r.newDB.Db.Exec(`DELETE FROM menu_versions WHERE version_id = $1 AND status = 'draft'`, id)
+	if err != nil {
+		return err
+	}
+	return r.draftAffected(id, res)
+}
+
+// Lists the drafts whose publish time has come, oldest first
+func (r *menuVersionRepository) GetDueVersions() ([]int, error) {
+	query := `
+	SELECT version_id
+	FROM menu_versions
+	WHERE status = 'draft' AND publish_at <= LOCALTIMESTAMP
+	ORDER BY publish_at, version_id;
+	`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Key of the transaction-level advisory lock that makes publishes of the menu wait for each other
+const menuPublishLock = 7301
+
+// Publishes a draft or a retired version in one transaction: its items are written to the menu, items that are not
+// in the version are archived, new items get their product IDs recorded in the version, and the previously published
+// version is retired
+func (r *menuVersionRepository) PublishVersion(id int) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, menuPublishLock)
+	if err != nil {
+		return err
+	}
+	var status string
+	var content []byte
+	err = tx.QueryRow(`SELECT status, items FROM menu_versions WHERE version_id = $1 FOR UPDATE`, id).Scan(&status, &content)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("menu version with ID %d not found", id)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if status == "published" {
+		err = errors.New("menu version is already published")
+		return err
+	}
+	var items []models.MenuItem
+	err = json.Unmarshal(content, &items)
+	if err != nil {
+		return err
+	}
+	published, err := writeVersionItems(tx, items)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE menu_items
+	SET archived_at = CASE WHEN product_id = ANY($1) THEN NULL ELSE COALESCE(archived_at, CURRENT_TIMESTAMP) END;
+	`, pq.Array(published))
+	if err != nil {
+		return err
+	}
+
+	content, err = json.Marshal(versionItemsOrEmpty(items))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE menu_versions SET status = 'retired' WHERE status = 'published'`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE menu_versions
+	SET status = 'published', published_at = CURRENT_TIMESTAMP, publish_at = NULL, items = $2
+	WHERE version_id = $1;
+	`, id, content)
+	return err
+}
+
+// Publishes an edit of the live menu as a new version based on the published one, in one transaction. Only the
+// changed items are written and only the removed items are archived, and the new version holds the published items
+// with the edit applied, so edits published at the same time do not undo each other
+func (r *menuVersionRepository) PublishLiveEdit(name string, changed []models.MenuItem, removed []int) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, menuPublishLock)
+	if err != nil {
+		return err
+	}
+	var basedOn int
+	var content []byte
+	err = tx.QueryRow(`SELECT version_id, items FROM menu_versions WHERE status = 'published'`).Scan(&basedOn, &content)
+	if err == sql.ErrNoRows {
+		err = errors.New("no menu version is published")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	var items []models.MenuItem
+	err = json.Unmarshal(content, &items)
+	if err != nil {
+		return err
+	}
+	written, err := writeVersionItems(tx, changed)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE menu_items SET archived_at = NULL WHERE product_id = ANY($1)`, pq.Array(written))
+	if err != nil {
+		return err
+	}
+	dropped := make(map[int]bool)
+	archived := make([]int64, 0, len(removed))
+	for _, productId := range removed {
+		dropped[productId] = true
+		archived = append(archived, int64(productId))
+	}
+	_, err = tx.Exec(`
+	UPDATE menu_items
+	SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP)
+	WHERE product_id = ANY($1);
+	`, pq.Array(archived))
+	if err != nil {
+		return err
+	}
+
+	for _, item := range changed {
+		dropped[item.ID] = true
+	}
+	edited := []models.MenuItem{}
+	for _, item := range items {
+		if !dropped[item.ID] {
+			edited = append(edited, item)
+		}
+	}
+	edited = append(edited, changed...)
+	content, err = json.Marshal(edited)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE menu_versions SET status = 'retired' WHERE status = 'published'`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	INSERT INTO menu_versions (name, status, based_on, items, published_at)
+	VALUES ($1, 'published', $2, $3, CURRENT_TIMESTAMP);
+	`, name, basedOn, content)
+	return err
+}
+
+// Writes version items to the menu: items that are not on it yet are inserted, the rest are updated. New items
+// and the bundle slots referring to them get their product IDs in place. Returns the product IDs written
+func writeVersionItems(tx *sql.Tx, items []models.MenuItem) ([]int64, error) {
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, int64(item.ID))
+	}
+	onMenu := make(map[int]bool)
+	rows, err := tx.Query(`SELECT product_id FROM menu_items WHERE product_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var productId int
+		if err = rows.Scan(&productId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		onMenu[productId] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// New items are inserted bare first so bundle slots can refer to them by their new IDs
+	newIds := make(map[int]int)
+	for _, item := range items {
+		if onMenu[item.ID] {
+			continue
+		}
+		bare := models.MenuItem{
+			Name:        item.Name,
+			Description: item.Description,
+			Price:       item.Price,
+			CategoryID:  item.CategoryID,
+			PrepTime:    item.PrepTime,
+			Station:     item.Station,
+		}
+		newIds[item.ID], err = insertMenuItem(tx, bare)
+		if err != nil {
+			return nil, err
+		}
+	}
+	written := make([]int64, 0, len(items))
+	for i := range items {
+		if newId, ok := newIds[items[i].ID]; ok {
+			items[i].ID = newId
+		}
+		for s := range items[i].BundleSlots {
+			for o, productId := range items[i].BundleSlots[s].Options {
+				if newId, ok := newIds[productId]; ok {
+					items[i].BundleSlots[s].Options[o] = newId
+				}
+			}
+		}
+		err = updateMenuItem(tx, items[i].ID, items[i])
+		if err != nil {
+			return nil, err
+		}
+		written = append(written, int64(items[i].ID))
+	}
+	return written, nil
+}
+
+// Tells a missing version apart from one that is not a draft when a draft-only statement changed nothing
+func (r *menuVersionRepository) draftAffected(id int, res sql.Result) error {
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff > 0 {
+		return nil
+	}
+	var exists bool
+	err = r.newDB.Db.QueryRow(`SELECT EXISTS(SELECT 1 FROM menu_versions WHERE version_id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("menu version with ID %d not found", id)
+	}
+	return ErrVersionNotDraft
+}
+
+type versionScanner interface {
+	Scan(dest ...any) error
+}
+
+// Scans the common columns of a menu version followed by any extra destinations
+func scanVersion(row versionScanner, extra ...any) (models.MenuVersion, error) {
+	var version models.MenuVersion
+	var basedOn sql.NullInt64
+	var publishAt, publishedAt sql.NullString
+	dest := append([]any{
+		&version.VersionID,
+		&version.Name,
+		&version.Status,
+		&basedOn,
+		&publishAt,
+		&publishedAt,
+		&version.CreatedAt,
+	}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return version, err
+	}
+	version.BasedOn = nullableInt(basedOn)
+	version.PublishAt = nullableString(publishAt)
+	version.PublishedAt = nullableString(publishedAt)
+	return version, nil
+}
+
+// The items column is NOT NULL, so an empty version is stored as an empty array
+func versionItemsOrEmpty(items []models.MenuItem) []models.MenuItem {
+	if items == nil {
+		return []models.MenuItem{}
+	}
+	return items
+}
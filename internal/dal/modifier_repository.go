@@ -0,0 +1,292 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// ModifierRepository defines the methods for storing modifier groups and their options
+type ModifierRepository interface {
+	PostModifierGroup(group models.ModifierGroup) error
+	GetModifierGroups() ([]models.ModifierGroup, error)
+	GetModifierGroupID(id int) (models.ModifierGroup, error)
+	UpdateModifierGroup(id int, group models.ModifierGroup) error
+	DeleteModifierGroup(id int) error
+}
+
+type modifierRepository struct {
+	newDB *SqlDataBase.DB
+}
+
+// NewModifierRepository creates and returns a new instance of modifierRepository
+func NewModifierRepository(db *SqlDataBase.DB) ModifierRepository {
+	return &modifierRepository{newDB: db}
+}
+
+func (r *modifierRepository) PostModifierGroup(group models.ModifierGroup) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	groupId := 0
+	stmt := `
+	INSERT INTO modifier_groups (name, min_select, max_select)
+	VALUES ($1, $2, $3)
+	RETURNING group_id;
+	`
+	err = tx.QueryRow(stmt, group.Name, group.MinSelect, group.MaxSelect).Scan(&groupId)
+	if err != nil {
+		return err
+	}
+	err = saveModifierOptions(tx, groupId, group.Options)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *modifierRepository) UpdateModifierGroup(id int, group models.ModifierGroup) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	stmt := `
+	UPDATE modifier_groups
+	SET name = $1, min_select = $2, max_select = $3
+	WHERE group_id = $4;
+	`
+	res, err := tx.Exec(stmt, group.Name, group.MinSelect, group.MaxSelect, id)
+	if err != nil {
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		err = fmt.Errorf("modifier group with ID %d not found", id)
+		return err
+	}
+	err = saveModifierOptions(tx, id, group.Options)
+	if err != nil {
+		return err
+	}
+	err = checkSubstitutions(tx, &id, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *modifierRepository) DeleteModifierGroup(id int) error {
+	res, err := r.newDB.Db.Exec(`DELETE FROM modifier_groups WHERE group_id = $1`, id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return errors.New("cannot delete a modifier group whose options are referenced by orders")
+		}
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		return fmt.Errorf("modifier group with ID %d not found", id)
+	}
+	return nil
+}
+
+func (r *modifierRepository) GetModifierGroups() ([]models.ModifierGroup, error) {
+	return r.readModifierGroups(nil)
+}
+
+func (r *modifierRepository) GetModifierGroupID(id int) (models.ModifierGroup, error) {
+	groups, err := r.readModifierGroups(&id)
+	if err != nil {
+		return models.ModifierGroup{}, err
+	}
+	if len(groups) == 0 {
+		return models.ModifierGroup{}, fmt.Errorf("modifier group with ID %d not found", id)
+	}
+	return groups[0], nil
+}
+
+// Upserts the options of a modifier group by name, replaces their inventory effects and removes options no longer listed
+func saveModifierOptions(tx *sql.Tx, groupId int, options []models.ModifierOption) error {
+	optionStmt := `
+	INSERT INTO modifier_options (group_id, name, price_delta)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (group_id, name) DO UPDATE
+	SET price_delta = EXCLUDED.price_delta
+	RETURNING option_id;
+	`
+	deleteIngredientsStmt := `DELETE FROM modifier_option_ingredients WHERE option_id = $1`
+	ingredientStmt := `
+	INSERT INTO modifier_option_ingredients (option_id, ingredient_id, quantity, unit)
+	VALUES ($1, $2, $3, NULLIF($4, ''))
+	`
+	names := []string{}
+	for _, option := range options {
+		var optionId int
+		err := tx.QueryRow(optionStmt, groupId, option.Name, option.PriceDelta).Scan(&optionId)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(deleteIngredientsStmt, optionId)
+		if err != nil {
+			return err
+		}
+		for _, ingredient := range option.Ingredients {
+			_, err = tx.Exec(ingredientStmt, optionId, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
+			if err != nil {
+				return err
+			}
+		}
+		names = append(names, option.Name)
+	}
+	removeStmt := `
+	DELETE FROM modifier_options
+	WHERE group_id = $1 AND NOT (name = ANY($2::TEXT[]));
+	`
+	_, err := tx.Exec(removeStmt, groupId, pq.Array(names))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return errors.New("cannot remove a modifier option that is referenced by orders")
+		}
+		return err
+	}
+	return nil
+}
+
+// Rejects substitutions that take more of an ingredient than the recipe of an attached menu item holds,
+// checking every active variant or the base recipe of items without variants. Limited to one modifier
+// group or to one menu item, whichever is not nil
+func checkSubstitutions(tx *sql.Tx, groupId, productId *int) error {
+	query := `
+	SELECT mi.name, o.name, i.name
+	FROM menu_item_modifier_groups mg
+	JOIN menu_items mi ON mg.product_id = mi.product_id
+	JOIN modifier_options o ON mg.group_id = o.group_id
+	JOIN modifier_option_ingredients moi ON o.option_id = moi.option_id
+	JOIN inventory i ON moi.ingredient_id = i.ingredient_id
+	LEFT JOIN menu_item_variants v ON mg.product_id = v.product_id AND v.archived_at IS NULL
+	CROSS JOIN LATERAL (
+		SELECT COALESCE(SUM(r.quantity), 0) AS quantity
+		FROM recipe_for(mg.product_id, v.variant_id) r
+		WHERE r.ingredient_id = moi.ingredient_id
+	) recipe
+	WHERE moi.stock_quantity < 0
+		AND recipe.quantity + moi.stock_quantity < 0
+		AND ($1::INT IS NULL OR mg.group_id = $1)
+		AND ($2::INT IS NULL OR mg.product_id = $2)
+	LIMIT 1;
+	`
+	var product, option, ingredient string
+	err := tx.QueryRow(query, groupId, productId).Scan(&product, &option, &ingredient)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("modifier option %s removes more %s than the recipe of %s holds", option, ingredient, product)
+}
+
+// Reads modifier groups with their options and inventory effects, for one group or all groups when groupId is nil
+func (r *modifierRepository) readModifierGroups(groupId *int) ([]models.ModifierGroup, error) {
+	query := `
+	SELECT
+		g.group_id,
+		g.name,
+		g.min_select,
+		g.max_select,
+		o.option_id,
+		o.name,
+		o.price_delta,
+		oi.ingredient_id,
+		oi.quantity,
+		oi.unit
+	FROM modifier_groups g
+	LEFT JOIN modifier_options o ON g.group_id = o.group_id
+	LEFT JOIN modifier_option_ingredients oi ON o.option_id = oi.option_id
+	WHERE ($1::INT IS NULL OR g.group_id = $1)
+	ORDER BY g.group_id, o.option_id;
+	`
+	rows, err := r.newDB.Db.Query(query, groupId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := []models.ModifierGroup{}
+	for rows.Next() {
+		var group models.ModifierGroup
+		var optionId, ingredientId sql.NullInt64
+		var optionName, unit sql.NullString
+		var priceDelta, quantity sql.NullFloat64
+		err := rows.Scan(
+			&group.GroupID,
+			&group.Name,
+			&group.MinSelect,
+			&group.MaxSelect,
+			&optionId,
+			&optionName,
+			&priceDelta,
+			&ingredientId,
+			&quantity,
+			&unit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) == 0 || groups[len(groups)-1].GroupID != group.GroupID {
+			group.Options = []models.ModifierOption{}
+			groups = append(groups, group)
+		}
+		current := &groups[len(groups)-1]
+		if !optionId.Valid {
+			continue
+		}
+		if len(current.Options) == 0 || current.Options[len(current.Options)-1].OptionID != int(optionId.Int64) {
+			current.Options = append(current.Options, models.ModifierOption{
+				OptionID:    int(optionId.Int64),
+				Name:        optionName.String,
+				PriceDelta:  priceDelta.Float64,
+				Ingredients: []models.MenuItemIngredient{},
+			})
+		}
+		if ingredientId.Valid {
+			option := &current.Options[len(current.Options)-1]
+			option.Ingredients = append(option.Ingredients, models.MenuItemIngredient{
+				IngredientID: int(ingredientId.Int64),
+				Quantity:     quantity.Float64,
+				Unit:         unit.String,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
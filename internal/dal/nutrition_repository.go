@@ -0,0 +1,69 @@
+package dal
+
+import (
+	"database/sql"
+
+	"frapuccino/models"
+)
+
+// Fills in the per-serving nutrition of a menu item's base recipe and variants, and what each of its modifier options adds
+func (r *jsonMenuRepository) readNutrition(item *models.MenuItem) error {
+	rows, err := r.newDB.Db.Query(`
+	SELECT variant_id, kcal, sugar, fat, caffeine
+	FROM menu_item_nutrition
+	WHERE product_id = $1;
+	`, item.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var variantId sql.NullInt64
+		var nutrition models.Nutrition
+		err := rows.Scan(&variantId, &nutrition.Kcal, &nutrition.Sugar, &nutrition.Fat, &nutrition.Caffeine)
+		if err != nil {
+			return err
+		}
+		if !variantId.Valid {
+			item.Nutrition = &nutrition
+			continue
+		}
+		for i := range item.Variants {
+			if item.Variants[i].VariantID == int(variantId.Int64) {
+				item.Variants[i].Nutrition = &nutrition
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	optionRows, err := r.newDB.Db.Query(`
+	SELECT mo.option_id, mo.name, n.kcal, n.sugar, n.fat, n.caffeine
+	FROM menu_item_modifier_groups mg
+	JOIN modifier_options mo ON mg.group_id = mo.group_id
+	JOIN modifier_option_nutrition n ON mo.option_id = n.option_id
+	WHERE mg.product_id = $1
+	ORDER BY mg.group_id, mo.option_id;
+	`, item.ID)
+	if err != nil {
+		return err
+	}
+	defer optionRows.Close()
+	for optionRows.Next() {
+		var option models.OptionNutrition
+		err := optionRows.Scan(
+			&option.OptionID,
+			&option.Name,
+			&option.Nutrition.Kcal,
+			&option.Nutrition.Sugar,
+			&option.Nutrition.Fat,
+			&option.Nutrition.Caffeine,
+		)
+		if err != nil {
+			return err
+		}
+		item.ModifierNutrition = append(item.ModifierNutrition, option)
+	}
+	return optionRows.Err()
+}
@@ -0,0 +1,23 @@
+package orderRepo
+
+import (
+	"sort"
+
+	"frapuccino/models"
+)
+
+// Collects the allergens of all order lines into one sorted list for the order-level warning
+func orderAllergens(items []models.OrderItem) []string {
+	seen := make(map[string]bool)
+	allergens := []string{}
+	for _, item := range items {
+		for _, allergen := range item.Allergens {
+			if !seen[allergen] {
+				seen[allergen] = true
+				allergens = append(allergens, allergen)
+			}
+		}
+	}
+	sort.Strings(allergens)
+	return allergens
+}
@@ -0,0 +1,78 @@
+package orderRepo
+
+import (
+	"database/sql"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// Returns the slots of a bundle menu item with the IDs of the menu items each slot can be filled with; archived
+// menu items are left out, so a slot may have no options left
+func (r *orderRepository) GetBundleSlots(productID int) ([]models.BundleSlot, error) {
+	stmt := `
+	SELECT s.slot_id, s.name, s.quantity, COALESCE(array_agg(o.product_id ORDER BY o.product_id) FILTER (WHERE o.product_id IS NOT NULL), '{}')
+	FROM bundle_slots s
+	LEFT JOIN (
+		SELECT bo.slot_id, bo.product_id
+		FROM bundle_slot_options bo
+		JOIN menu_items mi ON bo.product_id = mi.product_id AND mi.archived_at IS NULL
+	) o ON s.slot_id = o.slot_id
+	WHERE s.bundle_id = $1
+	GROUP BY s.slot_id, s.name, s.quantity
+	ORDER BY s.slot_id;
+	`
+	rows, err := r.newDB.Db.Query(stmt, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	slots := []models.BundleSlot{}
+	for rows.Next() {
+		var slot models.BundleSlot
+		var options pq.Int64Array
+		err := rows.Scan(&slot.SlotID, &slot.Name, &slot.Quantity, &options)
+		if err != nil {
+			return nil, err
+		}
+		slot.Options = SqlDataBase.ToIntSlice(options)
+		slots = append(slots, slot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+// Records the components of a bundle order line: the chosen menu item for each slot, or its only option
+func insertOrderComponents(tx *sql.Tx, orderItemID int, item models.OrderItem) error {
+	stmt := `
+	INSERT INTO order_item_components (order_item_id, slot_id, product_id, quantity)
+	SELECT $1, s.slot_id, COALESCE(c.product_id, (SELECT MIN(o.product_id) FROM bundle_slot_options o WHERE o.slot_id = s.slot_id)), s.quantity
+	FROM bundle_slots s
+	LEFT JOIN UNNEST($3::INT[], $4::INT[]) AS c(slot_id, product_id) ON s.slot_id = c.slot_id
+	WHERE s.bundle_id = $2;
+	`
+	slotIds := []int{}
+	productIds := []int{}
+	for _, component := range item.Components {
+		slotIds = append(slotIds, component.SlotID)
+		productIds = append(productIds, component.ProductID)
+	}
+	_, err := tx.Exec(stmt, orderItemID, item.ProductID, pq.Array(slotIds), pq.Array(productIds))
+	return err
+}
+
+// Pairs the slot and menu item arrays read from order_item_components; slot 0 marks a slot since removed from the bundle
+func toComponents(slots, products pq.Int64Array) []models.OrderComponent {
+	if len(products) == 0 {
+		return nil
+	}
+	components := make([]models.OrderComponent, 0, len(products))
+	for i := range products {
+		components = append(components, models.OrderComponent{SlotID: int(slots[i]), ProductID: int(products[i])})
+	}
+	return components
+}
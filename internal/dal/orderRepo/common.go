@@ -2,20 +2,29 @@ package orderRepo
 
 import (
 	"database/sql"
+	"time"
 
 	"frapuccino/internal/dal/SqlDataBase"
 	"frapuccino/models"
 )
 
 type OrderRepository interface {
-	WriteDBNewOrder(body models.Order) error
+	WriteDBNewOrder(body models.Order, hold time.Duration) (int, error)
 	DeleteOldOrder(tx *sql.Tx, id int) error
-	UpdateOrder(id int, body models.Order) error
+	UpdateOrder(id int, body models.Order, hold time.Duration) error
 	OrderClose(id int) error
 	ParseOrders() ([]models.Order, error)
 	DeleteOrder(id int) error
 	GetRepoId(id int) (models.Order, error)
-	CheckIngredients(tx *sql.Tx, body models.Order) error
+	CheckIngredients(tx *sql.Tx, body models.Order, hold time.Duration) error
+	ExpireReservations() ([]int, error)
+	RefreshETA(tx *sql.Tx, orderID *int) error
+	GetOrderETA(id int) (models.OrderETA, error)
+	GetStations() ([]models.Station, error)
+	UpdateStation(station models.Station) error
+	GetModifierRules(productID int) ([]models.ModifierGroup, error)
+	GetBundleSlots(productID int) ([]models.BundleSlot, error)
+	GetVariantIDs(productID int) ([]int, error)
 }
 
 type orderRepository struct {
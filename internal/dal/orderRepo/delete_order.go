@@ -26,7 +26,7 @@ func (r orderRepository) DeleteOrder(id int) error {
 		return err
 	}
 	if status == "open" {
-		err = r.RestockInventory(tx, id)
+		err = r.releaseStock(tx, id)
 		if err != nil {
 			return err
 		}
@@ -36,5 +36,9 @@ func (r orderRepository) DeleteOrder(id int) error {
 	if err != nil {
 		return err
 	}
+	err = r.RefreshETA(tx, nil)
+	if err != nil {
+		return err
+	}
 	return nil
 }
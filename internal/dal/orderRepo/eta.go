@@ -0,0 +1,106 @@
+package orderRepo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// Recalculates the estimated ready time of open orders from the queue at each station.
+// A nil orderID refreshes every open order, otherwise only the given one.
+func (r *orderRepository) RefreshETA(tx *sql.Tx, orderID *int) error {
+	return SqlDataBase.RefreshOrderETA(tx, orderID)
+}
+
+func (r *orderRepository) GetOrderETA(id int) (models.OrderETA, error) {
+	var eta models.OrderETA
+	stmt := `
+	SELECT
+		order_id,
+		status,
+		estimated_ready_at,
+		GREATEST(EXTRACT(EPOCH FROM estimated_ready_at - CURRENT_TIMESTAMP), 0)::INT,
+		ARRAY(
+			SELECT DISTINCT allergen
+			FROM order_line_allergens la
+			CROSS JOIN UNNEST(la.allergens) AS allergen
+			WHERE la.order_id = orders.order_id
+			ORDER BY allergen
+		)
+	FROM orders
+	WHERE order_id = $1 AND estimated_ready_at IS NOT NULL;
+	`
+	var allergens pq.StringArray
+	err := r.newDB.Db.QueryRow(stmt, id).Scan(&eta.OrderID, &eta.Status, &eta.EstimatedReadyAt, &eta.EtaSeconds, &allergens)
+	if err == sql.ErrNoRows {
+		return eta, fmt.Errorf("no ETA for order with ID %d", id)
+	}
+	if err != nil {
+		return eta, err
+	}
+	eta.AllergenWarnings = allergens
+	return eta, nil
+}
+
+func (r *orderRepository) GetStations() ([]models.Station, error) {
+	rows, err := r.newDB.Db.Query(`SELECT station, active_staff FROM stations ORDER BY station`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stations := []models.Station{}
+	for rows.Next() {
+		var station models.Station
+		err := rows.Scan(&station.Station, &station.ActiveStaff)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, station)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stations, nil
+}
+
+// Changes the number of active staff at a station and refreshes the ETA of all open orders
+func (r *orderRepository) UpdateStation(station models.Station) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	stmt := `
+	UPDATE stations
+	SET active_staff = $1
+	WHERE station::TEXT = $2;
+	`
+	res, err := tx.Exec(stmt, station.ActiveStaff, station.Station)
+	if err != nil {
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		err = errors.New("station not found")
+		return err
+	}
+	err = r.RefreshETA(tx, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
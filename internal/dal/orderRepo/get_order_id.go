@@ -4,7 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 
+	"frapuccino/internal/dal/SqlDataBase"
 	"frapuccino/models"
+
+	"github.com/lib/pq"
 )
 
 func (r orderRepository) GetRepoId(id int) (models.Order, error) {
@@ -15,10 +18,36 @@ func (r orderRepository) GetRepoId(id int) (models.Order, error) {
 	o.customer_name,
 	o.status,
 	o.created_at,
+	o.estimated_ready_at,
+	CASE WHEN o.status = 'open' THEN o.reserved_until END,
+	o.menu_version_id,
 	oi.product_id,
-	oi.quantity
+	oi.variant_id,
+	oi.quantity,
+	m.modifiers,
+	c.slots,
+	c.components,
+	la.allergens,
+	ln.kcal,
+	ln.sugar,
+	ln.fat,
+	ln.caffeine
 FROM orders o
 LEFT JOIN order_items oi ON o.order_id = oi.order_id
+LEFT JOIN LATERAL (
+	SELECT array_agg(option_id) AS modifiers
+	FROM order_item_modifiers
+	WHERE order_item_id = oi.order_item_id
+) m ON TRUE
+LEFT JOIN LATERAL (
+	SELECT
+		array_agg(COALESCE(slot_id, 0) ORDER BY order_item_component_id) AS slots,
+		array_agg(product_id ORDER BY order_item_component_id) AS components
+	FROM order_item_components
+	WHERE order_item_id = oi.order_item_id
+) c ON TRUE
+LEFT JOIN order_line_allergens la ON oi.order_item_id = la.order_item_id
+LEFT JOIN order_line_nutrition ln ON oi.order_item_id = ln.order_item_id
 WHERE o.order_id = $1;
 	`
 	rows, err := r.newDB.Db.Query(query, id)
@@ -28,16 +57,33 @@ WHERE o.order_id = $1;
 	defer rows.Close()
 	oneOrder.Items = []models.OrderItem{}
 	for rows.Next() {
-		var productId sql.NullInt64
+		var productId, variantId sql.NullInt64
 		var quantity, orderId sql.NullInt64
 		var customerName, status, createdAt string
+		var readyAt, reservedUntil sql.NullString
+		var menuVersionId sql.NullInt64
+		var modifiers, slots, components pq.Int64Array
+		var allergens pq.StringArray
+		var kcal, sugar, fat, caffeine sql.NullFloat64
 		err := rows.Scan(
 			&orderId,
 			&customerName,
 			&status,
 			&createdAt,
+			&readyAt,
+			&reservedUntil,
+			&menuVersionId,
 			&productId,
+			&variantId,
 			&quantity,
+			&modifiers,
+			&slots,
+			&components,
+			&allergens,
+			&kcal,
+			&sugar,
+			&fat,
+			&caffeine,
 		)
 		if err != nil {
 			return oneOrder, err
@@ -47,16 +93,36 @@ WHERE o.order_id = $1;
 			oneOrder.CustomerName = customerName
 			oneOrder.Status = status
 			oneOrder.CreatedAt = createdAt
+			if readyAt.Valid {
+				oneOrder.EstimatedReadyAt = &readyAt.String
+			}
+			if reservedUntil.Valid {
+				oneOrder.ReservedUntil = &reservedUntil.String
+			}
+			if menuVersionId.Valid {
+				version := int(menuVersionId.Int64)
+				oneOrder.MenuVersionID = &version
+			}
 		}
 
-		oneOrder.Items = append(oneOrder.Items, models.OrderItem{
-			ProductID: int(productId.Int64),
-			Quantity:  int(quantity.Int64),
-		})
+		orderItem := models.OrderItem{
+			ProductID:  int(productId.Int64),
+			Quantity:   int(quantity.Int64),
+			Modifiers:  SqlDataBase.ToIntSlice(modifiers),
+			Components: toComponents(slots, components),
+			Allergens:  allergens,
+			Nutrition:  lineNutrition(kcal, sugar, fat, caffeine),
+		}
+		if variantId.Valid {
+			variant := int(variantId.Int64)
+			orderItem.VariantID = &variant
+		}
+		oneOrder.Items = append(oneOrder.Items, orderItem)
 
 	}
 	if oneOrder.ID == 0 {
 		return oneOrder, fmt.Errorf("order with ID %d not found", id)
 	}
+	oneOrder.AllergenWarnings = orderAllergens(oneOrder.Items)
 	return oneOrder, nil
 }
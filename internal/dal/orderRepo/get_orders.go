@@ -3,7 +3,10 @@ package orderRepo
 import (
 	"database/sql"
 
+	"frapuccino/internal/dal/SqlDataBase"
 	"frapuccino/models"
+
+	"github.com/lib/pq"
 )
 
 func (r orderRepository) ParseOrders() ([]models.Order, error) {
@@ -13,10 +16,36 @@ func (r orderRepository) ParseOrders() ([]models.Order, error) {
 		o.customer_name,
 		o.status,
 		o.created_at,
+		o.estimated_ready_at,
+		CASE WHEN o.status = 'open' THEN o.reserved_until END,
+		o.menu_version_id,
 		oi.product_id,
-		oi.quantity
+		oi.variant_id,
+		oi.quantity,
+		m.modifiers,
+		c.slots,
+		c.components,
+		la.allergens,
+		ln.kcal,
+		ln.sugar,
+		ln.fat,
+		ln.caffeine
 	FROM orders o
-	LEFT JOIN order_items oi ON o.order_id = oi.order_id;
+	LEFT JOIN order_items oi ON o.order_id = oi.order_id
+	LEFT JOIN LATERAL (
+		SELECT array_agg(option_id) AS modifiers
+		FROM order_item_modifiers
+		WHERE order_item_id = oi.order_item_id
+	) m ON TRUE
+	LEFT JOIN LATERAL (
+		SELECT
+			array_agg(COALESCE(slot_id, 0) ORDER BY order_item_component_id) AS slots,
+			array_agg(product_id ORDER BY order_item_component_id) AS components
+		FROM order_item_components
+		WHERE order_item_id = oi.order_item_id
+	) c ON TRUE
+	LEFT JOIN order_line_allergens la ON oi.order_item_id = la.order_item_id
+	LEFT JOIN order_line_nutrition ln ON oi.order_item_id = ln.order_item_id;
 `
 
 	rows, err := r.newDB.Db.Query(query)
@@ -28,15 +57,32 @@ func (r orderRepository) ParseOrders() ([]models.Order, error) {
 	for rows.Next() {
 		orderId := 0
 		var customerName, status, createdAt string
-		var quantity, productID sql.NullInt64
+		var quantity, productID, variantID sql.NullInt64
+		var readyAt, reservedUntil sql.NullString
+		var menuVersionId sql.NullInt64
+		var modifiers, slots, components pq.Int64Array
+		var allergens pq.StringArray
+		var kcal, sugar, fat, caffeine sql.NullFloat64
 
 		err = rows.Scan(
 			&orderId,
 			&customerName,
 			&status,
 			&createdAt,
+			&readyAt,
+			&reservedUntil,
+			&menuVersionId,
 			&productID,
+			&variantID,
 			&quantity,
+			&modifiers,
+			&slots,
+			&components,
+			&allergens,
+			&kcal,
+			&sugar,
+			&fat,
+			&caffeine,
 		)
 		if err != nil {
 			return nil, err
@@ -49,16 +95,36 @@ func (r orderRepository) ParseOrders() ([]models.Order, error) {
 				CreatedAt:    createdAt,
 				Items:        []models.OrderItem{},
 			}
+			if readyAt.Valid {
+				orderMap[orderId].EstimatedReadyAt = &readyAt.String
+			}
+			if reservedUntil.Valid {
+				orderMap[orderId].ReservedUntil = &reservedUntil.String
+			}
+			if menuVersionId.Valid {
+				version := int(menuVersionId.Int64)
+				orderMap[orderId].MenuVersionID = &version
+			}
 		}
 
-		orderMap[orderId].Items = append(orderMap[orderId].Items, models.OrderItem{
-			ProductID: int(productID.Int64),
-			Quantity:  int(quantity.Int64),
-		})
+		orderItem := models.OrderItem{
+			ProductID:  int(productID.Int64),
+			Quantity:   int(quantity.Int64),
+			Modifiers:  SqlDataBase.ToIntSlice(modifiers),
+			Components: toComponents(slots, components),
+			Allergens:  allergens,
+			Nutrition:  lineNutrition(kcal, sugar, fat, caffeine),
+		}
+		if variantID.Valid {
+			variant := int(variantID.Int64)
+			orderItem.VariantID = &variant
+		}
+		orderMap[orderId].Items = append(orderMap[orderId].Items, orderItem)
 
 	}
 
 	for _, order := range orderMap {
+		order.AllergenWarnings = orderAllergens(order.Items)
 		allOrders = append(allOrders, *order)
 	}
 
@@ -0,0 +1,9 @@
+package orderRepo
+
+import "database/sql"
+
+// Tags the inventory movements of the rest of the transaction with a reason and order for the inventory_transactions ledger
+func setMovement(tx *sql.Tx, reason string, orderID int) error {
+	_, err := tx.Exec(`SELECT set_inventory_movement($1, $2)`, reason, orderID)
+	return err
+}
@@ -0,0 +1,75 @@
+package orderRepo
+
+import (
+	"database/sql"
+
+	"frapuccino/models"
+)
+
+// Returns the modifier groups attached to a menu item with the IDs of their options
+func (r *orderRepository) GetModifierRules(productID int) ([]models.ModifierGroup, error) {
+	stmt := `
+	SELECT g.group_id, g.name, g.min_select, g.max_select, o.option_id
+	FROM menu_item_modifier_groups mg
+	JOIN modifier_groups g ON mg.group_id = g.group_id
+	LEFT JOIN modifier_options o ON g.group_id = o.group_id
+	WHERE mg.product_id = $1
+	ORDER BY g.group_id, o.option_id;
+	`
+	rows, err := r.newDB.Db.Query(stmt, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := []models.ModifierGroup{}
+	for rows.Next() {
+		var group models.ModifierGroup
+		var optionId sql.NullInt64
+		err := rows.Scan(&group.GroupID, &group.Name, &group.MinSelect, &group.MaxSelect, &optionId)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) == 0 || groups[len(groups)-1].GroupID != group.GroupID {
+			groups = append(groups, group)
+		}
+		if optionId.Valid {
+			current := &groups[len(groups)-1]
+			current.Options = append(current.Options, models.ModifierOption{OptionID: int(optionId.Int64)})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// Inserts the lines of an order together with their selected modifier options and bundle components
+func (r *orderRepository) insertOrderItems(tx *sql.Tx, orderID int, items []models.OrderItem) error {
+	itemStmt := `
+	INSERT INTO order_items (order_id, product_id, variant_id, quantity)
+			VALUES ($1, $2, $3, $4)
+	RETURNING order_item_id;
+	`
+	modifierStmt := `
+	INSERT INTO order_item_modifiers (order_item_id, option_id)
+			VALUES ($1, $2);
+	`
+	for _, item := range items {
+		var orderItemID int
+		err := tx.QueryRow(itemStmt, orderID, item.ProductID, item.VariantID, item.Quantity).Scan(&orderItemID)
+		if err != nil {
+			return err
+		}
+		for _, optionID := range item.Modifiers {
+			_, err = tx.Exec(modifierStmt, orderItemID, optionID)
+			if err != nil {
+				return err
+			}
+		}
+		err = insertOrderComponents(tx, orderItemID, item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
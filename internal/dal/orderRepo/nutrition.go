@@ -0,0 +1,20 @@
+package orderRepo
+
+import (
+	"database/sql"
+
+	"frapuccino/models"
+)
+
+// Builds the per-serving nutrition of an order line; lines whose product has no recipe get none
+func lineNutrition(kcal, sugar, fat, caffeine sql.NullFloat64) *models.Nutrition {
+	if !kcal.Valid {
+		return nil
+	}
+	return &models.Nutrition{
+		Kcal:     kcal.Float64,
+		Sugar:    sugar.Float64,
+		Fat:      fat.Float64,
+		Caffeine: caffeine.Float64,
+	}
+}
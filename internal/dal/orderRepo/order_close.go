@@ -26,7 +26,7 @@ func (r orderRepository) OrderClose(id int) error {
 	stmt := `
 UPDATE orders
 SET status = 'close'
-WHERE order_id = $1 AND status != 'close';
+WHERE order_id = $1 AND status = 'open';
 `
 	res, err := tx.Exec(stmt, id)
 	if err != nil {
@@ -37,7 +37,15 @@ WHERE order_id = $1 AND status != 'close';
 		return err
 	}
 	if rowsAff == 0 {
-		return errors.New("order not found or already closed")
+		return errors.New("order not found or not open")
+	}
+	err = r.consumeReservation(tx, id)
+	if err != nil {
+		return err
+	}
+	err = r.RefreshETA(tx, nil)
+	if err != nil {
+		return err
 	}
 	return nil
 }
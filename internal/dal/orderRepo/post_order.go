@@ -5,18 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"frapuccino/internal/dal/SqlDataBase"
 	"frapuccino/models"
 
 	"github.com/lib/pq"
 )
 
-func (r *orderRepository) WriteDBNewOrder(body models.Order) error {
-	err := r.checkOrdersInMenu(body)
-	if err != nil {
-		return err
-	}
-
+// Writes a new order and takes its ingredients out of the available stock, reserving them for the given hold
+func (r *orderRepository) WriteDBNewOrder(body models.Order, hold time.Duration) (int, error) {
 	stmt := `
 	INSERT INTO orders (customer_name,  status)
 			VALUES ($1, $2)
@@ -24,7 +22,7 @@ func (r *orderRepository) WriteDBNewOrder(body models.Order) error {
 	`
 	tx, err := r.newDB.Db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() {
 		if err != nil {
@@ -33,31 +31,33 @@ func (r *orderRepository) WriteDBNewOrder(body models.Order) error {
 			tx.Commit()
 		}
 	}()
+	err = r.checkOrdersInMenu(tx, body)
+	if err != nil {
+		return 0, err
+	}
 	row := tx.QueryRow(stmt, body.CustomerName, body.Status)
 	err = row.Scan(&body.ID)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	stmt1 := `
-	INSERT INTO order_items (order_id, product_id,  quantity)
-			VALUES ($1, $2, $3);
-	`
-	for _, item := range body.Items {
-		_, err = tx.Exec(stmt1, body.ID, item.ProductID, item.Quantity)
-		if err != nil {
-			return err
-		}
+	err = r.insertOrderItems(tx, body.ID, body.Items)
+	if err != nil {
+		return 0, err
 	}
-	err = r.CheckIngredients(tx, body)
+	err = r.CheckIngredients(tx, body, hold)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	err = r.RefreshETA(tx, &body.ID)
+	if err != nil {
+		return 0, err
+	}
+	return body.ID, nil
 }
 
 // Writes a new order to the JSON file and creates a backup in the reserve copy
 
-func (r *orderRepository) checkOrdersInMenu(body models.Order) error {
+func (r *orderRepository) checkOrdersInMenu(tx *sql.Tx, body models.Order) error {
 	stmt := `
 	WITH new_order AS (
 		SELECT UNNEST($1::INT[]) AS product_id
@@ -67,15 +67,18 @@ func (r *orderRepository) checkOrdersInMenu(body models.Order) error {
 	WHERE NOT EXISTS (
 		SELECT 1
 		FROM menu_items
-		WHERE menu_items.product_id = new_order.product_id
+		WHERE menu_items.product_id = new_order.product_id AND menu_items.archived_at IS NULL
 	);
 	`
 
 	productsIds := []int{}
 	for _, item := range body.Items {
 		productsIds = append(productsIds, item.ProductID)
+		for _, component := range item.Components {
+			productsIds = append(productsIds, component.ProductID)
+		}
 	}
-	rows, err := r.newDB.Db.Query(stmt, pq.Array(productsIds))
+	rows, err := tx.Query(stmt, pq.Array(productsIds))
 	if err != nil {
 		return err
 	}
@@ -99,26 +102,32 @@ func (r *orderRepository) checkOrdersInMenu(body models.Order) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return SqlDataBase.CheckOrdersInWindow(tx, productsIds)
 }
 
-func (r *orderRepository) CheckIngredients(tx *sql.Tx, body models.Order) error {
+// Verifies that the available stock, net of other orders' reservations, covers the order before taking its ingredients
+func (r *orderRepository) CheckIngredients(tx *sql.Tx, body models.Order, hold time.Duration) error {
 	stmt := `
 	WITH required_ingredients AS (
-		SELECT mi.ingredient_id, mi.quantity * oi.quantity AS required_quantity
-		FROM menu_item_ingredients mi
-		JOIN order_items oi ON mi.product_id = oi.product_id
-		WHERE oi.order_id = $1
+		SELECT ingredient_id, SUM(quantity) AS required_quantity
+		FROM order_line_ingredients
+		WHERE order_id = $1
+		GROUP BY ingredient_id
+		HAVING SUM(quantity) > 0
 	),
 	insufficient_ingredients AS (
-		SELECT ri.ingredient_id, i.quantity AS available_quantity, ri.required_quantity
+		SELECT ri.ingredient_id, s.available AS available_quantity, ri.required_quantity
 		FROM required_ingredients ri
-		JOIN inventory i ON ri.ingredient_id = i.ingredient_id
-		WHERE ri.required_quantity > i.quantity
+		JOIN inventory_stock s ON ri.ingredient_id = s.ingredient_id
+		WHERE ri.required_quantity > s.available
 	)
 	SELECT ingredient_id
 	FROM insufficient_ingredients;
 	`
+	err := SqlDataBase.LockOrderIngredients(tx, body.ID)
+	if err != nil {
+		return err
+	}
 	rows, err := tx.Query(stmt, body.ID)
 	if err != nil {
 		return err
@@ -138,7 +147,7 @@ func (r *orderRepository) CheckIngredients(tx *sql.Tx, body models.Order) error
 		miss := fmt.Sprintf("Insufficient ingredients: %v", missingIngredients)
 		return errors.New(miss)
 	}
-	err = r.deductInventory(tx, body.ID)
+	err = r.takeStock(tx, body.ID, hold)
 	if err != nil {
 		return err
 	}
@@ -150,16 +159,20 @@ func (r *orderRepository) deductInventory(tx *sql.Tx, orderID int) error {
 		UPDATE inventory
 		SET quantity = quantity - ri.required_quantity
 		FROM (
-			SELECT mi.ingredient_id, SUM(mi.quantity * oi.quantity) AS required_quantity
-			FROM menu_item_ingredients mi
-			JOIN order_items oi ON mi.product_id = oi.product_id
-			WHERE oi.order_id = $1
-			GROUP BY mi.ingredient_id
+			SELECT ingredient_id, SUM(quantity) AS required_quantity
+			FROM order_line_ingredients
+			WHERE order_id = $1
+			GROUP BY ingredient_id
+			HAVING SUM(quantity) > 0
 		) AS ri
 		WHERE inventory.ingredient_id = ri.ingredient_id;
 	`
 
-	_, err := tx.Exec(stmt, orderID)
+	err := setMovement(tx, "order", orderID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(stmt, orderID)
 	if err != nil {
 		return fmt.Errorf("failed to deduct inventory: %w", err)
 	}
@@ -0,0 +1,125 @@
+package orderRepo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Takes the ingredients of an order out of the available stock. A positive hold reserves them until the order
+// is closed, cancelled or the hold runs out; a zero hold deducts them from the inventory straight away.
+func (r *orderRepository) takeStock(tx *sql.Tx, orderID int, hold time.Duration) error {
+	stmt := `
+	UPDATE orders
+	SET reserved_until = CASE WHEN $2::FLOAT8 > 0 THEN CURRENT_TIMESTAMP + make_interval(secs => $2::FLOAT8) END
+	WHERE order_id = $1;
+	`
+	_, err := tx.Exec(stmt, orderID, hold.Seconds())
+	if err != nil {
+		return err
+	}
+	if hold <= 0 {
+		return r.deductInventory(tx, orderID)
+	}
+	reserveStmt := `
+	INSERT INTO inventory_reservations (order_id, ingredient_id, quantity)
+	SELECT order_id, ingredient_id, SUM(quantity)
+	FROM order_line_ingredients
+	WHERE order_id = $1
+	GROUP BY order_id, ingredient_id
+	HAVING SUM(quantity) > 0;
+	`
+	_, err = tx.Exec(reserveStmt, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to reserve inventory: %w", err)
+	}
+	return nil
+}
+
+// Gives the ingredients of an open order back to the available stock, dropping its reservation or
+// restocking the inventory when they were deducted on opening
+func (r *orderRepository) releaseStock(tx *sql.Tx, orderID int) error {
+	var reserved bool
+	err := tx.QueryRow(`SELECT reserved_until IS NOT NULL FROM orders WHERE order_id = $1`, orderID).Scan(&reserved)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return r.RestockInventory(tx, orderID)
+	}
+	_, err = tx.Exec(`DELETE FROM inventory_reservations WHERE order_id = $1`, orderID)
+	return err
+}
+
+// Deducts the reserved ingredients of a closing order from the inventory. Waste, adjustments and counts cannot
+// take reserved stock, so the reservation is always on hand.
+func (r *orderRepository) consumeReservation(tx *sql.Tx, orderID int) error {
+	stmt := `
+	UPDATE inventory
+	SET quantity = inventory.quantity - r.quantity
+	FROM inventory_reservations r
+	WHERE r.order_id = $1 AND inventory.ingredient_id = r.ingredient_id;
+	`
+	err := setMovement(tx, "order", orderID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(stmt, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to consume reserved inventory: %w", err)
+	}
+	_, err = tx.Exec(`DELETE FROM inventory_reservations WHERE order_id = $1`, orderID)
+	return err
+}
+
+// Rejects open orders whose reservation has run out and releases their ingredients
+func (r *orderRepository) ExpireReservations() ([]int, error) {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	stmt := `
+	UPDATE orders
+	SET status = 'rejected'
+	WHERE status = 'open' AND reserved_until < CURRENT_TIMESTAMP
+	RETURNING order_id;
+	`
+	rows, err := tx.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+	_, err = tx.Exec(`DELETE FROM inventory_reservations WHERE order_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	err = r.RefreshETA(tx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
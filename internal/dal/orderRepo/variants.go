@@ -0,0 +1,22 @@
+package orderRepo
+
+import (
+	"frapuccino/internal/dal/SqlDataBase"
+
+	"github.com/lib/pq"
+)
+
+// Returns the IDs of the variants of a menu item that can still be ordered, leaving out archived ones
+func (r *orderRepository) GetVariantIDs(productID int) ([]int, error) {
+	stmt := `
+	SELECT COALESCE(array_agg(variant_id ORDER BY variant_id), '{}')
+	FROM menu_item_variants
+	WHERE product_id = $1 AND archived_at IS NULL;
+	`
+	var ids pq.Int64Array
+	err := r.newDB.Db.QueryRow(stmt, productID).Scan(&ids)
+	if err != nil {
+		return nil, err
+	}
+	return SqlDataBase.ToIntSlice(ids), nil
+}
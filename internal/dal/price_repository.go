@@ -0,0 +1,170 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"frapuccino/models"
+)
+
+func (r *jsonMenuRepository) GetPriceHistory(id int) ([]models.PriceHistory, error) {
+	query := `
+	SELECT history_id, product_id, old_price, new_price, updated_at
+	FROM price_history
+	WHERE product_id = $1
+	ORDER BY updated_at DESC, history_id DESC;
+	`
+	rows, err := r.newDB.Db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := []models.PriceHistory{}
+	for rows.Next() {
+		var item models.PriceHistory
+		err := rows.Scan(&item.HistoryID, &item.ProductID, &item.OldPrice, &item.NewPrice, &item.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *jsonMenuRepository) PostScheduledPrice(change models.ScheduledPrice) (int, error) {
+	var scheduleId int
+	stmt := `
+	INSERT INTO scheduled_price_changes (product_id, new_price, effective_at)
+	VALUES ($1, $2, $3::TIMESTAMPTZ::TIMESTAMP)
+	RETURNING schedule_id;
+	`
+	err := r.newDB.Db.QueryRow(stmt, change.ProductID, change.NewPrice, change.EffectiveAt).Scan(&scheduleId)
+	if err != nil {
+		return 0, err
+	}
+	return scheduleId, nil
+}
+
+func (r *jsonMenuRepository) GetScheduledPrices(productId int) ([]models.ScheduledPrice, error) {
+	query := `
+	SELECT schedule_id, product_id, new_price, effective_at, applied_at, last_error, attempts, failed_at
+	FROM scheduled_price_changes
+	WHERE product_id = $1
+	ORDER BY effective_at, schedule_id;
+	`
+	return r.readScheduledPrices(query, productId)
+}
+
+// Lists pending price changes whose effective time has passed, leaving out those that failed for good
+func (r *jsonMenuRepository) GetDuePriceChanges() ([]models.ScheduledPrice, error) {
+	query := `
+	SELECT schedule_id, product_id, new_price, effective_at, applied_at, last_error, attempts, failed_at
+	FROM scheduled_price_changes
+	WHERE applied_at IS NULL AND failed_at IS NULL AND effective_at <= LOCALTIMESTAMP
+	ORDER BY effective_at, schedule_id;
+	`
+	return r.readScheduledPrices(query)
+}
+
+func (r *jsonMenuRepository) readScheduledPrices(query string, args ...any) ([]models.ScheduledPrice, error) {
+	rows, err := r.newDB.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	changes := []models.ScheduledPrice{}
+	for rows.Next() {
+		var change models.ScheduledPrice
+		var appliedAt, lastError, failedAt sql.NullString
+		err := rows.Scan(
+			&change.ScheduleID,
+			&change.ProductID,
+			&change.NewPrice,
+			&change.EffectiveAt,
+			&appliedAt,
+			&lastError,
+			&change.Attempts,
+			&failedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if appliedAt.Valid {
+			change.AppliedAt = &appliedAt.String
+		}
+		if lastError.Valid {
+			change.LastError = &lastError.String
+		}
+		change.FailedAt = nullableString(failedAt)
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Deletes a scheduled price change that has not been applied yet
+func (r *jsonMenuRepository) DeleteScheduledPrice(productId, scheduleId int) error {
+	stmt := `
+	DELETE FROM scheduled_price_changes
+	WHERE schedule_id = $1 AND product_id = $2 AND applied_at IS NULL;
+	`
+	res, err := r.newDB.Db.Exec(stmt, scheduleId, productId)
+	if err != nil {
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		return fmt.Errorf("pending price change with ID %d not found", scheduleId)
+	}
+	return nil
+}
+
+// Sets the price of a menu item on the menu, leaving the rest of it alone, and returns the price it replaced
+func (r *jsonMenuRepository) UpdateMenuPrice(productId int, price float64) (float64, error) {
+	stmt := `
+	UPDATE menu_items mi
+	SET price = $1
+	FROM (SELECT product_id, price FROM menu_items WHERE product_id = $2 FOR UPDATE) old
+	WHERE mi.product_id = old.product_id AND mi.archived_at IS NULL
+	RETURNING old.price;
+	`
+	var oldPrice float64
+	err := r.newDB.Db.QueryRow(stmt, price, productId).Scan(&oldPrice)
+	if err == sql.ErrNoRows {
+		return 0, ErrMenuItemNotOnMenu
+	}
+	if err != nil {
+		return 0, err
+	}
+	return oldPrice, nil
+}
+
+// Records the outcome of applying a scheduled price change; a failed change stays pending with its error until it
+// has failed maxAttempts times, after which it is marked failed and no longer retried
+func (r *jsonMenuRepository) MarkScheduledPrice(scheduleId int, applyErr error, maxAttempts int) error {
+	if applyErr != nil {
+		failStmt := `
+		UPDATE scheduled_price_changes
+		SET last_error = $1, attempts = attempts + 1,
+			failed_at = CASE WHEN attempts + 1 >= $3 THEN CURRENT_TIMESTAMP END
+		WHERE schedule_id = $2;
+		`
+		_, err := r.newDB.Db.Exec(failStmt, applyErr.Error(), scheduleId, maxAttempts)
+		return err
+	}
+	stmt := `
+	UPDATE scheduled_price_changes
+	SET applied_at = CURRENT_TIMESTAMP, last_error = NULL
+	WHERE schedule_id = $1;
+	`
+	_, err := r.newDB.Db.Exec(stmt, scheduleId)
+	return err
+}
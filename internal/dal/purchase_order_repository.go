@@ -0,0 +1,487 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// Returned when a purchase order is not in a state that allows the requested change
+var ErrPurchaseOrderStatus = errors.New("purchase order is not in a state that allows this change")
+
+// PurchaseOrderRepository defines the methods for purchase orders, goods receiving and reorder suggestions
+type PurchaseOrderRepository interface {
+	PostPurchaseOrder(order models.PurchaseOrder) (int, error)
+	PostPurchaseOrders(orders []models.PurchaseOrder) ([]int, error)
+	GetPurchaseOrders(status string) ([]models.PurchaseOrder, error)
+	GetPurchaseOrderID(id int) (models.PurchaseOrder, error)
+	UpdatePurchaseOrder(id int, order models.PurchaseOrder) error
+	DeletePurchaseOrder(id int) error
+	SendPurchaseOrder(id int) error
+	ReceivePurchaseOrder(id int, receipt models.PurchaseOrderReceipt) error
+	GetReorderSuggestions() ([]models.ReorderSuggestionLine, error)
+}
+
+type purchaseOrderRepository struct {
+	newDB *SqlDataBase.DB
+}
+
+// NewPurchaseOrderRepository creates and returns a new instance of purchaseOrderRepository
+func NewPurchaseOrderRepository(db *SqlDataBase.DB) PurchaseOrderRepository {
+	return &purchaseOrderRepository{newDB: db}
+}
+
+// Creates a draft purchase order with its lines
+func (r *purchaseOrderRepository) PostPurchaseOrder(order models.PurchaseOrder) (int, error) {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	id, err := insertPurchaseOrder(tx, order)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Creates several draft purchase orders in one transaction, so either all of them are created or none
+func (r *purchaseOrderRepository) PostPurchaseOrders(orders []models.PurchaseOrder) ([]int, error) {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	ids := []int{}
+	for _, order := range orders {
+		var id int
+		id, err = insertPurchaseOrder(tx, order)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Inserts a draft purchase order with its lines
+func insertPurchaseOrder(tx *sql.Tx, order models.PurchaseOrder) (int, error) {
+	var id int
+	err := tx.QueryRow(`
+	INSERT INTO purchase_orders (supplier_id, expected_at)
+	VALUES ($1, $2)
+	RETURNING purchase_order_id;
+	`, order.SupplierID, order.ExpectedAt).Scan(&id)
+	if err != nil {
+		return 0, purchaseOrderError(err)
+	}
+	err = savePurchaseOrderLines(tx, id, order.SupplierID, order.Lines)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Lists purchase orders, newest first, optionally only those in one status
+func (r *purchaseOrderRepository) GetPurchaseOrders(status string) ([]models.PurchaseOrder, error) {
+	query := `
+	SELECT po.purchase_order_id, po.supplier_id, s.name, po.status, po.expected_at, po.created_at, po.sent_at, po.received_at
+	FROM purchase_orders po
+	JOIN suppliers s ON po.supplier_id = s.supplier_id
+	WHERE $1 = '' OR po.status::TEXT = $1
+	ORDER BY po.created_at DESC, po.purchase_order_id DESC;
+	`
+	return r.readPurchaseOrders(query, status)
+}
+
+func (r *purchaseOrderRepository) GetPurchaseOrderID(id int) (models.PurchaseOrder, error) {
+	query := `
+	SELECT po.purchase_order_id, po.supplier_id, s.name, po.status, po.expected_at, po.created_at, po.sent_at, po.received_at
+	FROM purchase_orders po
+	JOIN suppliers s ON po.supplier_id = s.supplier_id
+	WHERE po.purchase_order_id = $1;
+	`
+	orders, err := r.readPurchaseOrders(query, id)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	if len(orders) == 0 {
+		return models.PurchaseOrder{}, fmt.Errorf("purchase order with ID %d not found", id)
+	}
+	return orders[0], nil
+}
+
+// Replaces the supplier, expected date and lines of a draft
+func (r *purchaseOrderRepository) UpdatePurchaseOrder(id int, order models.PurchaseOrder) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = lockPurchaseOrder(tx, id, "draft")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE purchase_orders SET supplier_id = $2, expected_at = $3 WHERE purchase_order_id = $1;
+	`, id, order.SupplierID, order.ExpectedAt)
+	if err != nil {
+		err = purchaseOrderError(err)
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM purchase_order_lines WHERE purchase_order_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return savePurchaseOrderLines(tx, id, order.SupplierID, order.Lines)
+}
+
+// Deletes a draft; orders sent to the supplier are kept
+func (r *purchaseOrderRepository) DeletePurchaseOrder(id int) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = lockPurchaseOrder(tx, id, "draft")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM purchase_orders WHERE purchase_order_id = $1`, id)
+	return err
+}
+
+// Marks a draft as sent; without an expected date it is due after the supplier's lead time
+func (r *purchaseOrderRepository) SendPurchaseOrder(id int) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = lockPurchaseOrder(tx, id, "draft")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE purchase_orders po
+	SET status = 'sent',
+		sent_at = CURRENT_TIMESTAMP,
+		expected_at = COALESCE(po.expected_at, CURRENT_DATE + s.lead_time_days)
+	FROM suppliers s
+	WHERE po.supplier_id = s.supplier_id AND po.purchase_order_id = $1;
+	`, id)
+	return err
+}
+
+// Books goods received against a sent order: each line adds to the stock through the ledger under 'purchase order' as
+// a new lot, the ingredient takes the line's unit cost, and the order becomes partially received or received
+func (r *purchaseOrderRepository) ReceivePurchaseOrder(id int, receipt models.PurchaseOrderReceipt) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = lockPurchaseOrder(tx, id, "sent", "partially_received")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`SELECT set_inventory_movement($1, NULL)`, "purchase order")
+	if err != nil {
+		return err
+	}
+	for _, line := range receipt.Lines {
+		var lineId int
+		var unitCost, remaining float64
+		err = tx.QueryRow(`
+		SELECT line_id, unit_cost, quantity - received_quantity
+		FROM purchase_order_lines
+		WHERE purchase_order_id = $1 AND ingredient_id = $2
+		FOR UPDATE;
+		`, id, line.IngredientID).Scan(&lineId, &unitCost, &remaining)
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("ingredient with ID %d is not on purchase order %d", line.IngredientID, id)
+		}
+		if err != nil {
+			return err
+		}
+		if line.Quantity > remaining {
+			err = fmt.Errorf("received quantity of ingredient with ID %d exceeds the %g still outstanding", line.IngredientID, remaining)
+			return err
+		}
+		_, err = tx.Exec(`
+		UPDATE purchase_order_lines SET received_quantity = received_quantity + $2 WHERE line_id = $1;
+		`, lineId, line.Quantity)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+		INSERT INTO purchase_order_receipts (line_id, quantity, unit_cost) VALUES ($1, $2, $3);
+		`, lineId, line.Quantity, unitCost)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`SELECT set_inventory_lot($1)`, line.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+		UPDATE inventory SET quantity = quantity + $2, price = $3 WHERE ingredient_id = $1;
+		`, line.IngredientID, line.Quantity, unitCost)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`
+	UPDATE purchase_orders
+	SET status = CASE WHEN outstanding THEN 'partially_received' ELSE 'received' END::purchase_order_status,
+		received_at = CASE WHEN outstanding THEN NULL ELSE CURRENT_TIMESTAMP END
+	FROM (
+		SELECT bool_or(received_quantity < quantity) AS outstanding
+		FROM purchase_order_lines
+		WHERE purchase_order_id = $1
+	) lines
+	WHERE purchase_order_id = $1;
+	`, id)
+	return err
+}
+
+// Lists ingredients with a par level whose stock plus open purchase orders is at or below the reorder point (or
+// short of par when there is no reorder point), with the quantity that tops them up to par and the cheapest supplier
+func (r *purchaseOrderRepository) GetReorderSuggestions() ([]models.ReorderSuggestionLine, error) {
+	query := `
+	WITH on_order AS (
+		SELECT l.ingredient_id, SUM(l.quantity - l.received_quantity) AS quantity
+		FROM purchase_order_lines l
+		JOIN purchase_orders po ON l.purchase_order_id = po.purchase_order_id
+		WHERE po.status IN ('sent', 'partially_received')
+		GROUP BY l.ingredient_id
+	)
+	SELECT
+		i.ingredient_id,
+		i.name,
+		i.unit,
+		i.quantity,
+		COALESCE(o.quantity, 0),
+		i.reorder_point,
+		i.par_level,
+		i.par_level - i.quantity - COALESCE(o.quantity, 0),
+		best.unit_cost,
+		best.supplier_id,
+		COALESCE(best.name, ''),
+		best.lead_time_days
+	FROM inventory i
+	LEFT JOIN on_order o ON i.ingredient_id = o.ingredient_id
+	LEFT JOIN LATERAL (
+		SELECT si.unit_cost, s.supplier_id, s.name, s.lead_time_days
+		FROM supplier_ingredients si
+		JOIN suppliers s ON si.supplier_id = s.supplier_id
+		WHERE si.ingredient_id = i.ingredient_id
+		ORDER BY si.unit_cost, s.lead_time_days, s.supplier_id
+		LIMIT 1
+	) best ON TRUE
+	WHERE i.archived_at IS NULL
+		AND i.par_level IS NOT NULL
+		AND (i.reorder_point IS NULL OR i.quantity + COALESCE(o.quantity, 0) <= i.reorder_point)
+		AND i.par_level - i.quantity - COALESCE(o.quantity, 0) > 0
+	ORDER BY best.name NULLS LAST, i.name;
+	`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []models.ReorderSuggestionLine{}
+	for rows.Next() {
+		var line models.ReorderSuggestionLine
+		var reorderPoint, unitCost sql.NullFloat64
+		var supplierId, leadTime sql.NullInt64
+		err := rows.Scan(
+			&line.IngredientID,
+			&line.Name,
+			&line.Unit,
+			&line.Quantity,
+			&line.OnOrder,
+			&reorderPoint,
+			&line.ParLevel,
+			&line.SuggestedQuantity,
+			&unitCost,
+			&supplierId,
+			&line.SupplierName,
+			&leadTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		line.ReorderPoint = nullableFloat(reorderPoint)
+		line.UnitCost = nullableFloat(unitCost)
+		line.SupplierID = nullableInt(supplierId)
+		line.LeadTimeDays = nullableInt(leadTime)
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// Inserts the lines of a purchase order; a line without a unit cost takes the supplier's price for the ingredient,
+// or its current cost when the supplier does not list it
+func savePurchaseOrderLines(tx *sql.Tx, orderId, supplierId int, lines []models.PurchaseOrderLine) error {
+	stmt := `
+	INSERT INTO purchase_order_lines (purchase_order_id, ingredient_id, quantity, unit_cost)
+	SELECT $1, i.ingredient_id, $3, COALESCE(NULLIF($4, 0), si.unit_cost, i.price)
+	FROM inventory i
+	LEFT JOIN supplier_ingredients si ON si.ingredient_id = i.ingredient_id AND si.supplier_id = $5
+	WHERE i.ingredient_id = $2;
+	`
+	for _, line := range lines {
+		res, err := tx.Exec(stmt, orderId, line.IngredientID, line.Quantity, line.UnitCost, supplierId)
+		if err != nil {
+			return purchaseOrderError(err)
+		}
+		rowsAff, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAff == 0 {
+			return fmt.Errorf("inventory item with ID %d not found", line.IngredientID)
+		}
+	}
+	return nil
+}
+
+// Locks a purchase order and checks it is in one of the given states
+func lockPurchaseOrder(tx *sql.Tx, id int, statuses ...string) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM purchase_orders WHERE purchase_order_id = $1 FOR UPDATE`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("purchase order with ID %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	for _, allowed := range statuses {
+		if status == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: it is %s", ErrPurchaseOrderStatus, status)
+}
+
+func (r *purchaseOrderRepository) readPurchaseOrders(query string, args ...any) ([]models.PurchaseOrder, error) {
+	rows, err := r.newDB.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orders := []models.PurchaseOrder{}
+	index := make(map[int]int)
+	ids := []int64{}
+	for rows.Next() {
+		order := models.PurchaseOrder{Lines: []models.PurchaseOrderLine{}}
+		var expectedAt, sentAt, receivedAt sql.NullString
+		err := rows.Scan(
+			&order.PurchaseOrderID,
+			&order.SupplierID,
+			&order.SupplierName,
+			&order.Status,
+			&expectedAt,
+			&order.CreatedAt,
+			&sentAt,
+			&receivedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		order.ExpectedAt = nullableString(expectedAt)
+		order.SentAt = nullableString(sentAt)
+		order.ReceivedAt = nullableString(receivedAt)
+		index[order.PurchaseOrderID] = len(orders)
+		ids = append(ids, int64(order.PurchaseOrderID))
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	lineQuery := `
+	SELECT l.purchase_order_id, l.ingredient_id, i.name, i.unit, l.quantity, l.unit_cost, l.received_quantity
+	FROM purchase_order_lines l
+	JOIN inventory i ON l.ingredient_id = i.ingredient_id
+	WHERE l.purchase_order_id = ANY($1)
+	ORDER BY l.line_id;
+	`
+	lineRows, err := r.newDB.Db.Query(lineQuery, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer lineRows.Close()
+	for lineRows.Next() {
+		var orderId int
+		var line models.PurchaseOrderLine
+		err := lineRows.Scan(&orderId, &line.IngredientID, &line.Name, &line.Unit, &line.Quantity, &line.UnitCost, &line.ReceivedQuantity)
+		if err != nil {
+			return nil, err
+		}
+		i := index[orderId]
+		orders[i].Lines = append(orders[i].Lines, line)
+		orders[i].Total += line.Quantity * line.UnitCost
+	}
+	if err := lineRows.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func purchaseOrderError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return errors.New("ingredient is listed twice on the purchase order")
+		case "23503":
+			return errors.New("supplier or ingredient does not exist")
+		}
+	}
+	return err
+}
@@ -5,13 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"frapuccino/internal/dal/SqlDataBase"
 	"frapuccino/models"
 
 	"github.com/lib/pq"
 )
 
-func (r *searchFilterRepo) WriteDBNewOrders(bodies []models.Order) (*models.Common, error) {
+// Writes a batch of orders, reserving the ingredients of each accepted one for the given hold or deducting
+// them straight away when the hold is zero. Each order is written under a savepoint, so a rejected order leaves
+// nothing behind while the accepted ones are committed together
+func (r *searchFilterRepo) WriteDBNewOrders(bodies []models.Order, hold time.Duration) (*models.Common, error) {
 	var processOrders []models.ProcessedOrder
 	var summary models.Summary
 	var inventoryUpdates []models.InventoryUpdate
@@ -24,27 +29,20 @@ func (r *searchFilterRepo) WriteDBNewOrders(bodies []models.Order) (*models.Comm
 	defer func() {
 		if err != nil {
 			tx.Rollback()
-		} else {
-			tx.Commit()
 		}
 	}()
 	for _, body := range bodies {
-		err := r.checkOrdersInMenu(body)
+		_, err = tx.Exec(`SAVEPOINT batch_order`)
 		if err != nil {
-			stringErr := err.Error()
-			processOrders = append(processOrders, models.ProcessedOrder{
-				OrderId:      0,
-				CustomerName: body.CustomerName,
-				Status:       "rejected",
-				Total:        nil,
-				Reason:       &stringErr,
-			})
-			rejected++
-			continue
+			return nil, err
 		}
-		orderID, err := r.insertOrder(tx, body)
-		if err != nil {
-			stringErr := err.Error()
+		orderID, total, updates, orderErr := r.writeBatchOrder(tx, body, hold)
+		if orderErr != nil {
+			_, err = tx.Exec(`ROLLBACK TO SAVEPOINT batch_order`)
+			if err != nil {
+				return nil, err
+			}
+			stringErr := orderErr.Error()
 			processOrders = append(processOrders, models.ProcessedOrder{
 				OrderId:      orderID,
 				CustomerName: body.CustomerName,
@@ -55,50 +53,14 @@ func (r *searchFilterRepo) WriteDBNewOrders(bodies []models.Order) (*models.Comm
 			rejected++
 			continue
 		}
-		body.ID = orderID
-		err = r.insertOrderItems(tx, body)
+		_, err = tx.Exec(`RELEASE SAVEPOINT batch_order`)
 		if err != nil {
-			stringErr := err.Error()
-			processOrders = append(processOrders, models.ProcessedOrder{
-				OrderId:      body.ID,
-				CustomerName: body.CustomerName,
-				Status:       "rejected",
-				Total:        nil,
-				Reason:       &stringErr,
-			})
-			rejected++
-			continue
+			return nil, err
 		}
-		updates, err := r.checkIngredients(tx, body)
 		inventoryUpdates = append(inventoryUpdates, updates...)
-		if err != nil {
-			stringErr := err.Error()
-			processOrders = append(processOrders, models.ProcessedOrder{
-				OrderId:      0,
-				CustomerName: body.CustomerName,
-				Status:       "rejected",
-				Total:        nil,
-				Reason:       &stringErr,
-			})
-			rejected++
-			continue
-		}
-		total, err := r.calculateOrderTotal(tx, body.ID)
-		if err != nil {
-			stringErr := err.Error()
-			processOrders = append(processOrders, models.ProcessedOrder{
-				OrderId:      body.ID,
-				CustomerName: body.CustomerName,
-				Status:       "rejected",
-				Total:        nil,
-				Reason:       &stringErr,
-			})
-			rejected++
-			continue
-		}
 		totalRevenue += total
 		processOrders = append(processOrders, models.ProcessedOrder{
-			OrderId:      body.ID,
+			OrderId:      orderID,
 			CustomerName: body.CustomerName,
 			Status:       "accepted",
 			Total:        &total,
@@ -107,6 +69,10 @@ func (r *searchFilterRepo) WriteDBNewOrders(bodies []models.Order) (*models.Comm
 		accepted++
 
 	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
 	summary = models.Summary{
 		TotalOrders:      len(bodies),
 		Accepted:         accepted,
@@ -120,9 +86,39 @@ func (r *searchFilterRepo) WriteDBNewOrders(bodies []models.Order) (*models.Comm
 	}, nil
 }
 
+// Writes one order of a batch with its lines, stock and ETA, returning its ID (0 when it was not inserted),
+// total and the inventory it took
+func (r *searchFilterRepo) writeBatchOrder(tx *sql.Tx, body models.Order, hold time.Duration) (int, float64, []models.InventoryUpdate, error) {
+	err := r.checkOrdersInMenu(tx, body)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	body.ID, err = r.insertOrder(tx, body)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	err = r.insertOrderItems(tx, body)
+	if err != nil {
+		return body.ID, 0, nil, err
+	}
+	updates, err := r.checkIngredients(tx, body, hold)
+	if err != nil {
+		return body.ID, 0, nil, err
+	}
+	total, err := r.calculateOrderTotal(tx, body.ID)
+	if err != nil {
+		return body.ID, 0, nil, err
+	}
+	err = SqlDataBase.RefreshOrderETA(tx, &body.ID)
+	if err != nil {
+		return body.ID, 0, nil, err
+	}
+	return body.ID, total, updates, nil
+}
+
 // Writes a new order to the JSON file and creates a backup in the reserve copy
 
-func (r *searchFilterRepo) checkOrdersInMenu(body models.Order) error {
+func (r *searchFilterRepo) checkOrdersInMenu(tx *sql.Tx, body models.Order) error {
 	stmt := `
 	WITH new_order AS (
 		SELECT UNNEST($1::INT[]) AS product_id
@@ -132,15 +128,18 @@ func (r *searchFilterRepo) checkOrdersInMenu(body models.Order) error {
 	WHERE NOT EXISTS (
 		SELECT 1
 		FROM menu_items
-		WHERE menu_items.product_id = new_order.product_id
+		WHERE menu_items.product_id = new_order.product_id AND menu_items.archived_at IS NULL
 	);
 	`
 
 	productsIds := []int{}
 	for _, item := range body.Items {
 		productsIds = append(productsIds, item.ProductID)
+		for _, component := range item.Components {
+			productsIds = append(productsIds, component.ProductID)
+		}
 	}
-	rows, err := r.Db.Db.Query(stmt, pq.Array(productsIds))
+	rows, err := tx.Query(stmt, pq.Array(productsIds))
 	if err != nil {
 		return err
 	}
@@ -160,26 +159,35 @@ func (r *searchFilterRepo) checkOrdersInMenu(body models.Order) error {
 		miss := fmt.Sprintf("These items are not in menu %s", strings.Join(missingProducts, ", "))
 		return errors.New(miss)
 	}
+	err = SqlDataBase.CheckOrdersInWindow(tx, productsIds)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-func (r *searchFilterRepo) checkIngredients(tx *sql.Tx, body models.Order) ([]models.InventoryUpdate, error) {
+func (r *searchFilterRepo) checkIngredients(tx *sql.Tx, body models.Order, hold time.Duration) ([]models.InventoryUpdate, error) {
 	stmt := `
 	WITH required_ingredients AS (
-		SELECT mi.ingredient_id, mi.quantity * oi.quantity AS required_quantity
-		FROM menu_item_ingredients mi
-		JOIN order_items oi ON mi.product_id = oi.product_id
-		WHERE oi.order_id = $1
+		SELECT ingredient_id, SUM(quantity) AS required_quantity
+		FROM order_line_ingredients
+		WHERE order_id = $1
+		GROUP BY ingredient_id
+		HAVING SUM(quantity) > 0
 	),
 	insufficient_ingredients AS (
-		SELECT ri.ingredient_id, i.quantity AS available_quantity, ri.required_quantity
+		SELECT ri.ingredient_id, s.available AS available_quantity, ri.required_quantity
 		FROM required_ingredients ri
-		JOIN inventory i ON ri.ingredient_id = i.ingredient_id
-		WHERE ri.required_quantity > i.quantity
+		JOIN inventory_stock s ON ri.ingredient_id = s.ingredient_id
+		WHERE ri.required_quantity > s.available
 	)
 	SELECT ingredient_id
 	FROM insufficient_ingredients;
 	`
+	err := SqlDataBase.LockOrderIngredients(tx, body.ID)
+	if err != nil {
+		return nil, err
+	}
 	rows, err := tx.Query(stmt, body.ID)
 	if err != nil {
 		return nil, err
@@ -199,6 +207,9 @@ func (r *searchFilterRepo) checkIngredients(tx *sql.Tx, body models.Order) ([]mo
 		miss := fmt.Sprintf("Insufficient ingredients: %v", missingIngredients)
 		return nil, errors.New(miss)
 	}
+	if hold > 0 {
+		return r.reserveInventory(tx, body.ID, hold)
+	}
 	updates, err := r.deductInventory(tx, body.ID)
 	if err != nil {
 		return nil, err
@@ -206,21 +217,66 @@ func (r *searchFilterRepo) checkIngredients(tx *sql.Tx, body models.Order) ([]mo
 	return updates, nil
 }
 
+// Reserves the ingredients of an order until the hold runs out, reporting what is left available after it
+func (r *searchFilterRepo) reserveInventory(tx *sql.Tx, orderID int, hold time.Duration) ([]models.InventoryUpdate, error) {
+	stmt := `
+		WITH held AS (
+			INSERT INTO inventory_reservations (order_id, ingredient_id, quantity)
+			SELECT order_id, ingredient_id, SUM(quantity)
+			FROM order_line_ingredients
+			WHERE order_id = $1
+			GROUP BY order_id, ingredient_id
+			HAVING SUM(quantity) > 0
+			RETURNING ingredient_id, quantity
+		)
+		SELECT h.ingredient_id, i.name, h.quantity, s.available - h.quantity
+		FROM held h
+		JOIN inventory i ON h.ingredient_id = i.ingredient_id
+		JOIN inventory_stock s ON h.ingredient_id = s.ingredient_id;
+	`
+	_, err := tx.Exec(`UPDATE orders SET reserved_until = CURRENT_TIMESTAMP + make_interval(secs => $2::FLOAT8) WHERE order_id = $1`, orderID, hold.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(stmt, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	updates := []models.InventoryUpdate{}
+	for rows.Next() {
+		var update models.InventoryUpdate
+		err := rows.Scan(&update.IngredientId, &update.Name, &update.QuantityUsed, &update.Remaining)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, update)
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("No inventory updates")
+	}
+	return updates, nil
+}
+
 func (r *searchFilterRepo) deductInventory(tx *sql.Tx, orderID int) ([]models.InventoryUpdate, error) {
 	stmt := `
 		UPDATE inventory
 		SET quantity = quantity - ri.required_quantity
 		FROM (
-			SELECT mi.ingredient_id, SUM(mi.quantity * oi.quantity) AS required_quantity
-			FROM menu_item_ingredients mi
-			JOIN order_items oi ON mi.product_id = oi.product_id
-			WHERE oi.order_id = $1
-			GROUP BY mi.ingredient_id
+			SELECT ingredient_id, SUM(quantity) AS required_quantity
+			FROM order_line_ingredients
+			WHERE order_id = $1
+			GROUP BY ingredient_id
+			HAVING SUM(quantity) > 0
 		) AS ri
 		WHERE inventory.ingredient_id = ri.ingredient_id
 		RETURNING inventory.ingredient_id, inventory.name, ri.required_quantity, inventory.quantity;
 	`
 
+	_, err := tx.Exec(`SELECT set_inventory_movement($1, $2)`, "batch order", orderID)
+	if err != nil {
+		return nil, err
+	}
 	rows, err := tx.Query(stmt, orderID)
 	if err != nil {
 		return nil, err
@@ -259,11 +315,40 @@ func (r *searchFilterRepo) insertOrder(tx *sql.Tx, body models.Order) (int, erro
 
 func (r *searchFilterRepo) insertOrderItems(tx *sql.Tx, body models.Order) error {
 	stmt := `
-		INSERT INTO order_items (order_id, product_id, quantity)
-		VALUES ($1, $2, $3);
+		INSERT INTO order_items (order_id, product_id, variant_id, quantity)
+		VALUES ($1, $2, $3, $4)
+		RETURNING order_item_id;
+	`
+	modifierStmt := `
+		INSERT INTO order_item_modifiers (order_item_id, option_id)
+		VALUES ($1, $2);
+	`
+	componentStmt := `
+		INSERT INTO order_item_components (order_item_id, slot_id, product_id, quantity)
+		SELECT $1, s.slot_id, COALESCE(c.product_id, (SELECT MIN(o.product_id) FROM bundle_slot_options o WHERE o.slot_id = s.slot_id)), s.quantity
+		FROM bundle_slots s
+		LEFT JOIN UNNEST($3::INT[], $4::INT[]) AS c(slot_id, product_id) ON s.slot_id = c.slot_id
+		WHERE s.bundle_id = $2;
 	`
 	for _, item := range body.Items {
-		_, err := tx.Exec(stmt, body.ID, item.ProductID, item.Quantity)
+		var orderItemID int
+		err := tx.QueryRow(stmt, body.ID, item.ProductID, item.VariantID, item.Quantity).Scan(&orderItemID)
+		if err != nil {
+			return err
+		}
+		for _, optionID := range item.Modifiers {
+			_, err = tx.Exec(modifierStmt, orderItemID, optionID)
+			if err != nil {
+				return err
+			}
+		}
+		slotIds := []int{}
+		productIds := []int{}
+		for _, component := range item.Components {
+			slotIds = append(slotIds, component.SlotID)
+			productIds = append(productIds, component.ProductID)
+		}
+		_, err = tx.Exec(componentStmt, orderItemID, item.ProductID, pq.Array(slotIds), pq.Array(productIds))
 		if err != nil {
 			return err
 		}
@@ -274,10 +359,9 @@ func (r *searchFilterRepo) insertOrderItems(tx *sql.Tx, body models.Order) error
 
 func (r *searchFilterRepo) calculateOrderTotal(tx *sql.Tx, orderID int) (float64, error) {
 	stmt := `
-		SELECT SUM(mi.price * oi.quantity)
-		FROM menu_items mi
-		JOIN order_items oi ON mi.product_id = oi.product_id
-		WHERE oi.order_id = $1;
+		SELECT SUM(line_total)
+		FROM order_line_totals
+		WHERE order_id = $1;
 	`
 	var total float64
 	row := tx.QueryRow(stmt, orderID)
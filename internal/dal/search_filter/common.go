@@ -8,12 +8,12 @@ import (
 )
 
 type SearchFilterRepo interface {
-	GetLeftOvers(sortby string, page, pageSize int) (models.LeftOvers, error)
+	GetLeftOvers(sortby string, page, pageSize int, belowThreshold bool) (models.LeftOvers, error)
 	GetOrderedItems(startDate, endDate time.Time) (map[string]int, error)
 	TextSearch(search string, minPrice, maxPrice *float64, filter []string) (models.SearchReports, error)
 	OrderedItemsByPeriodDay(month string) (models.PeriodResult, error)
 	OrderedItemsByPeriodMonth(year string) (models.PeriodResult, error)
-	WriteDBNewOrders(body []models.Order) (*models.Common, error)
+	WriteDBNewOrders(body []models.Order, hold time.Duration) (*models.Common, error)
 }
 
 type searchFilterRepo struct {
@@ -6,14 +6,21 @@ import (
 	"frapuccino/models"
 )
 
-func (d searchFilterRepo) GetLeftOvers(sortby string, page, pageSize int) (models.LeftOvers, error) {
+// Pages through the active ingredients; belowThreshold keeps only those at or below their reorder point
+func (d searchFilterRepo) GetLeftOvers(sortby string, page, pageSize int, belowThreshold bool) (models.LeftOvers, error) {
 	var res models.LeftOvers
 	offset := (page - 1) * pageSize
 	var total int
-	err := d.Db.Db.QueryRow("SELECT COUNT(*) AS total FROM inventory").Scan(&total)
+	err := d.Db.Db.QueryRow(`
+	SELECT COUNT(*) AS total
+	FROM inventory
+	WHERE archived_at IS NULL AND (NOT $1 OR quantity <= reorder_point)`, belowThreshold).Scan(&total)
 	if err != nil {
 		return models.LeftOvers{}, err
 	}
+	if total == 0 && belowThreshold {
+		return models.LeftOvers{CurrentPage: page, Data: []models.Data{}}, nil
+	}
 
 	totalPage := (total + pageSize - 1) / pageSize
 	if totalPage < page {
@@ -29,6 +36,9 @@ func (d searchFilterRepo) GetLeftOvers(sortby string, page, pageSize int) (model
     COALESCE(i.price, 0) AS product_price
 FROM 
     inventory i
+WHERE 
+    i.archived_at IS NULL
+    AND (NOT $4 OR i.quantity <= i.reorder_point)
 ORDER BY 
     CASE WHEN $1 = 'quantity' THEN i.quantity END DESC,
     CASE WHEN $1 = 'price' THEN i.price END DESC
@@ -36,7 +46,7 @@ LIMIT $2 OFFSET $3;
 
 	`
 	var items []models.Data
-	rows, err := d.Db.Db.Query(query, sortby, pageSize, offset)
+	rows, err := d.Db.Db.Query(query, sortby, pageSize, offset, belowThreshold)
 	if err != nil {
 		return res, err
 	}
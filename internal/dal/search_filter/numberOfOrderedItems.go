@@ -8,21 +8,19 @@ import (
 func (d searchFilterRepo) GetOrderedItems(startDate, endDate time.Time) (map[string]int, error) {
 	query := `
 	SELECT 
-            m.name AS product_name,
-            SUM(oi.quantity) AS total_quantity
+            t.item_name AS product_name,
+            SUM(t.quantity) AS total_quantity
         FROM 
-            order_items oi
+            order_line_totals t
         JOIN 
-            menu_items m ON oi.product_id = m.product_id
-        JOIN 
-            orders o ON oi.order_id = o.order_id
+            orders o ON t.order_id = o.order_id
         WHERE 
             o.status = 'close'
             AND ($1::timestamp IS NULL OR o.created_at >= $1::timestamp)
 			AND ($2::timestamp IS NULL OR o.created_at <= $2::timestamp)
 
         GROUP BY 
-            m.name
+            t.item_name
         ORDER BY 
             total_quantity DESC;
 	`
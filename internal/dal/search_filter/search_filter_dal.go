@@ -19,19 +19,17 @@ func (d searchFilterRepo) TextSearch(search string, minPrice, maxPrice *float64,
 		SELECT 
     o.order_id AS id,
     o.customer_name,
-    array_agg(mi.name) AS items,
-    SUM(mi.price * oi.quantity) AS total,
-    ts_rank(to_tsvector(o.customer_name || ' ' || string_agg(mi.name, ' ')), to_tsquery($1)) AS relevance
+    array_agg(t.item_name) AS items,
+    SUM(t.line_total) AS total,
+    ts_rank(to_tsvector(o.customer_name || ' ' || string_agg(t.item_name, ' ')), to_tsquery($1)) AS relevance
 FROM 
     orders o
 JOIN 
-    order_items oi ON o.order_id = oi.order_id
-JOIN 
-    menu_items mi ON oi.product_id = mi.product_id
+    order_line_totals t ON o.order_id = t.order_id
 GROUP BY 
     o.order_id, o.customer_name
 HAVING 
-    to_tsvector(o.customer_name || ' ' || string_agg(mi.name, ' ')) @@ to_tsquery($1)
+    to_tsvector(o.customer_name || ' ' || string_agg(t.item_name, ' ')) @@ to_tsquery($1)
 ORDER BY 
     relevance DESC;
 
@@ -0,0 +1,354 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// Returned when counting, finalizing or cancelling a stocktake that is already finalized
+var ErrStocktakeNotOpen = errors.New("stocktake is already finalized")
+
+// Returned when a stocktake is started while another one is open
+var ErrStocktakeInProgress = errors.New("another stocktake is still open, finalize or cancel it first")
+
+// StocktakeRepository defines the methods for stocktake sessions and their counts
+type StocktakeRepository interface {
+	PostStocktake(note string) (int, error)
+	GetStocktakes() ([]models.Stocktake, error)
+	GetStocktakeID(id int) (models.Stocktake, error)
+	SubmitCounts(id int, submission models.StocktakeSubmission) error
+	GetVarianceLines(id int) ([]models.StocktakeVarianceLine, error)
+	FinalizeStocktake(id int) error
+	DeleteStocktake(id int) error
+}
+
+type stocktakeRepository struct {
+	newDB *SqlDataBase.DB
+}
+
+// NewStocktakeRepository creates and returns a new instance of stocktakeRepository
+func NewStocktakeRepository(db *SqlDataBase.DB) StocktakeRepository {
+	return &stocktakeRepository{newDB: db}
+}
+
+// Opens a stocktake; only one can be open at a time
+func (r *stocktakeRepository) PostStocktake(note string) (int, error) {
+	var id int
+	err := r.newDB.Db.QueryRow(`INSERT INTO stocktakes (note) VALUES ($1) RETURNING stocktake_id`, note).Scan(&id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return 0, ErrStocktakeInProgress
+	}
+	return id, err
+}
+
+// Lists stocktakes, newest first, with the number of counted ingredients and, once finalized, the variance value
+func (r *stocktakeRepository) GetStocktakes() ([]models.Stocktake, error) {
+	query := `
+	SELECT
+		s.stocktake_id,
+		s.status,
+		s.note,
+		s.started_at,
+		s.finalized_at,
+		(SELECT COUNT(DISTINCT e.ingredient_id) FROM stocktake_entries e WHERE e.stocktake_id = s.stocktake_id),
+		(SELECT SUM(c.variance * c.unit_cost) FROM stock_counts c WHERE c.stocktake_id = s.stocktake_id)
+	FROM stocktakes s
+	ORDER BY s.started_at DESC, s.stocktake_id DESC;
+	`
+	rows, err := r.newDB.Db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stocktakes := []models.Stocktake{}
+	for rows.Next() {
+		stocktake, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, stocktake)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stocktakes, nil
+}
+
+// Retrieves a stocktake with every counter's counts
+func (r *stocktakeRepository) GetStocktakeID(id int) (models.Stocktake, error) {
+	query := `
+	SELECT
+		s.stocktake_id,
+		s.status,
+		s.note,
+		s.started_at,
+		s.finalized_at,
+		(SELECT COUNT(DISTINCT e.ingredient_id) FROM stocktake_entries e WHERE e.stocktake_id = s.stocktake_id),
+		(SELECT SUM(c.variance * c.unit_cost) FROM stock_counts c WHERE c.stocktake_id = s.stocktake_id)
+	FROM stocktakes s
+	WHERE s.stocktake_id = $1;
+	`
+	stocktake, err := scanStocktake(r.newDB.Db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return stocktake, fmt.Errorf("stocktake with ID %d not found", id)
+	}
+	if err != nil {
+		return stocktake, err
+	}
+	rows, err := r.newDB.Db.Query(`
+	SELECT e.ingredient_id, i.name, i.unit, e.counter, e.counted_quantity, e.counted_at
+	FROM stocktake_entries e
+	JOIN inventory i ON e.ingredient_id = i.ingredient_id
+	WHERE e.stocktake_id = $1
+	ORDER BY i.name, e.counter;
+	`, id)
+	if err != nil {
+		return stocktake, err
+	}
+	defer rows.Close()
+	stocktake.Entries = []models.StocktakeEntry{}
+	for rows.Next() {
+		var entry models.StocktakeEntry
+		err := rows.Scan(&entry.IngredientID, &entry.Name, &entry.Unit, &entry.Counter, &entry.CountedQuantity, &entry.CountedAt)
+		if err != nil {
+			return stocktake, err
+		}
+		stocktake.Entries = append(stocktake.Entries, entry)
+	}
+	return stocktake, rows.Err()
+}
+
+// Records a counter's counts in an open stocktake, replacing the counter's earlier counts of the same ingredients
+func (r *stocktakeRepository) SubmitCounts(id int, submission models.StocktakeSubmission) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = lockOpenStocktake(tx, id, "FOR SHARE")
+	if err != nil {
+		return err
+	}
+	stmt := `
+	INSERT INTO stocktake_entries (stocktake_id, ingredient_id, counter, counted_quantity)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (stocktake_id, ingredient_id, counter)
+	DO UPDATE SET counted_quantity = EXCLUDED.counted_quantity, counted_at = CURRENT_TIMESTAMP;
+	`
+	for _, count := range submission.Counts {
+		_, err = tx.Exec(stmt, id, count.IngredientID, submission.Counter, count.CountedQuantity)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			err = fmt.Errorf("inventory item with ID %d not found", count.IngredientID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lists the counted ingredients of a stocktake with their theoretical and counted quantities and unit cost. The
+// theoretical quantity is the stock the ledger shows at the ingredient's last count, so sales made since then are
+// not mistaken for shrinkage; while the stocktake is open the cost is the current price, once finalized the price at
+// the time
+func (r *stocktakeRepository) GetVarianceLines(id int) ([]models.StocktakeVarianceLine, error) {
+	query := `
+	WITH counted AS (
+		SELECT ingredient_id, SUM(counted_quantity) AS quantity, COUNT(*) AS counters, MAX(counted_at) AS counted_at
+		FROM stocktake_entries
+		WHERE stocktake_id = $1
+		GROUP BY ingredient_id
+	)
+	SELECT
+		i.ingredient_id,
+		i.name,
+		i.unit,
+		COALESCE(c.expected_quantity, inventory_level_at(i.ingredient_id, e.counted_at)),
+		COALESCE(c.counted_quantity, e.quantity),
+		COALESCE(c.unit_cost, i.price),
+		COALESCE(e.counters, 0)
+	FROM inventory i
+	LEFT JOIN counted e ON i.ingredient_id = e.ingredient_id
+	LEFT JOIN stock_counts c ON i.ingredient_id = c.ingredient_id AND c.stocktake_id = $1
+	WHERE e.ingredient_id IS NOT NULL OR c.count_id IS NOT NULL
+	ORDER BY i.name;
+	`
+	rows, err := r.newDB.Db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []models.StocktakeVarianceLine{}
+	for rows.Next() {
+		var line models.StocktakeVarianceLine
+		err := rows.Scan(
+			&line.IngredientID,
+			&line.Name,
+			&line.Unit,
+			&line.TheoreticalQuantity,
+			&line.CountedQuantity,
+			&line.UnitCost,
+			&line.Counters,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// Corrects the stock of every counted ingredient by its variance, the sum of its counts less the stock the ledger
+// shows at its last count, so movements since the count are kept. Records the counts with that expected stock,
+// writes 'stocktake' ledger entries and closes the stocktake
+func (r *stocktakeRepository) FinalizeStocktake(id int) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = lockOpenStocktake(tx, id, "FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	SELECT 1 FROM inventory
+	WHERE ingredient_id IN (SELECT ingredient_id FROM stocktake_entries WHERE stocktake_id = $1)
+	FOR UPDATE;
+	`, id)
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`
+	INSERT INTO stock_counts (stocktake_id, ingredient_id, expected_quantity, counted_quantity, unit_cost)
+	SELECT $1, i.ingredient_id, inventory_level_at(i.ingredient_id, MAX(e.counted_at)), SUM(e.counted_quantity), i.price
+	FROM stocktake_entries e
+	JOIN inventory i ON e.ingredient_id = i.ingredient_id
+	WHERE e.stocktake_id = $1
+	GROUP BY i.ingredient_id;
+	`, id)
+	if err != nil {
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		err = errors.New("stocktake has no counts to finalize")
+		return err
+	}
+	var short []int64
+	err = tx.QueryRow(`
+	SELECT COALESCE(ARRAY_AGG(c.ingredient_id ORDER BY c.ingredient_id), '{}')
+	FROM stock_counts c
+	JOIN inventory_stock s ON c.ingredient_id = s.ingredient_id
+	WHERE c.stocktake_id = $1 AND c.variance < 0 AND s.on_hand + c.variance < s.reserved;
+	`, id).Scan(pq.Array(&short))
+	if err != nil {
+		return err
+	}
+	if len(short) > 0 {
+		err = fmt.Errorf("%w: ingredients %v", ErrReservedStock, short)
+		return err
+	}
+	_, err = tx.Exec(`SELECT set_inventory_movement($1, NULL)`, "stocktake")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE inventory i
+	SET quantity = i.quantity + c.variance
+	FROM stock_counts c
+	WHERE c.stocktake_id = $1 AND c.ingredient_id = i.ingredient_id AND c.variance <> 0;
+	`, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE stocktakes SET status = 'finalized', finalized_at = CURRENT_TIMESTAMP WHERE stocktake_id = $1;
+	`, id)
+	return err
+}
+
+// Cancels an open stocktake with its counts
+func (r *stocktakeRepository) DeleteStocktake(id int) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = lockOpenStocktake(tx, id, "FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM stocktakes WHERE stocktake_id = $1`, id)
+	return err
+}
+
+// Locks a stocktake in the given mode and checks it is still open
+func lockOpenStocktake(tx *sql.Tx, id int, lock string) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM stocktakes WHERE stocktake_id = $1 `+lock, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("stocktake with ID %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	if status != "open" {
+		return ErrStocktakeNotOpen
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanStocktake(row rowScanner) (models.Stocktake, error) {
+	var stocktake models.Stocktake
+	var finalizedAt sql.NullString
+	var varianceValue sql.NullFloat64
+	err := row.Scan(
+		&stocktake.StocktakeID,
+		&stocktake.Status,
+		&stocktake.Note,
+		&stocktake.StartedAt,
+		&finalizedAt,
+		&stocktake.CountedItems,
+		&varianceValue,
+	)
+	stocktake.FinalizedAt = nullableString(finalizedAt)
+	stocktake.VarianceValue = nullableFloat(varianceValue)
+	return stocktake, err
+}
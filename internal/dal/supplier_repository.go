@@ -0,0 +1,216 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+
+	"github.com/lib/pq"
+)
+
+// SupplierRepository defines the methods for storing suppliers and the ingredients they deliver
+type SupplierRepository interface {
+	PostSupplier(supplier models.Supplier) (int, error)
+	GetSuppliers() ([]models.Supplier, error)
+	GetSupplierID(id int) (models.Supplier, error)
+	UpdateSupplier(id int, supplier models.Supplier) error
+	DeleteSupplier(id int) error
+}
+
+type supplierRepository struct {
+	newDB *SqlDataBase.DB
+}
+
+// NewSupplierRepository creates and returns a new instance of supplierRepository
+func NewSupplierRepository(db *SqlDataBase.DB) SupplierRepository {
+	return &supplierRepository{newDB: db}
+}
+
+func (r *supplierRepository) PostSupplier(supplier models.Supplier) (int, error) {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	stmt := `
+	INSERT INTO suppliers (name, contact_name, email, phone, lead_time_days)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING supplier_id;
+	`
+	var id int
+	err = tx.QueryRow(stmt, supplier.Name, supplier.ContactName, supplier.Email, supplier.Phone, supplier.LeadTimeDays).Scan(&id)
+	if err != nil {
+		err = supplierError(err)
+		return 0, err
+	}
+	err = saveSupplierIngredients(tx, id, supplier.Ingredients)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *supplierRepository) GetSuppliers() ([]models.Supplier, error) {
+	query := `
+	SELECT supplier_id, name, contact_name, email, phone, lead_time_days
+	FROM suppliers
+	ORDER BY name;
+	`
+	return r.readSuppliers(query)
+}
+
+func (r *supplierRepository) GetSupplierID(id int) (models.Supplier, error) {
+	query := `
+	SELECT supplier_id, name, contact_name, email, phone, lead_time_days
+	FROM suppliers
+	WHERE supplier_id = $1;
+	`
+	suppliers, err := r.readSuppliers(query, id)
+	if err != nil {
+		return models.Supplier{}, err
+	}
+	if len(suppliers) == 0 {
+		return models.Supplier{}, fmt.Errorf("supplier with ID %d not found", id)
+	}
+	return suppliers[0], nil
+}
+
+// Updates a supplier and replaces the ingredients it delivers
+func (r *supplierRepository) UpdateSupplier(id int, supplier models.Supplier) error {
+	tx, err := r.newDB.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	stmt := `
+	UPDATE suppliers
+	SET name = $1, contact_name = $2, email = $3, phone = $4, lead_time_days = $5
+	WHERE supplier_id = $6;
+	`
+	res, err := tx.Exec(stmt, supplier.Name, supplier.ContactName, supplier.Email, supplier.Phone, supplier.LeadTimeDays, id)
+	if err != nil {
+		err = supplierError(err)
+		return err
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		err = fmt.Errorf("supplier with ID %d not found", id)
+		return err
+	}
+	return saveSupplierIngredients(tx, id, supplier.Ingredients)
+}
+
+// Deletes a supplier no purchase order refers to
+func (r *supplierRepository) DeleteSupplier(id int) error {
+	res, err := r.newDB.Db.Exec(`DELETE FROM suppliers WHERE supplier_id = $1`, id)
+	if err != nil {
+		return supplierError(err)
+	}
+	rowsAff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAff == 0 {
+		return fmt.Errorf("supplier with ID %d not found", id)
+	}
+	return nil
+}
+
+func saveSupplierIngredients(tx *sql.Tx, supplierId int, ingredients []models.SupplierIngredient) error {
+	_, err := tx.Exec(`DELETE FROM supplier_ingredients WHERE supplier_id = $1`, supplierId)
+	if err != nil {
+		return err
+	}
+	stmt := `
+	INSERT INTO supplier_ingredients (supplier_id, ingredient_id, unit_cost)
+	VALUES ($1, $2, $3);
+	`
+	for _, ingredient := range ingredients {
+		_, err = tx.Exec(stmt, supplierId, ingredient.IngredientID, ingredient.UnitCost)
+		if err != nil {
+			return supplierError(err)
+		}
+	}
+	return nil
+}
+
+func (r *supplierRepository) readSuppliers(query string, args ...any) ([]models.Supplier, error) {
+	rows, err := r.newDB.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	suppliers := []models.Supplier{}
+	index := make(map[int]int)
+	ids := []int64{}
+	for rows.Next() {
+		supplier := models.Supplier{Ingredients: []models.SupplierIngredient{}}
+		err := rows.Scan(&supplier.SupplierID, &supplier.Name, &supplier.ContactName, &supplier.Email, &supplier.Phone, &supplier.LeadTimeDays)
+		if err != nil {
+			return nil, err
+		}
+		index[supplier.SupplierID] = len(suppliers)
+		ids = append(ids, int64(supplier.SupplierID))
+		suppliers = append(suppliers, supplier)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	ingredientQuery := `
+	SELECT si.supplier_id, si.ingredient_id, i.name, i.unit, si.unit_cost
+	FROM supplier_ingredients si
+	JOIN inventory i ON si.ingredient_id = i.ingredient_id
+	WHERE si.supplier_id = ANY($1)
+	ORDER BY i.name;
+	`
+	ingredientRows, err := r.newDB.Db.Query(ingredientQuery, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer ingredientRows.Close()
+	for ingredientRows.Next() {
+		var supplierId int
+		var ingredient models.SupplierIngredient
+		err := ingredientRows.Scan(&supplierId, &ingredient.IngredientID, &ingredient.Name, &ingredient.Unit, &ingredient.UnitCost)
+		if err != nil {
+			return nil, err
+		}
+		i := index[supplierId]
+		suppliers[i].Ingredients = append(suppliers[i].Ingredients, ingredient)
+	}
+	if err := ingredientRows.Err(); err != nil {
+		return nil, err
+	}
+	return suppliers, nil
+}
+
+func supplierError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return errors.New("supplier with this name already exists, or an ingredient is listed twice")
+		case "23503":
+			return errors.New("supplier is referenced by purchase orders, or an ingredient does not exist")
+		}
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package dal
+
+import (
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/models"
+)
+
+// UnitRepository defines the methods for reading the units registry and the stock units of ingredients
+type UnitRepository interface {
+	GetUnits() ([]models.Unit, error)
+	GetStockUnits() (map[int]string, error)
+}
+
+type unitRepository struct {
+	newDB *SqlDataBase.DB
+}
+
+// NewUnitRepository creates and returns a new instance of unitRepository
+func NewUnitRepository(db *SqlDataBase.DB) UnitRepository {
+	return &unitRepository{newDB: db}
+}
+
+// Lists the registered units grouped by dimension, smallest first
+func (r *unitRepository) GetUnits() ([]models.Unit, error) {
+	rows, err := r.newDB.Db.Query(`SELECT unit, dimension, factor FROM units ORDER BY dimension, factor, unit`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	units := []models.Unit{}
+	for rows.Next() {
+		var unit models.Unit
+		if err := rows.Scan(&unit.Unit, &unit.Dimension, &unit.Factor); err != nil {
+			return nil, err
+		}
+		units = append(units, unit)
+	}
+	return units, rows.Err()
+}
+
+// Maps every ingredient ID to the unit it is stocked in
+func (r *unitRepository) GetStockUnits() (map[int]string, error) {
+	rows, err := r.newDB.Db.Query(`SELECT ingredient_id, unit FROM inventory`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	units := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var unit string
+		if err := rows.Scan(&id, &unit); err != nil {
+			return nil, err
+		}
+		units[id] = unit
+	}
+	return units, rows.Err()
+}
@@ -11,6 +11,13 @@ import (
 type AggregationsHandler interface {
 	PopularItems(w http.ResponseWriter, r *http.Request)
 	TotalSales(w http.ResponseWriter, r *http.Request)
+	PrepTime(w http.ResponseWriter, r *http.Request)
+	MenuMargins(w http.ResponseWriter, r *http.Request)
+	MarginAlerts(w http.ResponseWriter, r *http.Request)
+	StockAlerts(w http.ResponseWriter, r *http.Request)
+	Shrinkage(w http.ResponseWriter, r *http.Request)
+	Waste(w http.ResponseWriter, r *http.Request)
+	InventoryValuation(w http.ResponseWriter, r *http.Request)
 }
 
 type aggregationsHandler struct {
@@ -41,11 +48,28 @@ func (h *aggregationsHandler) TotalSales(w http.ResponseWriter, r *http.Request)
 
 // Handles the HTTP request to retrieve and return popular menu items as JSON
 func (h *aggregationsHandler) PopularItems(w http.ResponseWriter, r *http.Request) {
-	err, res := h.aggregationsService.ServicePopularItems()
+	err, res := h.aggregationsService.ServicePopularItems(r.URL.Query().Get("attribution"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(res)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to compare estimated and actual prep time of closed orders
+func (h *aggregationsHandler) PrepTime(w http.ResponseWriter, r *http.Request) {
+	res, err := h.aggregationsService.ServicePrepTime()
 	if err != nil {
 		SendError(w, http.StatusInternalServerError, err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
 	err = encoder.Encode(res)
@@ -54,3 +78,87 @@ func (h *aggregationsHandler) PopularItems(w http.ResponseWriter, r *http.Reques
 		return
 	}
 }
+
+// Handles the HTTP request to report cost and gross margin across the menu
+func (h *aggregationsHandler) MenuMargins(w http.ResponseWriter, r *http.Request) {
+	res, err := h.aggregationsService.ServiceMenuMargins(r.URL.Query().Get("threshold"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(res)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to retrieve low margin alerts raised by price changes
+func (h *aggregationsHandler) MarginAlerts(w http.ResponseWriter, r *http.Request) {
+	res, err := h.aggregationsService.ServiceMarginAlerts()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(res)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to retrieve low stock alerts raised when stock reached an ingredient's reorder point
+func (h *aggregationsHandler) StockAlerts(w http.ResponseWriter, r *http.Request) {
+	res, err := h.aggregationsService.ServiceStockAlerts()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(res)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to report the variance found by finalized stocktakes over time
+func (h *aggregationsHandler) Shrinkage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	res, err := h.aggregationsService.ServiceShrinkage(query.Get("from"), query.Get("to"), query.Get("ingredientId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, res)
+}
+
+// Handles the HTTP request to report the cost of waste by reason and by period
+func (h *aggregationsHandler) Waste(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	res, err := h.aggregationsService.ServiceWaste(query.Get("from"), query.Get("to"), query.Get("period"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, res)
+}
+
+// Handles the HTTP request to report the value of the stock on hand per ingredient and in total
+func (h *aggregationsHandler) InventoryValuation(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	res, err := h.aggregationsService.ServiceInventoryValuation(query.Get("asOf"), query.Get("method"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, res)
+}
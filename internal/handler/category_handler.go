@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"frapuccino/internal/service"
+	"frapuccino/models"
+)
+
+type CategoryHandler interface {
+	PostCategory(w http.ResponseWriter, r *http.Request)
+	GetCategories(w http.ResponseWriter, r *http.Request)
+	GetCategoryID(w http.ResponseWriter, r *http.Request)
+	PutCategoryID(w http.ResponseWriter, r *http.Request)
+	DeleteCategoryID(w http.ResponseWriter, r *http.Request)
+}
+
+type categoryHandler struct {
+	categoryService service.CategoryService
+}
+
+// Initializes and returns a new instance of categoryHandler with the provided service
+func NewCategoryHandler(categoryService service.CategoryService) CategoryHandler {
+	return &categoryHandler{categoryService: categoryService}
+}
+
+// Handles the HTTP request to add a new category
+func (h *categoryHandler) PostCategory(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	category := models.Category{}
+	err := json.NewDecoder(r.Body).Decode(&category)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.categoryService.ServicePostCategory(category)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusCreated, "Category added")
+}
+
+// Handles the HTTP request to retrieve all categories and returns them as JSON
+func (h *categoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categoryService.ServiceGetCategories()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(categories)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to retrieve a specific category by ID and returns it as JSON
+func (h *categoryHandler) GetCategoryID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	category, err := h.categoryService.ServiceGetCategoryID(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(category)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to update a specific category by ID
+func (h *categoryHandler) PutCategoryID(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	category := models.Category{}
+	err = json.NewDecoder(r.Body).Decode(&category)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.categoryService.ServicePutCategoryID(id, category)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Category updated")
+}
+
+// Handles the HTTP request to delete a specific category by ID
+func (h *categoryHandler) DeleteCategoryID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.categoryService.ServiceDeleteCategory(id)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusNoContent, "Category deleted")
+}
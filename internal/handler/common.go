@@ -48,3 +48,13 @@ func SendSucces(w http.ResponseWriter, status int, message string) {
 		slog.Error("Failed to send response", slog.String("ERROR", err.Error()))
 	}
 }
+
+// Sends a JSON-encoded response with the specified status code
+func sendJSON(w http.ResponseWriter, status int, content any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(content)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -16,4 +16,11 @@ func AggregationHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
 	aggregationsHandler := handler.NewAggregationsHandler(aggregationsService)
 	mux.HandleFunc("GET /reports/total-sales", aggregationsHandler.TotalSales)
 	mux.HandleFunc("GET /reports/popular-items", aggregationsHandler.PopularItems)
+	mux.HandleFunc("GET /reports/prep-time", aggregationsHandler.PrepTime)
+	mux.HandleFunc("GET /reports/menu-margins", aggregationsHandler.MenuMargins)
+	mux.HandleFunc("GET /reports/margin-alerts", aggregationsHandler.MarginAlerts)
+	mux.HandleFunc("GET /reports/stock-alerts", aggregationsHandler.StockAlerts)
+	mux.HandleFunc("GET /reports/shrinkage", aggregationsHandler.Shrinkage)
+	mux.HandleFunc("GET /reports/waste", aggregationsHandler.Waste)
+	mux.HandleFunc("GET /reports/inventory-valuation", aggregationsHandler.InventoryValuation)
 }
@@ -0,0 +1,22 @@
+package handlefunc
+
+import (
+	"net/http"
+
+	"frapuccino/internal/dal"
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/internal/handler"
+	"frapuccino/internal/service"
+)
+
+func CategoryHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
+	// Set up Categories: repository, service, and handler
+	categoryRepo := dal.NewCategoryRepository(&newDb)
+	categoryService := service.NewCategoryService(categoryRepo)
+	categoryHandler := handler.NewCategoryHandler(categoryService)
+	mux.HandleFunc("POST /categories", categoryHandler.PostCategory)
+	mux.HandleFunc("GET /categories", categoryHandler.GetCategories)
+	mux.HandleFunc("GET /categories/{id}", categoryHandler.GetCategoryID)
+	mux.HandleFunc("PUT /categories/{id}", categoryHandler.PutCategoryID)
+	mux.HandleFunc("DELETE /categories/{id}", categoryHandler.DeleteCategoryID)
+}
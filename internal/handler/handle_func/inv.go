@@ -2,6 +2,7 @@ package handlefunc
 
 import (
 	"net/http"
+	"time"
 
 	"frapuccino/internal/dal"
 	"frapuccino/internal/dal/SqlDataBase"
@@ -9,14 +10,34 @@ import (
 	"frapuccino/internal/service"
 )
 
+// How often expired lots are written off
+const lotExpiryInterval = time.Hour
+
 func InvHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
 	// Set up Inventory: repository, service, and handler
 	invRepo := dal.NewJSONInvRepository(&newDb)
-	invService := service.NewInvService(invRepo)
+	unitRepo := dal.NewUnitRepository(&newDb)
+	invService := service.NewInvService(invRepo, unitRepo)
 	invHandler := handler.NewInvHandler(invService)
 	mux.HandleFunc("POST /inventory", invHandler.PostInv)
 	mux.HandleFunc("GET /inventory", invHandler.GetInv)
+	mux.HandleFunc("GET /inventory/alerts", invHandler.GetInvAlerts)
+	mux.HandleFunc("GET /inventory/expiring", invHandler.GetExpiringLots)
+	mux.HandleFunc("POST /inventory/waste", invHandler.PostWaste)
+	mux.HandleFunc("GET /inventory/waste", invHandler.GetWaste)
 	mux.HandleFunc("GET /inventory/{id}", invHandler.GetInvID)
 	mux.HandleFunc("PUT /inventory/{id}", invHandler.PutInvID)
 	mux.HandleFunc("DELETE /inventory/{id}", invHandler.DeleteInvID)
+	mux.HandleFunc("POST /inventory/{id}/restore", invHandler.RestoreInvID)
+	mux.HandleFunc("GET /inventory/{id}/usages", invHandler.GetInvUsages)
+	mux.HandleFunc("POST /inventory/{id}/replace", invHandler.ReplaceInvID)
+	mux.HandleFunc("GET /inventory/{id}/transactions", invHandler.GetInvTransactions)
+	mux.HandleFunc("GET /inventory/{id}/level", invHandler.GetInvLevel)
+	mux.HandleFunc("POST /inventory/{id}/adjustments", invHandler.AdjustInvID)
+	mux.HandleFunc("POST /inventory/{id}/stocktake", invHandler.CountInvID)
+	mux.HandleFunc("GET /inventory/{id}/lots", invHandler.GetInvLots)
+	mux.HandleFunc("GET /units", invHandler.GetUnits)
+
+	// Write off expired lots in the background
+	go invService.RunExpiryScheduler(lotExpiryInterval)
 }
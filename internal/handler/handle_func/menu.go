@@ -2,6 +2,7 @@ package handlefunc
 
 import (
 	"net/http"
+	"time"
 
 	"frapuccino/internal/dal"
 	"frapuccino/internal/dal/SqlDataBase"
@@ -9,14 +10,45 @@ import (
 	"frapuccino/internal/service"
 )
 
+// How often due scheduled price changes are applied and scheduled menu versions published
+const (
+	priceSchedulerInterval   = time.Minute
+	versionSchedulerInterval = time.Minute
+)
+
 func MenuHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
 	// Set up Menu: repository, service, and handler
 	menuRepo := dal.NewJSONMenuRepository(&newDb)
-	menuService := service.NewMenuService(menuRepo)
+	categoryRepo := dal.NewCategoryRepository(&newDb)
+	versionRepo := dal.NewMenuVersionRepository(&newDb)
+	unitRepo := dal.NewUnitRepository(&newDb)
+	menuService := service.NewMenuService(menuRepo, categoryRepo, versionRepo, unitRepo)
 	menuHandler := handler.NewMenuHandler(menuService)
 	mux.HandleFunc("POST /menu", menuHandler.PostMenu)
 	mux.HandleFunc("GET /menu", menuHandler.GetMenu)
+	mux.HandleFunc("GET /menu/available", menuHandler.GetMenuAvailable)
+	mux.HandleFunc("GET /menu/export", menuHandler.ExportMenu)
+	mux.HandleFunc("POST /menu/import", menuHandler.ImportMenu)
 	mux.HandleFunc("GET /menu/{id}", menuHandler.GetMenuID)
 	mux.HandleFunc("PUT /menu/{id}", menuHandler.PutMenuID)
 	mux.HandleFunc("DELETE /menu/{id}", menuHandler.DeleteMenuID)
+	mux.HandleFunc("POST /menu/{id}/restore", menuHandler.RestoreMenuID)
+	mux.HandleFunc("GET /menu/{id}/price-history", menuHandler.GetPriceHistory)
+	mux.HandleFunc("POST /menu/{id}/scheduled-prices", menuHandler.PostScheduledPrice)
+	mux.HandleFunc("GET /menu/{id}/scheduled-prices", menuHandler.GetScheduledPrices)
+	mux.HandleFunc("DELETE /menu/{id}/scheduled-prices/{scheduleId}", menuHandler.DeleteScheduledPrice)
+	mux.HandleFunc("GET /menu-versions", menuHandler.GetVersions)
+	mux.HandleFunc("POST /menu-versions", menuHandler.PostVersion)
+	mux.HandleFunc("POST /menu-versions/rollback", menuHandler.RollbackVersion)
+	mux.HandleFunc("GET /menu-versions/{versionId}", menuHandler.GetVersion)
+	mux.HandleFunc("DELETE /menu-versions/{versionId}", menuHandler.DeleteVersion)
+	mux.HandleFunc("GET /menu-versions/{versionId}/preview", menuHandler.PreviewVersion)
+	mux.HandleFunc("POST /menu-versions/{versionId}/publish", menuHandler.PublishVersion)
+	mux.HandleFunc("POST /menu-versions/{versionId}/items", menuHandler.PostVersionItem)
+	mux.HandleFunc("PUT /menu-versions/{versionId}/items/{id}", menuHandler.PutVersionItem)
+	mux.HandleFunc("DELETE /menu-versions/{versionId}/items/{id}", menuHandler.DeleteVersionItem)
+
+	// Apply scheduled price changes and publish scheduled menu versions in the background
+	go menuService.RunPriceScheduler(priceSchedulerInterval)
+	go menuService.RunVersionScheduler(versionSchedulerInterval)
 }
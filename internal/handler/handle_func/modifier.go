@@ -0,0 +1,23 @@
+package handlefunc
+
+import (
+	"net/http"
+
+	"frapuccino/internal/dal"
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/internal/handler"
+	"frapuccino/internal/service"
+)
+
+func ModifierHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
+	// Set up Modifier groups: repository, service, and handler
+	modifierRepo := dal.NewModifierRepository(&newDb)
+	unitRepo := dal.NewUnitRepository(&newDb)
+	modifierService := service.NewModifierService(modifierRepo, unitRepo)
+	modifierHandler := handler.NewModifierHandler(modifierService)
+	mux.HandleFunc("POST /modifier-groups", modifierHandler.PostModifierGroup)
+	mux.HandleFunc("GET /modifier-groups", modifierHandler.GetModifierGroups)
+	mux.HandleFunc("GET /modifier-groups/{id}", modifierHandler.GetModifierGroupID)
+	mux.HandleFunc("PUT /modifier-groups/{id}", modifierHandler.PutModifierGroupID)
+	mux.HandleFunc("DELETE /modifier-groups/{id}", modifierHandler.DeleteModifierGroupID)
+}
@@ -2,6 +2,7 @@ package handlefunc
 
 import (
 	"net/http"
+	"time"
 
 	"frapuccino/internal/dal/SqlDataBase"
 	"frapuccino/internal/dal/orderRepo"
@@ -9,6 +10,9 @@ import (
 	"frapuccino/internal/service"
 )
 
+// How often open orders are checked for reservations that have run out
+const reservationExpiryInterval = time.Minute
+
 func OrderHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
 	// Set up Orders: repository, service, and handler
 
@@ -21,4 +25,9 @@ func OrderHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
 	mux.HandleFunc("PUT /orders/{id}", orderHandler.PutOrdersID)
 	mux.HandleFunc("DELETE /orders/{id}", orderHandler.DeleteOrdersID)
 	mux.HandleFunc("POST /orders/{id}/close", orderHandler.PostOrdersIDClose)
+	mux.HandleFunc("GET /orders/{id}/eta", orderHandler.GetOrdersIDETA)
+	mux.HandleFunc("GET /stations", orderHandler.GetStations)
+	mux.HandleFunc("PUT /stations/{station}", orderHandler.PutStation)
+
+	go orderService.RunReservationScheduler(reservationExpiryInterval)
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/internal/dal/orderRepo"
 	database "frapuccino/internal/dal/search_filter"
 	"frapuccino/internal/handler"
 	"frapuccino/internal/service"
@@ -11,7 +12,8 @@ import (
 
 func FrappuccinoNewHandler(mux *http.ServeMux, newdb SqlDataBase.DB) {
 	searchRepo := database.NewSearchFilterRepo(&newdb)
-	searchService := service.NewSearchFilterHandler(searchRepo)
+	orderRepo := orderRepo.NewJSONOrderRepository(&newdb)
+	searchService := service.NewSearchFilterHandler(searchRepo, orderRepo)
 	searchHandler := handler.NewSearchFilterHandler(searchService)
 	mux.HandleFunc("GET /orders/numberOfOrderedItems", searchHandler.NumberOfOrderedItems)
 	mux.HandleFunc("GET /reports/search", searchHandler.ReportsSearch)
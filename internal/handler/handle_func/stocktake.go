@@ -0,0 +1,24 @@
+package handlefunc
+
+import (
+	"net/http"
+
+	"frapuccino/internal/dal"
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/internal/handler"
+	"frapuccino/internal/service"
+)
+
+func StocktakeHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
+	// Set up Stocktakes: repository, service, and handler
+	stocktakeRepo := dal.NewStocktakeRepository(&newDb)
+	stocktakeService := service.NewStocktakeService(stocktakeRepo)
+	stocktakeHandler := handler.NewStocktakeHandler(stocktakeService)
+	mux.HandleFunc("POST /stocktakes", stocktakeHandler.PostStocktake)
+	mux.HandleFunc("GET /stocktakes", stocktakeHandler.GetStocktakes)
+	mux.HandleFunc("GET /stocktakes/{id}", stocktakeHandler.GetStocktakeID)
+	mux.HandleFunc("DELETE /stocktakes/{id}", stocktakeHandler.DeleteStocktakeID)
+	mux.HandleFunc("POST /stocktakes/{id}/counts", stocktakeHandler.SubmitCounts)
+	mux.HandleFunc("GET /stocktakes/{id}/variance", stocktakeHandler.GetVariance)
+	mux.HandleFunc("POST /stocktakes/{id}/finalize", stocktakeHandler.FinalizeStocktake)
+}
@@ -0,0 +1,34 @@
+package handlefunc
+
+import (
+	"net/http"
+
+	"frapuccino/internal/dal"
+	"frapuccino/internal/dal/SqlDataBase"
+	"frapuccino/internal/handler"
+	"frapuccino/internal/service"
+)
+
+func SupplierHandler(mux *http.ServeMux, newDb SqlDataBase.DB) {
+	// Set up Suppliers and purchase orders: repositories, services, and handlers
+	supplierRepo := dal.NewSupplierRepository(&newDb)
+	purchaseOrderRepo := dal.NewPurchaseOrderRepository(&newDb)
+	supplierService := service.NewSupplierService(supplierRepo)
+	purchaseOrderService := service.NewPurchaseOrderService(purchaseOrderRepo, supplierRepo)
+	supplierHandler := handler.NewSupplierHandler(supplierService)
+	purchaseOrderHandler := handler.NewPurchaseOrderHandler(purchaseOrderService)
+	mux.HandleFunc("POST /suppliers", supplierHandler.PostSupplier)
+	mux.HandleFunc("GET /suppliers", supplierHandler.GetSuppliers)
+	mux.HandleFunc("GET /suppliers/{id}", supplierHandler.GetSupplierID)
+	mux.HandleFunc("PUT /suppliers/{id}", supplierHandler.PutSupplierID)
+	mux.HandleFunc("DELETE /suppliers/{id}", supplierHandler.DeleteSupplierID)
+	mux.HandleFunc("POST /purchase-orders", purchaseOrderHandler.PostPurchaseOrder)
+	mux.HandleFunc("GET /purchase-orders", purchaseOrderHandler.GetPurchaseOrders)
+	mux.HandleFunc("GET /purchase-orders/suggestions", purchaseOrderHandler.GetReorderSuggestions)
+	mux.HandleFunc("POST /purchase-orders/suggestions", purchaseOrderHandler.PostReorderSuggestions)
+	mux.HandleFunc("GET /purchase-orders/{id}", purchaseOrderHandler.GetPurchaseOrderID)
+	mux.HandleFunc("PUT /purchase-orders/{id}", purchaseOrderHandler.PutPurchaseOrderID)
+	mux.HandleFunc("DELETE /purchase-orders/{id}", purchaseOrderHandler.DeletePurchaseOrderID)
+	mux.HandleFunc("POST /purchase-orders/{id}/send", purchaseOrderHandler.SendPurchaseOrder)
+	mux.HandleFunc("POST /purchase-orders/{id}/receive", purchaseOrderHandler.ReceivePurchaseOrder)
+}
@@ -2,21 +2,36 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
 
+	"frapuccino/internal/dal"
 	"frapuccino/internal/service"
 	"frapuccino/models"
 )
 
 // InventoryHandler interface defines HTTP handler methods for inventory operations.
 type InventoryHandler interface {
-	PostInv(w http.ResponseWriter, r *http.Request)     // Handles adding new inventory items.
-	GetInv(w http.ResponseWriter, r *http.Request)      // Retrieves all inventory items.
-	GetInvID(w http.ResponseWriter, r *http.Request)    // Retrieves a single inventory item by ID.
-	PutInvID(w http.ResponseWriter, r *http.Request)    // Updates an inventory item by ID.
-	DeleteInvID(w http.ResponseWriter, r *http.Request) // Deletes an inventory item by ID.
+	PostInv(w http.ResponseWriter, r *http.Request)            // Handles adding new inventory items.
+	GetInv(w http.ResponseWriter, r *http.Request)             // Retrieves all inventory items.
+	GetInvID(w http.ResponseWriter, r *http.Request)           // Retrieves a single inventory item by ID.
+	PutInvID(w http.ResponseWriter, r *http.Request)           // Updates an inventory item by ID.
+	DeleteInvID(w http.ResponseWriter, r *http.Request)        // Deletes an inventory item by ID.
+	RestoreInvID(w http.ResponseWriter, r *http.Request)       // Restores an archived inventory item.
+	GetInvUsages(w http.ResponseWriter, r *http.Request)       // Lists recipes that use an inventory item.
+	ReplaceInvID(w http.ResponseWriter, r *http.Request)       // Replaces an inventory item in all recipes.
+	GetInvTransactions(w http.ResponseWriter, r *http.Request) // Lists the stock movements of an inventory item.
+	GetInvLevel(w http.ResponseWriter, r *http.Request)        // Reconstructs the stock level of an inventory item.
+	AdjustInvID(w http.ResponseWriter, r *http.Request)        // Changes the stock of an inventory item by a delta.
+	CountInvID(w http.ResponseWriter, r *http.Request)         // Replaces the stock of an inventory item with a count.
+	GetUnits(w http.ResponseWriter, r *http.Request)           // Lists the registered units of measure.
+	GetInvAlerts(w http.ResponseWriter, r *http.Request)       // Lists inventory items at or below their reorder point.
+	GetInvLots(w http.ResponseWriter, r *http.Request)         // Lists the lots of an inventory item.
+	GetExpiringLots(w http.ResponseWriter, r *http.Request)    // Lists lots expiring soon.
+	PostWaste(w http.ResponseWriter, r *http.Request)          // Deducts and logs wasted stock.
+	GetWaste(w http.ResponseWriter, r *http.Request)           // Lists the waste log.
 }
 
 // InvHandler struct handles requests related to inventory operations.
@@ -50,14 +65,24 @@ func (h *InvHandler) PostInv(w http.ResponseWriter, r *http.Request) {
 	SendSucces(w, http.StatusCreated, "New inventory item added")
 }
 
-// DeleteInvID handles the deletion of an inventory item by its ID, parsed from the URL path.
+// DeleteInvID handles the deletion of an inventory item by its ID, parsed from the URL path, or its archiving with ?archive=true.
+// While recipes use the ingredient it answers 409 with the dependent usages.
 func (h *InvHandler) DeleteInvID(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		SendError(w, http.StatusInternalServerError, err)
 		return
 	}
-	err1 := h.invService.ServiceInvDelete(id)
+	var err1 error
+	if r.URL.Query().Get("archive") == "true" {
+		err1 = h.invService.ServiceInvArchive(id)
+	} else {
+		err1 = h.invService.ServiceInvDelete(id)
+	}
+	if errors.Is(err1, dal.ErrIngredientInUse) {
+		h.sendInUse(w, id, err1)
+		return
+	}
 	if err1 != nil {
 		SendError(w, http.StatusBadRequest, err1)
 		return
@@ -65,9 +90,88 @@ func (h *InvHandler) DeleteInvID(w http.ResponseWriter, r *http.Request) {
 	SendSucces(w, http.StatusNoContent, "Inventory item deleted")
 }
 
+// Answers 409 with the recipes that block removing the ingredient
+func (h *InvHandler) sendInUse(w http.ResponseWriter, id int, err error) {
+	usages, usageErr := h.invService.ServiceGetUsages(id)
+	if usageErr != nil {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(map[string]any{"error": err.Error(), "usages": usages})
+}
+
+// RestoreInvID returns an archived inventory item to the inventory.
+func (h *InvHandler) RestoreInvID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.invService.ServiceInvRestore(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Inventory item restored")
+}
+
+// GetInvUsages lists the recipes, variants and modifiers that use an inventory item.
+func (h *InvHandler) GetInvUsages(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	usages, err := h.invService.ServiceGetUsages(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(usages)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// ReplaceInvID rewrites every recipe that uses an inventory item to use a substitute and archives the item.
+func (h *InvHandler) ReplaceInvID(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	replacement := models.IngredientReplacement{}
+	err = json.NewDecoder(r.Body).Decode(&replacement)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	usages, err := h.invService.ServiceInvReplace(id, replacement)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]any{"replaced": usages})
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
 // GetInv retrieves and sends all inventory items as JSON.
 func (h *InvHandler) GetInv(w http.ResponseWriter, r *http.Request) {
-	content, err := h.invService.ServiceGetInvItem()
+	content, err := h.invService.ServiceGetInvItem(r.URL.Query().Get("includeArchived") == "true")
 	if err != nil {
 		SendError(w, http.StatusBadRequest, err)
 		return
@@ -131,3 +235,172 @@ func (h *InvHandler) PutInvID(w http.ResponseWriter, r *http.Request) {
 	}
 	SendSucces(w, http.StatusOK, "Inventory item updated")
 }
+
+// GetInvTransactions lists the stock movements of an inventory item, filtered with ?from= and ?to=.
+func (h *InvHandler) GetInvTransactions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	ledger, err := h.invService.ServiceGetTransactions(id, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, ledger)
+}
+
+// GetInvLevel reconstructs the stock level of an inventory item at ?at=, or returns the current level.
+func (h *InvHandler) GetInvLevel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	level, err := h.invService.ServiceGetLevel(id, r.URL.Query().Get("at"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, level)
+}
+
+// AdjustInvID changes the stock of an inventory item by the signed delta in the request body.
+func (h *InvHandler) AdjustInvID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	var adjustment models.InventoryAdjustment
+	err = json.NewDecoder(r.Body).Decode(&adjustment)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	level, err := h.invService.ServiceAdjustInv(id, adjustment)
+	if errors.Is(err, dal.ErrNegativeStock) || errors.Is(err, dal.ErrReservedStock) {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, level)
+}
+
+// CountInvID replaces the stock of an inventory item with the counted quantity in the request body.
+func (h *InvHandler) CountInvID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	var count models.StockCount
+	err = json.NewDecoder(r.Body).Decode(&count)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	count, err = h.invService.ServiceCountInv(id, count)
+	if errors.Is(err, dal.ErrNegativeStock) || errors.Is(err, dal.ErrReservedStock) {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, count)
+}
+
+// GetUnits lists the units of measure inventory items and recipes can use.
+func (h *InvHandler) GetUnits(w http.ResponseWriter, r *http.Request) {
+	units, err := h.invService.ServiceGetUnits()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, units)
+}
+
+// GetInvAlerts lists the inventory items at or below their reorder point.
+func (h *InvHandler) GetInvAlerts(w http.ResponseWriter, r *http.Request) {
+	items, err := h.invService.ServiceGetLowStock()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, items)
+}
+
+// GetInvLots lists the lots of an inventory item; ?includeClosed=true adds used up and expired lots.
+func (h *InvHandler) GetInvLots(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	lots, err := h.invService.ServiceGetLots(id, r.URL.Query().Get("includeClosed") == "true")
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, lots)
+}
+
+// GetExpiringLots lists the lots with stock left that expire within ?days=, three by default.
+func (h *InvHandler) GetExpiringLots(w http.ResponseWriter, r *http.Request) {
+	lots, err := h.invService.ServiceGetExpiringLots(r.URL.Query().Get("days"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, lots)
+}
+
+// PostWaste deducts wasted stock of an ingredient or the whole recipe of a menu item and returns the logged entry.
+// It answers 409 when the stock is short of what the waste deducts.
+func (h *InvHandler) PostWaste(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	var entry models.WasteEntry
+	err := json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	entry, err = h.invService.ServiceLogWaste(entry)
+	if errors.Is(err, dal.ErrNegativeStock) || errors.Is(err, dal.ErrReservedStock) {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, entry)
+}
+
+// GetWaste lists the waste log between ?from= and ?to=.
+func (h *InvHandler) GetWaste(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	entries, err := h.invService.ServiceGetWaste(query.Get("from"), query.Get("to"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, entries)
+}
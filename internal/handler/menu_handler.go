@@ -2,20 +2,40 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
+	"frapuccino/internal/dal"
 	"frapuccino/internal/service"
 	"frapuccino/models"
 )
 
 type MenuHandler interface {
 	GetMenu(w http.ResponseWriter, r *http.Request)
+	GetMenuAvailable(w http.ResponseWriter, r *http.Request)
 	PostMenu(w http.ResponseWriter, r *http.Request)
 	GetMenuID(w http.ResponseWriter, r *http.Request)
 	PutMenuID(w http.ResponseWriter, r *http.Request)
 	DeleteMenuID(w http.ResponseWriter, r *http.Request)
+	RestoreMenuID(w http.ResponseWriter, r *http.Request)
+	GetPriceHistory(w http.ResponseWriter, r *http.Request)
+	PostScheduledPrice(w http.ResponseWriter, r *http.Request)
+	GetScheduledPrices(w http.ResponseWriter, r *http.Request)
+	DeleteScheduledPrice(w http.ResponseWriter, r *http.Request)
+	ExportMenu(w http.ResponseWriter, r *http.Request)
+	ImportMenu(w http.ResponseWriter, r *http.Request)
+	GetVersions(w http.ResponseWriter, r *http.Request)
+	GetVersion(w http.ResponseWriter, r *http.Request)
+	PostVersion(w http.ResponseWriter, r *http.Request)
+	DeleteVersion(w http.ResponseWriter, r *http.Request)
+	PreviewVersion(w http.ResponseWriter, r *http.Request)
+	PublishVersion(w http.ResponseWriter, r *http.Request)
+	RollbackVersion(w http.ResponseWriter, r *http.Request)
+	PostVersionItem(w http.ResponseWriter, r *http.Request)
+	PutVersionItem(w http.ResponseWriter, r *http.Request)
+	DeleteVersionItem(w http.ResponseWriter, r *http.Request)
 }
 
 type menuHandler struct {
@@ -49,7 +69,26 @@ func (h *menuHandler) PostMenu(w http.ResponseWriter, r *http.Request) {
 
 // Handles the HTTP request to retrieve all menu items and returns them as JSON
 func (h *menuHandler) GetMenu(w http.ResponseWriter, r *http.Request) {
-	content, err := h.menuService.ServiceGetMenuItem()
+	at, err := service.ParseMenuTime(r.URL.Query().Get("at"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := models.MenuFilter{
+		HideUnavailable:  r.URL.Query().Get("hideUnavailable") == "true",
+		ExcludeAllergens: service.ParseAllergenList(r.URL.Query().Get("excludeAllergens")),
+		At:               at,
+		IncludeArchived:  r.URL.Query().Get("includeArchived") == "true",
+	}
+	var content any
+	switch r.URL.Query().Get("groupBy") {
+	case "":
+		content, err = h.menuService.ServiceGetMenuItem(filter)
+	case "category":
+		content, err = h.menuService.ServiceGetMenuGrouped(filter)
+	default:
+		err = errors.New("groupBy must be 'category'")
+	}
 	if err != nil {
 		SendError(w, http.StatusBadRequest, err)
 		return
@@ -113,10 +152,190 @@ func (h *menuHandler) DeleteMenuID(w http.ResponseWriter, r *http.Request) {
 		SendError(w, http.StatusBadRequest, err)
 		return
 	}
-	err = h.menuService.ServiceDelete(id)
+	err = h.menuService.ServiceDelete(id, r.URL.Query().Get("permanent") == "true")
+	if errors.Is(err, dal.ErrMenuItemInUse) {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
 		SendError(w, http.StatusBadRequest, err)
 		return
 	}
 	SendSucces(w, http.StatusNoContent, "Menu item deleted")
 }
+
+// Handles the HTTP request to return an archived menu item to the menu
+func (h *menuHandler) RestoreMenuID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.menuService.ServiceRestore(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Menu item restored")
+}
+
+// Handles the HTTP request to retrieve the price history of a menu item
+func (h *menuHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	history, err := h.menuService.ServiceGetPriceHistory(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(history)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to schedule a future price change of a menu item
+func (h *menuHandler) PostScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	change := models.ScheduledPrice{}
+	err = json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.menuService.ServicePostScheduledPrice(id, change)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusCreated, "Price change scheduled")
+}
+
+// Handles the HTTP request to retrieve the scheduled price changes of a menu item
+func (h *menuHandler) GetScheduledPrices(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	changes, err := h.menuService.ServiceGetScheduledPrices(id)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(changes)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to cancel a pending scheduled price change
+func (h *menuHandler) DeleteScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	scheduleId, err := strconv.Atoi(r.PathValue("scheduleId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.menuService.ServiceDeleteScheduledPrice(id, scheduleId)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	SendSucces(w, http.StatusNoContent, "Scheduled price change cancelled")
+}
+
+// Handles the HTTP request to retrieve menu items with the servings makeable from current inventory
+func (h *menuHandler) GetMenuAvailable(w http.ResponseWriter, r *http.Request) {
+	availability, err := h.menuService.ServiceGetMenuAvailability()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(availability)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to export the menu with recipes as JSON or CSV
+func (h *menuHandler) ExportMenu(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		SendError(w, http.StatusBadRequest, errors.New("format must be 'json' or 'csv'"))
+		return
+	}
+	items, err := h.menuService.ServiceExportMenu()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="menu.csv"`)
+		err = service.WriteMenuCSV(w, items)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(items)
+	}
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to import menu items with recipes from JSON or CSV, previewing the changes with ?dryRun=true
+func (h *menuHandler) ImportMenu(w http.ResponseWriter, r *http.Request) {
+	var rows []models.MenuExportItem
+	var rowNumbers []int
+	var err error
+	switch r.Header.Get("Content-Type") {
+	case "application/json":
+		err = json.NewDecoder(r.Body).Decode(&rows)
+	case "text/csv":
+		rows, rowNumbers, err = service.ParseMenuCSV(r.Body)
+	default:
+		err = errors.New("Content-Type must be 'application/json' or 'text/csv'")
+	}
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := h.menuService.ServiceImportMenu(rows, rowNumbers, r.URL.Query().Get("dryRun") == "true")
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
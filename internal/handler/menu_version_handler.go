@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"frapuccino/internal/dal"
+	"frapuccino/models"
+)
+
+// Handles the HTTP request to list menu versions
+func (h *menuHandler) GetVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.menuService.ServiceGetVersions()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, versions)
+}
+
+// Handles the HTTP request to retrieve a menu version with its items
+func (h *menuHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("versionId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	version, err := h.menuService.ServiceGetVersion(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, version)
+}
+
+// Handles the HTTP request to create a draft copied from the live menu or from another version
+func (h *menuHandler) PostVersion(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	var version models.MenuVersion
+	err := json.NewDecoder(r.Body).Decode(&version)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := h.menuService.ServicePostVersion(version)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, map[string]int{"version_id": id})
+}
+
+// Handles the HTTP request to delete a draft
+func (h *menuHandler) DeleteVersion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("versionId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.menuService.ServiceDeleteVersion(id)
+	if err != nil {
+		sendVersionError(w, err)
+		return
+	}
+	SendSucces(w, http.StatusNoContent, "Menu version deleted")
+}
+
+// Handles the HTTP request to compare a menu version with the live menu
+func (h *menuHandler) PreviewVersion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("versionId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	preview, err := h.menuService.ServicePreviewVersion(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, preview)
+}
+
+// Handles the HTTP request to publish a menu version now, or at publish_at when the body gives one
+func (h *menuHandler) PublishVersion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("versionId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	var schedule models.MenuVersion
+	if r.ContentLength != 0 {
+		if err := CheckContentType(r); err != nil {
+			SendError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = json.NewDecoder(r.Body).Decode(&schedule)
+		if err != nil {
+			SendError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	publishAt := ""
+	if schedule.PublishAt != nil {
+		publishAt = *schedule.PublishAt
+	}
+	err = h.menuService.ServicePublishVersion(id, publishAt)
+	if err != nil {
+		sendVersionError(w, err)
+		return
+	}
+	if publishAt != "" {
+		SendSucces(w, http.StatusOK, "Menu version scheduled")
+		return
+	}
+	SendSucces(w, http.StatusOK, "Menu version published")
+}
+
+// Handles the HTTP request to publish again the version that was live before the current one
+func (h *menuHandler) RollbackVersion(w http.ResponseWriter, r *http.Request) {
+	version, err := h.menuService.ServiceRollbackVersion()
+	if err != nil {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, map[string]int{"version_id": version.VersionID})
+}
+
+// Handles the HTTP request to add a new menu item to a draft
+func (h *menuHandler) PostVersionItem(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	versionId, err := strconv.Atoi(r.PathValue("versionId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	var item models.MenuItem
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := h.menuService.ServicePostVersionItem(versionId, item)
+	if err != nil {
+		sendVersionError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, map[string]int{"product_id": id})
+}
+
+// Handles the HTTP request to replace a menu item of a draft
+func (h *menuHandler) PutVersionItem(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	versionId, err := strconv.Atoi(r.PathValue("versionId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	var item models.MenuItem
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.menuService.ServicePutVersionItem(versionId, id, item)
+	if err != nil {
+		sendVersionError(w, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Menu version item updated")
+}
+
+// Handles the HTTP request to remove a menu item from a draft
+func (h *menuHandler) DeleteVersionItem(w http.ResponseWriter, r *http.Request) {
+	versionId, err := strconv.Atoi(r.PathValue("versionId"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.menuService.ServiceDeleteVersionItem(versionId, id)
+	if err != nil {
+		sendVersionError(w, err)
+		return
+	}
+	SendSucces(w, http.StatusNoContent, "Menu version item removed")
+}
+
+// Answers 409 for changes to versions that are no longer drafts and 400 for anything else
+func sendVersionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, dal.ErrVersionNotDraft) {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
+	SendError(w, http.StatusBadRequest, err)
+}
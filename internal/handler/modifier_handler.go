@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"frapuccino/internal/service"
+	"frapuccino/models"
+)
+
+type ModifierHandler interface {
+	PostModifierGroup(w http.ResponseWriter, r *http.Request)
+	GetModifierGroups(w http.ResponseWriter, r *http.Request)
+	GetModifierGroupID(w http.ResponseWriter, r *http.Request)
+	PutModifierGroupID(w http.ResponseWriter, r *http.Request)
+	DeleteModifierGroupID(w http.ResponseWriter, r *http.Request)
+}
+
+type modifierHandler struct {
+	modifierService service.ModifierService
+}
+
+// Initializes and returns a new instance of modifierHandler with the provided service
+func NewModifierHandler(modifierService service.ModifierService) ModifierHandler {
+	return &modifierHandler{modifierService: modifierService}
+}
+
+// Handles the HTTP request to add a new modifier group with its options
+func (h *modifierHandler) PostModifierGroup(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	group := models.ModifierGroup{}
+	err := json.NewDecoder(r.Body).Decode(&group)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.modifierService.ServicePostModifierGroup(group)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusCreated, "Modifier group added")
+}
+
+// Handles the HTTP request to retrieve all modifier groups and returns them as JSON
+func (h *modifierHandler) GetModifierGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.modifierService.ServiceGetModifierGroups()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(groups)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to retrieve a specific modifier group by ID and returns it as JSON
+func (h *modifierHandler) GetModifierGroupID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	group, err := h.modifierService.ServiceGetModifierGroupID(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(group)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to update a specific modifier group by ID
+func (h *modifierHandler) PutModifierGroupID(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	group := models.ModifierGroup{}
+	err = json.NewDecoder(r.Body).Decode(&group)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.modifierService.ServicePutModifierGroupID(id, group)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Modifier group updated")
+}
+
+// Handles the HTTP request to delete a specific modifier group by ID
+func (h *modifierHandler) DeleteModifierGroupID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.modifierService.ServiceDeleteModifierGroup(id)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusNoContent, "Modifier group deleted")
+}
@@ -16,6 +16,9 @@ type OrderHandler interface {
 	PutOrdersID(w http.ResponseWriter, r *http.Request)
 	DeleteOrdersID(w http.ResponseWriter, r *http.Request)
 	PostOrdersIDClose(w http.ResponseWriter, r *http.Request)
+	GetOrdersIDETA(w http.ResponseWriter, r *http.Request)
+	GetStations(w http.ResponseWriter, r *http.Request)
+	PutStation(w http.ResponseWriter, r *http.Request)
 }
 type orderHandler struct {
 	orderService service.OrderService
@@ -39,14 +42,20 @@ func (h orderHandler) PostOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.orderService.ServicePostOrders(body)
+	eta, err := h.orderService.ServicePostOrders(body)
 	if err != nil {
 
 		SendError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	SendSucces(w, http.StatusCreated, "Order opened")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(eta)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
 }
 
 // Handles the HTTP request to retrieve all orders and returns them as JSON
@@ -137,3 +146,58 @@ func (h orderHandler) PostOrdersIDClose(w http.ResponseWriter, r *http.Request)
 	}
 	SendSucces(w, http.StatusOK, "Order closed")
 }
+
+// Handles the HTTP request to retrieve the estimated ready time of an order
+func (h orderHandler) GetOrdersIDETA(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	eta, err := h.orderService.GetOrderETAService(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(eta)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to retrieve all preparation stations and their active staff
+func (h orderHandler) GetStations(w http.ResponseWriter, r *http.Request) {
+	stations, err := h.orderService.GetStationsService()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(stations)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Handles the HTTP request to change the number of active staff at a station
+func (h orderHandler) PutStation(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	body := models.Station{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.orderService.PutStationService(r.PathValue("station"), body)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Station updated")
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"frapuccino/internal/dal"
+	"frapuccino/internal/service"
+	"frapuccino/models"
+)
+
+type PurchaseOrderHandler interface {
+	PostPurchaseOrder(w http.ResponseWriter, r *http.Request)
+	GetPurchaseOrders(w http.ResponseWriter, r *http.Request)
+	GetPurchaseOrderID(w http.ResponseWriter, r *http.Request)
+	PutPurchaseOrderID(w http.ResponseWriter, r *http.Request)
+	DeletePurchaseOrderID(w http.ResponseWriter, r *http.Request)
+	SendPurchaseOrder(w http.ResponseWriter, r *http.Request)
+	ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request)
+	GetReorderSuggestions(w http.ResponseWriter, r *http.Request)
+	PostReorderSuggestions(w http.ResponseWriter, r *http.Request)
+}
+
+type purchaseOrderHandler struct {
+	purchaseOrderService service.PurchaseOrderService
+}
+
+// Initializes and returns a new instance of purchaseOrderHandler with the provided service
+func NewPurchaseOrderHandler(purchaseOrderService service.PurchaseOrderService) PurchaseOrderHandler {
+	return &purchaseOrderHandler{purchaseOrderService: purchaseOrderService}
+}
+
+// Handles the HTTP request to create a draft purchase order
+func (h *purchaseOrderHandler) PostPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	order := models.PurchaseOrder{}
+	err := json.NewDecoder(r.Body).Decode(&order)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := h.purchaseOrderService.ServicePostPurchaseOrder(order)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, map[string]int{"purchase_order_id": id})
+}
+
+// Handles the HTTP request to list purchase orders, optionally filtered with ?status=
+func (h *purchaseOrderHandler) GetPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := h.purchaseOrderService.ServiceGetPurchaseOrders(r.URL.Query().Get("status"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, orders)
+}
+
+// Handles the HTTP request to retrieve a purchase order with its lines
+func (h *purchaseOrderHandler) GetPurchaseOrderID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	order, err := h.purchaseOrderService.ServiceGetPurchaseOrderID(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, order)
+}
+
+// Handles the HTTP request to replace a draft purchase order
+func (h *purchaseOrderHandler) PutPurchaseOrderID(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	order := models.PurchaseOrder{}
+	err = json.NewDecoder(r.Body).Decode(&order)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.purchaseOrderService.ServicePutPurchaseOrderID(id, order)
+	if err != nil {
+		sendPurchaseOrderError(w, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Purchase order updated")
+}
+
+// Handles the HTTP request to delete a draft purchase order
+func (h *purchaseOrderHandler) DeletePurchaseOrderID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.purchaseOrderService.ServiceDeletePurchaseOrder(id)
+	if err != nil {
+		sendPurchaseOrderError(w, err)
+		return
+	}
+	SendSucces(w, http.StatusNoContent, "Purchase order deleted")
+}
+
+// Handles the HTTP request to send a draft purchase order to its supplier
+func (h *purchaseOrderHandler) SendPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.purchaseOrderService.ServiceSendPurchaseOrder(id)
+	if err != nil {
+		sendPurchaseOrderError(w, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Purchase order sent")
+}
+
+// Handles the HTTP request to receive goods against a sent purchase order and returns the updated order
+func (h *purchaseOrderHandler) ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	receipt := models.PurchaseOrderReceipt{}
+	err = json.NewDecoder(r.Body).Decode(&receipt)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	order, err := h.purchaseOrderService.ServiceReceivePurchaseOrder(id, receipt)
+	if err != nil {
+		sendPurchaseOrderError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, order)
+}
+
+// Handles the HTTP request to list reorder suggestions grouped by supplier
+func (h *purchaseOrderHandler) GetReorderSuggestions(w http.ResponseWriter, r *http.Request) {
+	suggestions, err := h.purchaseOrderService.ServiceGetReorderSuggestions()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, suggestions)
+}
+
+// Handles the HTTP request to turn reorder suggestions into draft purchase orders
+func (h *purchaseOrderHandler) PostReorderSuggestions(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.purchaseOrderService.ServicePostReorderSuggestions()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, map[string][]int{"purchase_order_ids": ids})
+}
+
+// Answers 409 for changes the purchase order's status does not allow and 400 for anything else
+func sendPurchaseOrderError(w http.ResponseWriter, err error) {
+	if errors.Is(err, dal.ErrPurchaseOrderStatus) {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
+	SendError(w, http.StatusBadRequest, err)
+}
@@ -89,7 +89,8 @@ func (h *searchFilterHandler) GetLeftOvers(w http.ResponseWriter, r *http.Reques
 	sortBy := r.URL.Query().Get("sortBy")
 	page := r.URL.Query().Get("page")
 	pageSize := r.URL.Query().Get("pageSize")
-	res, err := h.searchFilterService.GetLeftOversService(sortBy, page, pageSize)
+	belowThreshold := r.URL.Query().Get("belowThreshold") == "true"
+	res, err := h.searchFilterService.GetLeftOversService(sortBy, page, pageSize, belowThreshold)
 	if err != nil {
 		SendError(w, http.StatusInternalServerError, err)
 		return
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"frapuccino/internal/dal"
+	"frapuccino/internal/service"
+	"frapuccino/models"
+)
+
+type StocktakeHandler interface {
+	PostStocktake(w http.ResponseWriter, r *http.Request)
+	GetStocktakes(w http.ResponseWriter, r *http.Request)
+	GetStocktakeID(w http.ResponseWriter, r *http.Request)
+	SubmitCounts(w http.ResponseWriter, r *http.Request)
+	GetVariance(w http.ResponseWriter, r *http.Request)
+	FinalizeStocktake(w http.ResponseWriter, r *http.Request)
+	DeleteStocktakeID(w http.ResponseWriter, r *http.Request)
+}
+
+type stocktakeHandler struct {
+	stocktakeService service.StocktakeService
+}
+
+// Initializes and returns a new instance of stocktakeHandler with the provided service
+func NewStocktakeHandler(stocktakeService service.StocktakeService) StocktakeHandler {
+	return &stocktakeHandler{stocktakeService: stocktakeService}
+}
+
+// Handles the HTTP request to start a stocktake; the body with a note is optional
+func (h *stocktakeHandler) PostStocktake(w http.ResponseWriter, r *http.Request) {
+	stocktake := models.Stocktake{}
+	if r.ContentLength != 0 {
+		if err := CheckContentType(r); err != nil {
+			SendError(w, http.StatusBadRequest, err)
+			return
+		}
+		err := json.NewDecoder(r.Body).Decode(&stocktake)
+		if err != nil {
+			SendError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	id, err := h.stocktakeService.ServicePostStocktake(stocktake)
+	if err != nil {
+		sendStocktakeError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, map[string]int{"stocktake_id": id})
+}
+
+// Handles the HTTP request to list stocktakes
+func (h *stocktakeHandler) GetStocktakes(w http.ResponseWriter, r *http.Request) {
+	stocktakes, err := h.stocktakeService.ServiceGetStocktakes()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, stocktakes)
+}
+
+// Handles the HTTP request to retrieve a stocktake with its counts
+func (h *stocktakeHandler) GetStocktakeID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	stocktake, err := h.stocktakeService.ServiceGetStocktakeID(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, stocktake)
+}
+
+// Handles the HTTP request to submit a counter's counts to an open stocktake
+func (h *stocktakeHandler) SubmitCounts(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	submission := models.StocktakeSubmission{}
+	err = json.NewDecoder(r.Body).Decode(&submission)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.stocktakeService.ServiceSubmitCounts(id, submission)
+	if err != nil {
+		sendStocktakeError(w, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Counts recorded")
+}
+
+// Handles the HTTP request to review the variance of a stocktake
+func (h *stocktakeHandler) GetVariance(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	variance, err := h.stocktakeService.ServiceGetVariance(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, variance)
+}
+
+// Handles the HTTP request to finalize a stocktake and returns its variance
+func (h *stocktakeHandler) FinalizeStocktake(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	variance, err := h.stocktakeService.ServiceFinalizeStocktake(id)
+	if err != nil {
+		sendStocktakeError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, variance)
+}
+
+// Handles the HTTP request to cancel an open stocktake
+func (h *stocktakeHandler) DeleteStocktakeID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.stocktakeService.ServiceDeleteStocktake(id)
+	if err != nil {
+		sendStocktakeError(w, err)
+		return
+	}
+	SendSucces(w, http.StatusNoContent, "Stocktake cancelled")
+}
+
+// Answers 409 when the stocktake is already finalized or another one is open and 400 for anything else
+func sendStocktakeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, dal.ErrStocktakeNotOpen) || errors.Is(err, dal.ErrStocktakeInProgress) ||
+		errors.Is(err, dal.ErrReservedStock) {
+		SendError(w, http.StatusConflict, err)
+		return
+	}
+	SendError(w, http.StatusBadRequest, err)
+}
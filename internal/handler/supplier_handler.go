@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"frapuccino/internal/service"
+	"frapuccino/models"
+)
+
+type SupplierHandler interface {
+	PostSupplier(w http.ResponseWriter, r *http.Request)
+	GetSuppliers(w http.ResponseWriter, r *http.Request)
+	GetSupplierID(w http.ResponseWriter, r *http.Request)
+	PutSupplierID(w http.ResponseWriter, r *http.Request)
+	DeleteSupplierID(w http.ResponseWriter, r *http.Request)
+}
+
+type supplierHandler struct {
+	supplierService service.SupplierService
+}
+
+// Initializes and returns a new instance of supplierHandler with the provided service
+func NewSupplierHandler(supplierService service.SupplierService) SupplierHandler {
+	return &supplierHandler{supplierService: supplierService}
+}
+
+// Handles the HTTP request to add a new supplier
+func (h *supplierHandler) PostSupplier(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	supplier := models.Supplier{}
+	err := json.NewDecoder(r.Body).Decode(&supplier)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := h.supplierService.ServicePostSupplier(supplier)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, map[string]int{"supplier_id": id})
+}
+
+// Handles the HTTP request to retrieve all suppliers and returns them as JSON
+func (h *supplierHandler) GetSuppliers(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := h.supplierService.ServiceGetSuppliers()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, suppliers)
+}
+
+// Handles the HTTP request to retrieve a specific supplier by ID and returns it as JSON
+func (h *supplierHandler) GetSupplierID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	supplier, err := h.supplierService.ServiceGetSupplierID(id)
+	if err != nil {
+		SendError(w, http.StatusNotFound, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, supplier)
+}
+
+// Handles the HTTP request to update a specific supplier by ID
+func (h *supplierHandler) PutSupplierID(w http.ResponseWriter, r *http.Request) {
+	if err := CheckContentType(r); err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	supplier := models.Supplier{}
+	err = json.NewDecoder(r.Body).Decode(&supplier)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.supplierService.ServicePutSupplierID(id, supplier)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusOK, "Supplier updated")
+}
+
+// Handles the HTTP request to delete a specific supplier by ID
+func (h *supplierHandler) DeleteSupplierID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.supplierService.ServiceDeleteSupplier(id)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err)
+		return
+	}
+	SendSucces(w, http.StatusNoContent, "Supplier deleted")
+}
@@ -1,13 +1,24 @@
 package service
 
 import (
+	"errors"
+	"sort"
+	"strconv"
+
 	"frapuccino/internal/dal"
 	"frapuccino/models"
 )
 
 type AggregationsService interface {
 	ServiceTotalSales() (float64, error)
-	ServicePopularItems() (error, []models.Popular)
+	ServicePopularItems(attribution string) (error, []models.Popular)
+	ServicePrepTime() ([]models.PrepTimeReport, error)
+	ServiceMenuMargins(threshold string) ([]models.MenuMargin, error)
+	ServiceMarginAlerts() ([]models.MarginAlert, error)
+	ServiceStockAlerts() ([]models.StockAlert, error)
+	ServiceShrinkage(from, to, ingredientId string) ([]models.ShrinkageReport, error)
+	ServiceWaste(from, to, period string) (models.WasteReport, error)
+	ServiceInventoryValuation(asOf, method string) (models.InventoryValuation, error)
 }
 
 type aggregationsService struct {
@@ -24,7 +35,147 @@ func (s *aggregationsService) ServiceTotalSales() (float64, error) {
 	return s.aggregationsRepo.RepositoryTotalSales()
 }
 
-// Finds and returns a sorted list of popular items based on quantities ordered
-func (s *aggregationsService) ServicePopularItems() (error, []models.Popular) {
-	return s.aggregationsRepo.RepositoryPopularItem()
+// Finds and returns a sorted list of popular items based on quantities ordered. Bundles are counted as sold
+// by default; attribution "component" counts their components instead, with the bundle revenue split between them
+func (s *aggregationsService) ServicePopularItems(attribution string) (error, []models.Popular) {
+	switch attribution {
+	case "", "bundle":
+		return s.aggregationsRepo.RepositoryPopularItem(false)
+	case "component":
+		return s.aggregationsRepo.RepositoryPopularItem(true)
+	}
+	return errors.New("attribution must be 'bundle' or 'component'"), nil
+}
+
+// Reports estimated vs actual prep time for closed orders with the difference and accuracy
+func (s *aggregationsService) ServicePrepTime() ([]models.PrepTimeReport, error) {
+	report, err := s.aggregationsRepo.RepositoryPrepTime()
+	if err != nil {
+		return nil, err
+	}
+	for i := range report {
+		report[i].Difference = report[i].ActualSeconds - report[i].EstimatedSeconds
+		if report[i].ActualSeconds > 0 {
+			report[i].Accuracy = float64(report[i].EstimatedSeconds) / float64(report[i].ActualSeconds) * 100
+		}
+	}
+	return report, nil
+}
+
+// Reports cost and gross margin of every menu item, flagging items below the threshold
+func (s *aggregationsService) ServiceMenuMargins(threshold string) ([]models.MenuMargin, error) {
+	limit := marginThreshold()
+	if threshold != "" {
+		parsed, err := strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return nil, errors.New("threshold must be a number")
+		}
+		limit = parsed
+	}
+	report, err := s.aggregationsRepo.RepositoryMenuMargins()
+	if err != nil {
+		return nil, err
+	}
+	for i := range report {
+		report[i].Margin = report[i].Price - report[i].Cost
+		report[i].MarginPercent = marginPercent(report[i].Price, report[i].Cost)
+		report[i].BelowThreshold = report[i].MarginPercent < limit
+	}
+	return report, nil
+}
+
+// Retrieves the alerts raised when price changes pushed margins below the threshold
+func (s *aggregationsService) ServiceMarginAlerts() ([]models.MarginAlert, error) {
+	return s.aggregationsRepo.RepositoryMarginAlerts()
+}
+
+// Retrieves the alerts raised when stock changes took ingredients down to their reorder point
+func (s *aggregationsService) ServiceStockAlerts() ([]models.StockAlert, error) {
+	return s.aggregationsRepo.RepositoryStockAlerts()
+}
+
+// Reports the variance of finalized stocktakes between optional from and to times, optionally for one ingredient
+func (s *aggregationsService) ServiceShrinkage(from, to, ingredientId string) ([]models.ShrinkageReport, error) {
+	start, err := parseLedgerTime("from", from, false)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseLedgerTime("to", to, true)
+	if err != nil {
+		return nil, err
+	}
+	id := 0
+	if ingredientId != "" {
+		id, err = strconv.Atoi(ingredientId)
+		if err != nil || id <= 0 {
+			return nil, errors.New("ingredientId must be a positive number")
+		}
+	}
+	return s.aggregationsRepo.RepositoryShrinkage(start, end, id)
+}
+
+// Reports the cost of waste between optional from and to times by reason and by day, week or month
+func (s *aggregationsService) ServiceWaste(from, to, period string) (models.WasteReport, error) {
+	report := models.WasteReport{Period: period, ByReason: []models.WasteReasonTotal{}}
+	switch period {
+	case "":
+		report.Period = "day"
+	case "day", "week", "month":
+	default:
+		return report, errors.New("period must be day, week or month")
+	}
+	var err error
+	report.From, err = parseLedgerTime("from", from, false)
+	if err != nil {
+		return report, err
+	}
+	report.To, err = parseLedgerTime("to", to, true)
+	if err != nil {
+		return report, err
+	}
+	report.ByPeriod, err = s.aggregationsRepo.RepositoryWaste(report.From, report.To, report.Period)
+	if err != nil {
+		return report, err
+	}
+	index := make(map[string]int)
+	for _, total := range report.ByPeriod {
+		i, ok := index[total.Reason]
+		if !ok {
+			i = len(report.ByReason)
+			index[total.Reason] = i
+			report.ByReason = append(report.ByReason, models.WasteReasonTotal{Reason: total.Reason})
+		}
+		report.ByReason[i].Entries += total.Entries
+		report.ByReason[i].Cost += total.Cost
+		report.Total += total.Cost
+	}
+	sort.Slice(report.ByReason, func(a, b int) bool {
+		return report.ByReason[a].Cost > report.ByReason[b].Cost
+	})
+	return report, nil
+}
+
+// Values the stock on hand now or as of a past time by weighted average, FIFO or last cost
+func (s *aggregationsService) ServiceInventoryValuation(asOf, method string) (models.InventoryValuation, error) {
+	valuation := models.InventoryValuation{Method: method, Items: []models.IngredientValuation{}}
+	switch method {
+	case "":
+		valuation.Method = "average"
+	case "average", "fifo", "last":
+	default:
+		return valuation, errors.New("method must be average, fifo or last")
+	}
+	var err error
+	valuation.AsOf, err = parseLedgerTime("asOf", asOf, true)
+	if err != nil {
+		return valuation, err
+	}
+	valuation.Items, err = s.aggregationsRepo.RepositoryInventoryValuation(valuation.AsOf, valuation.Method)
+	if err != nil {
+		return valuation, err
+	}
+	for _, item := range valuation.Items {
+		valuation.Total += item.Value
+	}
+	return valuation, nil
 }
@@ -0,0 +1,38 @@
+package service
+
+import "strings"
+
+// Lowercases, trims and de-duplicates allergen names so they compare the same everywhere
+func normalizeAllergens(allergens []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, allergen := range allergens {
+		allergen = strings.ToLower(strings.TrimSpace(allergen))
+		if allergen == "" || seen[allergen] {
+			continue
+		}
+		seen[allergen] = true
+		normalized = append(normalized, allergen)
+	}
+	return normalized
+}
+
+// Parses a comma separated allergen list such as "milk,nuts"
+func ParseAllergenList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return normalizeAllergens(strings.Split(list, ","))
+}
+
+// Reports whether any of the allergens is in the excluded list
+func containsAllergen(allergens, excluded []string) bool {
+	for _, allergen := range allergens {
+		for _, e := range excluded {
+			if allergen == e {
+				return true
+			}
+		}
+	}
+	return false
+}
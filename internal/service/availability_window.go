@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"frapuccino/models"
+)
+
+// Validates availability windows: ISO weekdays, "15:04" times given in pairs and "2006-01-02" date ranges
+func checkAvailabilityWindows(windows []models.AvailabilityWindow) error {
+	for _, window := range windows {
+		for _, day := range window.DaysOfWeek {
+			if day < 1 || day > 7 {
+				return errors.New("Days of week must be between 1 (Monday) and 7 (Sunday)")
+			}
+		}
+		if (window.StartTime == nil) != (window.EndTime == nil) {
+			return errors.New("Availability window needs both start_time and end_time")
+		}
+		if window.StartTime != nil {
+			start, err := time.Parse("15:04", *window.StartTime)
+			if err != nil {
+				return fmt.Errorf("Invalid start_time %q, expected HH:MM", *window.StartTime)
+			}
+			end, err := time.Parse("15:04", *window.EndTime)
+			if err != nil {
+				return fmt.Errorf("Invalid end_time %q, expected HH:MM", *window.EndTime)
+			}
+			if start.Equal(end) {
+				return errors.New("Availability window start_time and end_time cannot be equal")
+			}
+		}
+		var start, end time.Time
+		var err error
+		if window.StartDate != nil {
+			start, err = time.Parse("2006-01-02", *window.StartDate)
+			if err != nil {
+				return fmt.Errorf("Invalid start_date %q, expected YYYY-MM-DD", *window.StartDate)
+			}
+		}
+		if window.EndDate != nil {
+			end, err = time.Parse("2006-01-02", *window.EndDate)
+			if err != nil {
+				return fmt.Errorf("Invalid end_date %q, expected YYYY-MM-DD", *window.EndDate)
+			}
+		}
+		if window.StartDate != nil && window.EndDate != nil && end.Before(start) {
+			return errors.New("Availability window end_date cannot be before start_date")
+		}
+	}
+	return nil
+}
+
+// Parses the "at" preview time of the menu. Windows are local wall-clock times, so an offset in the value is ignored
+func ParseMenuTime(at string) (*string, error) {
+	if at == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02T15:04:05", at)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, at)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid at %q, expected a time like 2006-01-02T15:04:05", at)
+	}
+	local := t.Format("2006-01-02 15:04:05")
+	return &local, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"frapuccino/internal/dal"
+	"frapuccino/models"
+)
+
+type CategoryService interface {
+	ServicePostCategory(category models.Category) error
+	ServiceGetCategories() ([]models.Category, error)
+	ServiceGetCategoryID(id int) (models.Category, error)
+	ServicePutCategoryID(id int, category models.Category) error
+	ServiceDeleteCategory(id int) error
+}
+
+type categoryService struct {
+	categoryRepo dal.CategoryRepository
+}
+
+// Initializes and returns a new instance of categoryService with the provided repository
+func NewCategoryService(categoryRepo dal.CategoryRepository) CategoryService {
+	return &categoryService{categoryRepo: categoryRepo}
+}
+
+// Adds a new category after validation
+func (s *categoryService) ServicePostCategory(category models.Category) error {
+	category, err := s.CheckCategory(0, category)
+	if err != nil {
+		return err
+	}
+	return s.categoryRepo.PostCategory(category)
+}
+
+// Retrieves all categories ordered by sort order
+func (s *categoryService) ServiceGetCategories() ([]models.Category, error) {
+	return s.categoryRepo.GetCategories()
+}
+
+// Retrieves a specific category by ID
+func (s *categoryService) ServiceGetCategoryID(id int) (models.Category, error) {
+	return s.categoryRepo.GetCategoryID(id)
+}
+
+// Updates a category by ID, refusing parents that would create a cycle
+func (s *categoryService) ServicePutCategoryID(id int, category models.Category) error {
+	category, err := s.CheckCategory(id, category)
+	if err != nil {
+		return err
+	}
+	return s.categoryRepo.UpdateCategory(id, category)
+}
+
+// Deletes a category by ID when no menu items or subcategories reference it
+func (s *categoryService) ServiceDeleteCategory(id int) error {
+	return s.categoryRepo.DeleteCategory(id)
+}
+
+// Validates a category and defaults it to active; id is 0 for a new category
+func (s *categoryService) CheckCategory(id int, category models.Category) (models.Category, error) {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return category, errors.New("Missing name")
+	}
+	if category.Active == nil {
+		active := true
+		category.Active = &active
+	}
+	if err := checkAvailabilityWindows(category.Availability); err != nil {
+		return category, err
+	}
+	if category.ParentID == nil {
+		return category, nil
+	}
+	if _, err := s.categoryRepo.GetCategoryID(*category.ParentID); err != nil {
+		return category, err
+	}
+	if id != 0 {
+		cycle, err := s.categoryRepo.IsAncestor(id, *category.ParentID)
+		if err != nil {
+			return category, err
+		}
+		if cycle {
+			return category, errors.New("Category cannot be nested inside itself or its subcategories")
+		}
+	}
+	return category, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"math"
+
+	"frapuccino/models"
+)
+
+// ServiceAdjustInv applies a signed change to an ingredient's stock and returns the new level. Deliveries add stock,
+// waste removes it and corrections may go either way.
+func (s *invService) ServiceAdjustInv(id int, adjustment models.InventoryAdjustment) (models.InventoryLevel, error) {
+	level := models.InventoryLevel{IngredientID: id}
+	if math.IsNaN(adjustment.Delta) || math.IsInf(adjustment.Delta, 0) || adjustment.Delta == 0 {
+		return level, errors.New("Delta must be a non-zero number")
+	}
+	switch adjustment.Reason {
+	case "delivery":
+		if adjustment.Delta < 0 {
+			return level, errors.New("A delivery must have a positive delta")
+		}
+	case "waste":
+		if adjustment.Delta > 0 {
+			return level, errors.New("Waste must have a negative delta")
+		}
+	case "correction":
+	default:
+		return level, errors.New("Reason must be one of delivery, waste or correction")
+	}
+	if adjustment.ExpiresAt != nil && adjustment.Reason != "delivery" {
+		return level, errors.New("Only a delivery can set an expiry date")
+	}
+	if err := checkLotExpiry(adjustment.ExpiresAt); err != nil {
+		return level, err
+	}
+	var err error
+	level.Quantity, err = s.invRepo.AdjustItem(id, adjustment)
+	return level, err
+}
+
+// ServiceCountInv replaces an ingredient's stock with a counted level and records the variance.
+func (s *invService) ServiceCountInv(id int, count models.StockCount) (models.StockCount, error) {
+	if math.IsNaN(count.CountedQuantity) || math.IsInf(count.CountedQuantity, 0) || count.CountedQuantity < 0 {
+		return count, errors.New("Counted quantity must be a non-negative number")
+	}
+	return s.invRepo.CountItem(id, count.CountedQuantity)
+}
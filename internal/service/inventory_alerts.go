@@ -0,0 +1,37 @@
+package service
+
+import (
+	"errors"
+
+	"frapuccino/models"
+)
+
+// ServiceGetLowStock lists the ingredients at or below their reorder point with the quantity that brings each back to
+// its par level.
+func (s *invService) ServiceGetLowStock() ([]models.LowStockItem, error) {
+	items, err := s.invRepo.GetLowStock()
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		if item.ParLevel != nil {
+			quantity := *item.ParLevel - item.Quantity
+			items[i].ReorderQuantity = &quantity
+		}
+	}
+	return items, nil
+}
+
+// Reorder points and par levels are optional, but cannot be negative and the par level cannot be under the reorder point
+func checkReorderLevels(item models.InventoryItem) error {
+	if item.ReorderPoint != nil && *item.ReorderPoint < 0 {
+		return errors.New("Reorder point cannot be negative")
+	}
+	if item.ParLevel != nil && *item.ParLevel < 0 {
+		return errors.New("Par level cannot be negative")
+	}
+	if item.ReorderPoint != nil && item.ParLevel != nil && *item.ParLevel < *item.ReorderPoint {
+		return errors.New("Par level cannot be below the reorder point")
+	}
+	return nil
+}
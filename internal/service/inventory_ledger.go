@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"frapuccino/models"
+)
+
+// ServiceGetTransactions lists the stock movements of an ingredient between optional from and to times, with the stock
+// level reconstructed at both ends. A date without a time covers the whole day.
+func (s *invService) ServiceGetTransactions(id int, from, to string) (models.InventoryLedger, error) {
+	ledger := models.InventoryLedger{IngredientID: id}
+	if err := s.checkInvExists(id); err != nil {
+		return ledger, err
+	}
+	var err error
+	ledger.From, err = parseLedgerTime("from", from, false)
+	if err != nil {
+		return ledger, err
+	}
+	ledger.To, err = parseLedgerTime("to", to, true)
+	if err != nil {
+		return ledger, err
+	}
+	if ledger.From != nil && ledger.To != nil && *ledger.From > *ledger.To {
+		return ledger, errors.New("from must not be after to")
+	}
+	ledger.Transactions, err = s.invRepo.GetTransactions(id, ledger.From, ledger.To)
+	if err != nil {
+		return ledger, err
+	}
+	ledger.ClosingQuantity, err = s.invRepo.GetLevelAt(id, ledger.To)
+	if err != nil {
+		return ledger, err
+	}
+	ledger.OpeningQuantity = ledger.ClosingQuantity
+	for _, transaction := range ledger.Transactions {
+		ledger.OpeningQuantity -= transaction.QuantityChange
+	}
+	return ledger, nil
+}
+
+// ServiceGetLevel reconstructs the stock level of an ingredient at a past time, or returns the current level.
+func (s *invService) ServiceGetLevel(id int, at string) (models.InventoryLevel, error) {
+	level := models.InventoryLevel{IngredientID: id}
+	if err := s.checkInvExists(id); err != nil {
+		return level, err
+	}
+	var err error
+	level.At, err = parseLedgerTime("at", at, true)
+	if err != nil {
+		return level, err
+	}
+	level.Quantity, err = s.invRepo.GetLevelAt(id, level.At)
+	return level, err
+}
+
+func (s *invService) checkInvExists(id int) error {
+	exists, err := s.invRepo.CheckIfExists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("Such ID doesn't exist")
+	}
+	return nil
+}
+
+// Parses a local time like 2006-01-02T15:04:05 or a date; a date means its start, or its end when endOfDay is set
+func parseLedgerTime(name, value string, endOfDay bool) (*string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			date = date.Add(24*time.Hour - time.Microsecond)
+		}
+		local := date.Format("2006-01-02 15:04:05.999999")
+		return &local, nil
+	}
+	local, err := ParseMenuTime(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s %q, expected a date like 2006-01-02 or a time like 2006-01-02T15:04:05", name, value)
+	}
+	return local, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"frapuccino/models"
+)
+
+// Days ahead the expiring lots report looks by default
+const defaultExpiringDays = 3
+
+// ServiceGetLots lists the lots of an ingredient with stock left, or all of them when includeClosed is set.
+func (s *invService) ServiceGetLots(id int, includeClosed bool) ([]models.InventoryLot, error) {
+	if err := s.checkInvExists(id); err != nil {
+		return nil, err
+	}
+	return s.invRepo.GetLots(id, includeClosed)
+}
+
+// ServiceGetExpiringLots lists the lots with stock left that expire within days, three by default.
+func (s *invService) ServiceGetExpiringLots(days string) ([]models.InventoryLot, error) {
+	within := defaultExpiringDays
+	if days != "" {
+		var err error
+		within, err = strconv.Atoi(days)
+		if err != nil || within < 0 {
+			return nil, errors.New("days must be a non-negative number")
+		}
+	}
+	return s.invRepo.GetExpiringLots(within)
+}
+
+// RunExpiryScheduler periodically writes off expired lots until the process exits.
+func (s *invService) RunExpiryScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.expireLots()
+		<-ticker.C
+	}
+}
+
+func (s *invService) expireLots() {
+	lots, err := s.invRepo.ExpireLots()
+	if err != nil {
+		slog.Error("Failed to write off expired lots", slog.String("ERROR", err.Error()))
+		return
+	}
+	for _, lot := range lots {
+		slog.Info("Expired lot written off", slog.Int("lot_id", lot.LotID), slog.Int("ingredient_id", lot.IngredientID), slog.Float64("quantity", lot.Remaining))
+	}
+}
+
+// Lots are consumed first in, first out unless the ingredient asks for first expired, first out
+func checkConsumption(consumption string) (string, error) {
+	switch consumption {
+	case "":
+		return "fifo", nil
+	case "fifo", "fefo":
+		return consumption, nil
+	}
+	return consumption, errors.New("Consumption must be fifo or fefo")
+}
+
+// An expiry date is a plain date and only given for the lot a delivery creates
+func checkLotExpiry(expiresAt *string) error {
+	if expiresAt == nil {
+		return nil
+	}
+	if _, err := time.Parse(time.DateOnly, *expiresAt); err != nil {
+		return errors.New("Expiry date must be in YYYY-MM-DD format")
+	}
+	return nil
+}
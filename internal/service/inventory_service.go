@@ -2,7 +2,9 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"frapuccino/internal/dal"
 	"frapuccino/models"
@@ -10,22 +12,38 @@ import (
 
 // InventoryService defines methods for handling inventory operations.
 type InventoryService interface {
-	ServiceGetInvItem() ([]models.InventoryItem, error)         // Retrieves all inventory items.
-	ServicePostInv(content models.InventoryItem) error          // Adds new inventory items.
-	ServiceGetInvID(id int) (models.InventoryItem, error)       // Retrieves a single inventory item by ID.
-	ServicePutInvID(id int, newEdit models.InventoryItem) error // Updates an existing inventory item by ID.
+	ServiceGetInvItem(includeArchived bool) ([]models.InventoryItem, error) // Retrieves all inventory items.
+	ServicePostInv(content models.InventoryItem) error                      // Adds new inventory items.
+	ServiceGetInvID(id int) (models.InventoryItem, error)                   // Retrieves a single inventory item by ID.
+	ServicePutInvID(id int, newEdit models.InventoryItem) error             // Updates an existing inventory item by ID.
 	// EditInvStructure(EditableStructure models.InventoryItem, newEdit models.InventoryItem) (models.InventoryItem, error) // Edits specific fields of an inventory item.
-	ServiceInvDelete(id int) error // Deletes an inventory item by ID.
+	ServiceInvDelete(id int) error                                                                        // Deletes an inventory item by ID.
+	ServiceInvArchive(id int) error                                                                       // Archives an inventory item no recipe uses.
+	ServiceInvRestore(id int) error                                                                       // Restores an archived inventory item.
+	ServiceGetUsages(id int) ([]models.IngredientUsage, error)                                            // Lists recipes that use an inventory item.
+	ServiceInvReplace(id int, replacement models.IngredientReplacement) ([]models.IngredientUsage, error) // Replaces an inventory item in all recipes.
+	ServiceGetTransactions(id int, from, to string) (models.InventoryLedger, error)                       // Lists the stock movements of an inventory item.
+	ServiceGetLevel(id int, at string) (models.InventoryLevel, error)                                     // Reconstructs the stock level of an inventory item.
+	ServiceAdjustInv(id int, adjustment models.InventoryAdjustment) (models.InventoryLevel, error)        // Changes the stock of an inventory item by a delta.
+	ServiceCountInv(id int, count models.StockCount) (models.StockCount, error)                           // Replaces the stock of an inventory item with a count.
+	ServiceGetUnits() ([]models.Unit, error)                                                              // Lists the registered units of measure.
+	ServiceGetLowStock() ([]models.LowStockItem, error)                                                   // Lists inventory items at or below their reorder point.
+	ServiceGetLots(id int, includeClosed bool) ([]models.InventoryLot, error)                             // Lists the lots of an inventory item.
+	ServiceGetExpiringLots(days string) ([]models.InventoryLot, error)                                    // Lists lots expiring within a number of days.
+	RunExpiryScheduler(interval time.Duration)                                                            // Writes off expired lots in the background.
+	ServiceLogWaste(entry models.WasteEntry) (models.WasteEntry, error)                                   // Deducts and logs wasted stock.
+	ServiceGetWaste(from, to string) ([]models.WasteEntry, error)                                         // Lists the waste log.
 }
 
 // invService implements the InventoryService interface using InventoryRepository.
 type invService struct {
-	invRepo dal.InventoryRepository
+	invRepo  dal.InventoryRepository
+	unitRepo dal.UnitRepository
 }
 
 // NewInvService creates and returns a new instance of invService.
-func NewInvService(invRepo dal.InventoryRepository) InventoryService {
-	return &invService{invRepo: invRepo}
+func NewInvService(invRepo dal.InventoryRepository, unitRepo dal.UnitRepository) InventoryService {
+	return &invService{invRepo: invRepo, unitRepo: unitRepo}
 }
 
 // ServicePostInv adds new inventory items to the inventory if they pass validation and don't already exist.
@@ -33,6 +51,13 @@ func (s *invService) ServicePostInv(content models.InventoryItem) error {
 	if check, err := s.CheckInvPost(content); !check {
 		return err // Return error if the new item fails validation.
 	}
+	book, err := loadUnits(s.unitRepo)
+	if err != nil {
+		return err
+	}
+	if err := book.checkUnit(content.Unit); err != nil {
+		return err
+	}
 
 	exists, err := s.invRepo.CheckIfNameExists(content.Name)
 	if err != nil {
@@ -42,6 +67,8 @@ func (s *invService) ServicePostInv(content models.InventoryItem) error {
 		return errors.New("Such Item already exists")
 	}
 
+	content.Allergens = normalizeAllergens(content.Allergens)
+	content.Consumption, _ = checkConsumption(content.Consumption)
 	err = s.invRepo.AddItems(content) // Save the updated inventory.
 	if err != nil {
 		return err
@@ -50,9 +77,19 @@ func (s *invService) ServicePostInv(content models.InventoryItem) error {
 	return nil
 }
 
-// ServiceGetInvItem retrieves all inventory items from storage.
-func (s *invService) ServiceGetInvItem() ([]models.InventoryItem, error) {
-	return s.invRepo.ReadJSONInv()
+// ServiceGetInvItem retrieves all inventory items from storage, leaving out archived ones unless asked for.
+func (s *invService) ServiceGetInvItem(includeArchived bool) ([]models.InventoryItem, error) {
+	items, err := s.invRepo.ReadJSONInv()
+	if err != nil || includeArchived {
+		return items, err
+	}
+	active := []models.InventoryItem{}
+	for _, item := range items {
+		if item.ArchivedAt == nil {
+			active = append(active, item)
+		}
+	}
+	return active, nil
 }
 
 func (s *invService) ServiceGetInvID(id int) (models.InventoryItem, error) {
@@ -74,22 +111,51 @@ func (s *invService) ServiceGetInvID(id int) (models.InventoryItem, error) {
 	return newGetInvID, nil
 }
 
-// ServicePutInvID updates an existing inventory item identified by ID with new data.
+// ServicePutInvID updates an existing inventory item identified by ID with new data. The quantity is left as it is;
+// stock changes go through adjustments and stock counts.
 func (s *invService) ServicePutInvID(id int, newEdit models.InventoryItem) error {
-	exists, err := s.invRepo.CheckIfExists(id)
+	current, err := s.ServiceGetInvID(id)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return errors.New("Such ID doesn't exist")
+	if newEdit.Quantity != 0 && newEdit.Quantity != current.Quantity {
+		return errors.New("Quantity can't be set here, use /inventory/{id}/adjustments or /inventory/{id}/stocktake")
+	}
+	book, err := loadUnits(s.unitRepo)
+	if err != nil {
+		return err
+	}
+	if err := book.checkUnit(newEdit.Unit); err != nil {
+		return err
+	}
+	if !book.compatible(current.Unit, newEdit.Unit) {
+		return fmt.Errorf("Unit %s cannot be converted to %s, recipes and stock are kept in it", current.Unit, newEdit.Unit)
+	}
+	if err := checkNutrition(newEdit.Nutrition); err != nil {
+		return err
+	}
+	if err := checkReorderLevels(newEdit); err != nil {
+		return err
+	}
+	if newEdit.Consumption == "" {
+		newEdit.Consumption = current.Consumption
+	}
+	if newEdit.Consumption, err = checkConsumption(newEdit.Consumption); err != nil {
+		return err
 	}
 
+	newEdit.Allergens = normalizeAllergens(newEdit.Allergens)
 	if err := s.invRepo.UpdateItem(id, newEdit); err != nil {
 		return err
 	}
 	return nil
 }
 
+// ServiceGetUnits lists the units of measure ingredients and recipes can use.
+func (s *invService) ServiceGetUnits() ([]models.Unit, error) {
+	return s.unitRepo.GetUnits()
+}
+
 func (s *invService) ServiceInvDelete(id int) error {
 	exists, err := s.invRepo.CheckIfExists(id)
 	if err != nil {
@@ -98,8 +164,66 @@ func (s *invService) ServiceInvDelete(id int) error {
 	if !exists {
 		return errors.New("Such ID doesn't exist")
 	}
-	s.invRepo.DeleteItem(id)
-	return nil
+	return s.invRepo.DeleteItem(id)
+}
+
+// ServiceInvArchive hides an ingredient no recipe uses while keeping its history.
+func (s *invService) ServiceInvArchive(id int) error {
+	return s.invRepo.ArchiveItem(id, true)
+}
+
+// ServiceInvRestore returns an archived ingredient to the inventory.
+func (s *invService) ServiceInvRestore(id int) error {
+	return s.invRepo.ArchiveItem(id, false)
+}
+
+// ServiceGetUsages lists the recipes, variants and modifiers that depend on an ingredient.
+func (s *invService) ServiceGetUsages(id int) ([]models.IngredientUsage, error) {
+	exists, err := s.invRepo.CheckIfExists(id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("Such ID doesn't exist")
+	}
+	return s.invRepo.GetUsages(id)
+}
+
+// ServiceInvReplace moves every recipe from an ingredient to a substitute and archives the ingredient,
+// returning the usages that were rewritten.
+func (s *invService) ServiceInvReplace(id int, replacement models.IngredientReplacement) ([]models.IngredientUsage, error) {
+	if replacement.Ratio == 0 {
+		replacement.Ratio = 1
+	}
+	if replacement.Ratio < 0 {
+		return nil, errors.New("Ratio cannot be negative")
+	}
+	if replacement.SubstituteID == id {
+		return nil, errors.New("Substitute must be a different ingredient")
+	}
+	substitute, err := s.ServiceGetInvID(replacement.SubstituteID)
+	if err != nil {
+		return nil, errors.New("Substitute doesn't exist")
+	}
+	if substitute.ArchivedAt != nil {
+		return nil, errors.New("Substitute is archived")
+	}
+	book, err := loadUnits(s.unitRepo)
+	if err != nil {
+		return nil, err
+	}
+	if !book.compatible(book.stock[id], substitute.Unit) {
+		return nil, fmt.Errorf("Substitute is stocked in %s, which recipes in %s cannot be converted to", substitute.Unit, book.stock[id])
+	}
+	usages, err := s.ServiceGetUsages(id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.invRepo.ReplaceItem(id, replacement)
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
 }
 
 func (r *invService) CheckInvPost(newinv models.InventoryItem) (bool, error) {
@@ -110,6 +234,18 @@ func (r *invService) CheckInvPost(newinv models.InventoryItem) (bool, error) {
 	if newinv.Quantity < 0 {
 		return false, errors.New("Quantity cannot be negative")
 	}
+	if newinv.Price < 0 {
+		return false, errors.New("Price cannot be negative")
+	}
+	if err := checkNutrition(newinv.Nutrition); err != nil {
+		return false, err
+	}
+	if err := checkReorderLevels(newinv); err != nil {
+		return false, err
+	}
+	if _, err := checkConsumption(newinv.Consumption); err != nil {
+		return false, err
+	}
 	newInvUnit := strings.TrimSpace(newinv.Unit)
 	if newInvUnit == "" {
 		return false, errors.New("Missing Unit")
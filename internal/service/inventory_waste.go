@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"frapuccino/models"
+)
+
+// ServiceLogWaste deducts wasted stock of an ingredient, or the whole recipe of menu item servings, and records it in
+// the waste log with its cost.
+func (s *invService) ServiceLogWaste(entry models.WasteEntry) (models.WasteEntry, error) {
+	if (entry.IngredientID == nil) == (entry.MenuItemID == nil) {
+		return entry, errors.New("Give either ingredient_id or menu_item_id")
+	}
+	if entry.VariantID != nil && entry.MenuItemID == nil {
+		return entry, errors.New("A variant can only be given with a menu item")
+	}
+	if math.IsNaN(entry.Quantity) || math.IsInf(entry.Quantity, 0) || entry.Quantity <= 0 {
+		return entry, errors.New("Quantity must be a positive number")
+	}
+	if !validWasteReason(entry.Reason) {
+		return entry, errors.New("Reason must be one of spill, remake, spoilage, expired, breakage or other")
+	}
+	entry.Note = strings.TrimSpace(entry.Note)
+	return s.invRepo.LogWaste(entry)
+}
+
+// ServiceGetWaste lists the waste log between optional from and to times.
+func (s *invService) ServiceGetWaste(from, to string) ([]models.WasteEntry, error) {
+	start, err := parseLedgerTime("from", from, false)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseLedgerTime("to", to, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.invRepo.GetWaste(start, end)
+}
+
+func validWasteReason(reason string) bool {
+	switch reason {
+	case "spill", "remake", "spoilage", "expired", "breakage", "other":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"os"
+	"strconv"
+)
+
+// Margin percent below which a price change raises an alert, overridable with MARGIN_ALERT_THRESHOLD
+const defaultMarginThreshold = 30.0
+
+func marginThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("MARGIN_ALERT_THRESHOLD"), 64)
+	if err != nil {
+		return defaultMarginThreshold
+	}
+	return threshold
+}
+
+// Returns the gross margin of a price over its cost as a percentage of the price
+func marginPercent(price, cost float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	return (price - cost) / price * 100
+}
@@ -0,0 +1,412 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"frapuccino/models"
+)
+
+// Columns of the menu CSV; an item spans one row per recipe ingredient
+var menuCSVHeader = []string{"name", "description", "price", "category", "prep_time", "station", "ingredient", "quantity", "unit"}
+
+// Exports the active menu with categories and recipe ingredients referenced by name, ordered by product ID
+func (s *menuService) ServiceExportMenu() ([]models.MenuExportItem, error) {
+	menu, err := s.menuRepo.GetMenuRepo()
+	if err != nil {
+		return nil, err
+	}
+	ingredientNames, err := s.menuRepo.GetIngredientNames()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(menu, func(i, j int) bool { return menu[i].ID < menu[j].ID })
+	export := []models.MenuExportItem{}
+	for _, item := range menu {
+		if item.ArchivedAt != nil {
+			continue
+		}
+		exported := models.MenuExportItem{
+			Name:        item.Name,
+			Description: item.Description,
+			Price:       item.Price,
+			Category:    item.Category,
+			PrepTime:    item.PrepTime,
+			Station:     item.Station,
+			Ingredients: []models.MenuExportIngredient{},
+		}
+		for _, ingredient := range item.Ingredients {
+			exported.Ingredients = append(exported.Ingredients, models.MenuExportIngredient{
+				Ingredient: ingredientNames[ingredient.IngredientID],
+				Quantity:   ingredient.Quantity,
+				Unit:       ingredient.Unit,
+			})
+		}
+		export = append(export, exported)
+	}
+	return export, nil
+}
+
+// Imports menu items matched to existing ones by name ignoring case. Every row is validated with CheckMenu first and
+// nothing is written when a row fails or on a dry run; otherwise changed and new items are written in one transaction
+func (s *menuService) ServiceImportMenu(rows []models.MenuExportItem, rowNumbers []int, dryRun bool) (models.MenuImportResult, error) {
+	result := models.MenuImportResult{
+		DryRun:            dryRun,
+		Created:           []string{},
+		Updated:           []models.MenuImportDiff{},
+		Unchanged:         []string{},
+		CategoriesCreated: []string{},
+	}
+	menu, err := s.menuRepo.GetMenuRepo()
+	if err != nil {
+		return result, err
+	}
+	categories, err := s.categoryRepo.GetCategories()
+	if err != nil {
+		return result, err
+	}
+	ingredientNames, err := s.menuRepo.GetIngredientNames()
+	if err != nil {
+		return result, err
+	}
+	existing := make(map[string][]models.MenuItem)
+	for _, item := range menu {
+		key := importKey(item.Name)
+		existing[key] = append(existing[key], item)
+	}
+	categoryByName := make(map[string]models.Category)
+	for _, category := range categories {
+		categoryByName[importKey(category.Name)] = category
+	}
+	ingredientByName := make(map[string]int)
+	for id, name := range ingredientNames {
+		ingredientByName[importKey(name)] = id
+	}
+
+	seen := make(map[string]bool)
+	newCategories := make(map[string]bool)
+	writes := []models.MenuItem{}
+	priceChanges := make(map[int]float64)
+	for i, row := range rows {
+		rowNumber := i + 1
+		if i < len(rowNumbers) {
+			rowNumber = rowNumbers[i]
+		}
+		item, old, err := s.importItem(row, existing, categoryByName, ingredientByName, seen)
+		if err != nil {
+			result.Errors = append(result.Errors, models.MenuImportError{Row: rowNumber, Name: row.Name, Error: err.Error()})
+			continue
+		}
+		if item.CategoryID == nil && item.Category != "" && !newCategories[importKey(item.Category)] {
+			newCategories[importKey(item.Category)] = true
+			result.CategoriesCreated = append(result.CategoriesCreated, item.Category)
+		}
+		if old == nil {
+			result.Created = append(result.Created, item.Name)
+			writes = append(writes, item)
+			continue
+		}
+		fields := menuItemChanges(*old, item)
+		if len(fields) == 0 {
+			result.Unchanged = append(result.Unchanged, item.Name)
+			continue
+		}
+		result.Updated = append(result.Updated, models.MenuImportDiff{ProductID: old.ID, Name: item.Name, Fields: fields})
+		writes = append(writes, item)
+		if old.Price != item.Price {
+			priceChanges[old.ID] = old.Price
+		}
+	}
+	if len(result.Errors) > 0 || dryRun {
+		return result, nil
+	}
+	versioned, err := s.publishLiveEdit("Menu import", func(items []models.MenuItem) ([]models.MenuItem, error) {
+		return s.importVersionItems(items, writes)
+	})
+	if err != nil {
+		return result, err
+	}
+	if !versioned {
+		err = s.menuRepo.ImportMenu(writes)
+		if err != nil {
+			return result, err
+		}
+	}
+	for _, item := range writes {
+		if oldPrice, ok := priceChanges[item.ID]; ok {
+			s.checkMarginAlert(item.ID, oldPrice, item.Price)
+		}
+	}
+	return result, nil
+}
+
+// Applies imported items to the live menu as version items: updates keep the variants, modifier groups, availability
+// and bundle slots import leaves alone, new items get temporary negative IDs, and missing categories are created
+func (s *menuService) importVersionItems(items, writes []models.MenuItem) ([]models.MenuItem, error) {
+	index := make(map[int]int)
+	nextId := -1
+	for i, item := range items {
+		index[item.ID] = i
+	}
+	for _, item := range writes {
+		if item.CategoryID == nil && item.Category != "" {
+			category, err := s.categoryRepo.GetCategoryByName(item.Category)
+			if err != nil {
+				active := true
+				err = s.categoryRepo.PostCategory(models.Category{Name: item.Category, Active: &active})
+				if err != nil {
+					return nil, err
+				}
+				category, err = s.categoryRepo.GetCategoryByName(item.Category)
+				if err != nil {
+					return nil, err
+				}
+			}
+			item.CategoryID = &category.CategoryID
+			item.Category = category.Name
+		}
+		if item.ID == 0 {
+			item.ID = nextId
+			nextId--
+			items = append(items, versionItem(item))
+			continue
+		}
+		i, ok := index[item.ID]
+		if !ok {
+			return nil, fmt.Errorf("menu item %s is archived, restore it first", item.Name)
+		}
+		items[i].Name = item.Name
+		items[i].Description = item.Description
+		items[i].Price = item.Price
+		items[i].CategoryID = item.CategoryID
+		items[i].Category = item.Category
+		items[i].PrepTime = item.PrepTime
+		items[i].Station = item.Station
+		items[i].Ingredients = item.Ingredients
+	}
+	return items, nil
+}
+
+// Turns one imported row into a menu item, resolving its category and ingredients by name and validating it with
+// CheckMenu. Returns the existing item it updates, or nil for a new item
+func (s *menuService) importItem(
+	row models.MenuExportItem,
+	existing map[string][]models.MenuItem,
+	categoryByName map[string]models.Category,
+	ingredientByName map[string]int,
+	seen map[string]bool,
+) (models.MenuItem, *models.MenuItem, error) {
+	item := models.MenuItem{
+		Name:        strings.TrimSpace(row.Name),
+		Description: row.Description,
+		Price:       row.Price,
+		Category:    strings.TrimSpace(row.Category),
+		PrepTime:    row.PrepTime,
+		Station:     row.Station,
+		Ingredients: []models.MenuItemIngredient{},
+	}
+	item = setPrepDefaults(item)
+	key := importKey(item.Name)
+	if seen[key] {
+		return item, nil, errors.New("Menu item " + item.Name + " appears more than once in the import")
+	}
+	seen[key] = true
+	if category, ok := categoryByName[importKey(item.Category)]; ok {
+		item.CategoryID = &category.CategoryID
+		item.Category = category.Name
+	}
+	used := make(map[int]bool)
+	for _, ingredient := range row.Ingredients {
+		id, ok := ingredientByName[importKey(ingredient.Ingredient)]
+		if !ok {
+			return item, nil, errors.New("Unknown ingredient " + ingredient.Ingredient)
+		}
+		if used[id] {
+			return item, nil, errors.New("Ingredient " + ingredient.Ingredient + " is listed twice")
+		}
+		used[id] = true
+		item.Ingredients = append(item.Ingredients, models.MenuItemIngredient{IngredientID: id, Quantity: ingredient.Quantity, Unit: ingredient.Unit})
+	}
+	var old *models.MenuItem
+	switch matches := existing[key]; len(matches) {
+	case 0:
+	case 1:
+		old = &matches[0]
+		item.ID = old.ID
+	default:
+		return item, nil, errors.New("Several menu items are named " + item.Name)
+	}
+	// CheckMenu requires an ID; new items get theirs from the database, so any placeholder passes
+	checked := item
+	if checked.ID == 0 {
+		checked.ID = -1
+	}
+	if err := s.CheckMenu(checked); err != nil {
+		return item, nil, err
+	}
+	item, err := s.resolveUnits(item)
+	if err != nil {
+		return item, nil, err
+	}
+	return item, old, nil
+}
+
+// Lists the imported fields that differ between an existing menu item and its imported version
+func menuItemChanges(old, item models.MenuItem) []string {
+	fields := []string{}
+	if old.Name != item.Name {
+		fields = append(fields, "name")
+	}
+	if old.Description != item.Description {
+		fields = append(fields, "description")
+	}
+	if old.Price != item.Price {
+		fields = append(fields, "price")
+	}
+	if importKey(old.Category) != importKey(item.Category) {
+		fields = append(fields, "category")
+	}
+	if *old.PrepTime != *item.PrepTime {
+		fields = append(fields, "prep_time")
+	}
+	if old.Station != item.Station {
+		fields = append(fields, "station")
+	}
+	recipe := make(map[int]models.MenuItemIngredient)
+	for _, ingredient := range old.Ingredients {
+		recipe[ingredient.IngredientID] = ingredient
+	}
+	changed := len(old.Ingredients) != len(item.Ingredients)
+	for _, ingredient := range item.Ingredients {
+		if line, ok := recipe[ingredient.IngredientID]; !ok || line != ingredient {
+			changed = true
+		}
+	}
+	if changed {
+		fields = append(fields, "ingredients")
+	}
+	return fields
+}
+
+// Names are matched ignoring case and surrounding spaces
+func importKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Writes exported menu items as CSV with one row per recipe ingredient; items without a recipe get one row
+func WriteMenuCSV(w io.Writer, items []models.MenuExportItem) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(menuCSVHeader)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		fields := []string{
+			item.Name,
+			item.Description,
+			strconv.FormatFloat(item.Price, 'f', -1, 64),
+			item.Category,
+			strconv.Itoa(*item.PrepTime),
+			item.Station,
+		}
+		if len(item.Ingredients) == 0 {
+			err = writer.Write(append(fields, "", "", ""))
+		}
+		for _, ingredient := range item.Ingredients {
+			err = writer.Write(append(fields, ingredient.Ingredient, strconv.FormatFloat(ingredient.Quantity, 'f', -1, 64), ingredient.Unit))
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Reads menu items from CSV with a header row naming the columns. Rows of the same item are joined by name and the
+// item fields come from its first row. Returns the line each item starts on for error reporting
+func ParseMenuCSV(r io.Reader) ([]models.MenuExportItem, []int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("CSV must start with a header row")
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[importKey(column)] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, nil, errors.New("CSV header must contain a name column")
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	items := []models.MenuExportItem{}
+	lines := []int{}
+	index := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		name := field(record, "name")
+		i, ok := index[importKey(name)]
+		if !ok {
+			item := models.MenuExportItem{
+				Name:        name,
+				Description: field(record, "description"),
+				Category:    field(record, "category"),
+				Station:     field(record, "station"),
+				Ingredients: []models.MenuExportIngredient{},
+			}
+			if value := field(record, "price"); value != "" {
+				item.Price, err = strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, nil, fmt.Errorf("line %d: price must be a number", line)
+				}
+			}
+			if value := field(record, "prep_time"); value != "" {
+				prepTime, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("line %d: prep_time must be a whole number of seconds", line)
+				}
+				item.PrepTime = &prepTime
+			}
+			i = len(items)
+			index[importKey(name)] = i
+			items = append(items, item)
+			lines = append(lines, line)
+		}
+		ingredient := field(record, "ingredient")
+		if ingredient == "" {
+			continue
+		}
+		quantity, err := strconv.ParseFloat(field(record, "quantity"), 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: quantity must be a number", line)
+		}
+		items[i].Ingredients = append(items[i].Ingredients, models.MenuExportIngredient{
+			Ingredient: ingredient,
+			Quantity:   quantity,
+			Unit:       field(record, "unit"),
+		})
+	}
+	return items, lines, nil
+}
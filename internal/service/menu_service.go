@@ -2,35 +2,88 @@ package service
 
 import (
 	"errors"
+	"log/slog"
 	"strings"
+	"time"
 
 	"frapuccino/internal/dal"
 	"frapuccino/models"
 )
 
 type MenuService interface {
-	ServiceGetMenuItem() ([]models.MenuItem, error)
+	ServiceGetMenuItem(filter models.MenuFilter) ([]models.MenuItem, error)
+	ServiceGetMenuAvailability() ([]models.MenuAvailability, error)
+	ServiceGetMenuGrouped(filter models.MenuFilter) ([]models.CategoryGroup, error)
 	ServicePostMenu(content models.MenuItem) error
 	ServiceGetMenuID(id int) (models.MenuItem, error)
 	ServicePutMenuID(id int, newEdit models.MenuItem) error
-	ServiceDelete(id int) error
+	ServiceDelete(id int, permanent bool) error
+	ServiceRestore(id int) error
+	ServiceGetPriceHistory(id int) ([]models.PriceHistory, error)
+	ServicePostScheduledPrice(id int, change models.ScheduledPrice) error
+	ServiceGetScheduledPrices(id int) ([]models.ScheduledPrice, error)
+	ServiceDeleteScheduledPrice(id, scheduleId int) error
+	RunPriceScheduler(interval time.Duration)
+	ServiceExportMenu() ([]models.MenuExportItem, error)
+	ServiceImportMenu(rows []models.MenuExportItem, rowNumbers []int, dryRun bool) (models.MenuImportResult, error)
+	ServiceGetVersions() ([]models.MenuVersion, error)
+	ServiceGetVersion(id int) (models.MenuVersion, error)
+	ServicePostVersion(version models.MenuVersion) (int, error)
+	ServicePostVersionItem(versionId int, item models.MenuItem) (int, error)
+	ServicePutVersionItem(versionId, productId int, item models.MenuItem) error
+	ServiceDeleteVersionItem(versionId, productId int) error
+	ServiceDeleteVersion(id int) error
+	ServicePreviewVersion(id int) (models.MenuVersionPreview, error)
+	ServicePublishVersion(id int, publishAt string) error
+	ServiceRollbackVersion() (models.MenuVersion, error)
+	RunVersionScheduler(interval time.Duration)
 }
 
 type menuService struct {
-	menuRepo dal.MenuRepository
+	menuRepo     dal.MenuRepository
+	categoryRepo dal.CategoryRepository
+	versionRepo  dal.MenuVersionRepository
+	unitRepo     dal.UnitRepository
 }
 
-// Initializes and returns a new instance of menuService with the provided repository
-func NewMenuService(menuRepo dal.MenuRepository) MenuService {
-	return &menuService{menuRepo: menuRepo}
+// Initializes and returns a new instance of menuService with the provided repositories
+func NewMenuService(
+	menuRepo dal.MenuRepository,
+	categoryRepo dal.CategoryRepository,
+	versionRepo dal.MenuVersionRepository,
+	unitRepo dal.UnitRepository,
+) MenuService {
+	return &menuService{menuRepo: menuRepo, categoryRepo: categoryRepo, versionRepo: versionRepo, unitRepo: unitRepo}
 }
 
+// Default preparation settings applied when a menu item omits them
+const (
+	defaultPrepTime = 60
+	defaultStation  = "bar"
+)
+
 // Adds new menu items to the menu, checking for duplicates and validating data
 func (s *menuService) ServicePostMenu(content models.MenuItem) error {
-	err := s.CheckMenu(content)
+	content = setPrepDefaults(content)
+	content, err := s.resolveCategory(content)
+	if err != nil {
+		return err
+	}
+	err = s.CheckMenu(content)
 	if err != nil {
 		return err
 	}
+	content, err = s.resolveUnits(content)
+	if err != nil {
+		return err
+	}
+	versioned, err := s.publishLiveEdit("Add "+content.Name, func(items []models.MenuItem) ([]models.MenuItem, error) {
+		content.ID = -1
+		return append(items, versionItem(content)), nil
+	})
+	if versioned || err != nil {
+		return err
+	}
 	err = s.menuRepo.PostRepoMenu(content)
 	if err != nil {
 		return err
@@ -38,28 +91,338 @@ func (s *menuService) ServicePostMenu(content models.MenuItem) error {
 	return nil
 }
 
-// Retrieves all menu items from the repository
-func (s *menuService) ServiceGetMenuItem() ([]models.MenuItem, error) {
-	return s.menuRepo.GetMenuRepo()
+// Retrieves the menu items inside their availability windows at the filter time, optionally leaving out items that cannot
+// be made from current inventory and items whose recipe contains an excluded allergen. Allergens that only come with a
+// modifier do not exclude an item
+func (s *menuService) ServiceGetMenuItem(filter models.MenuFilter) ([]models.MenuItem, error) {
+	menu, err := s.menuRepo.GetMenuRepo()
+	if err != nil {
+		return nil, err
+	}
+	inWindow, err := s.menuRepo.GetProductsAvailableAt(filter.At)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[int]bool)
+	if filter.HideUnavailable {
+		availability, err := s.menuAvailability(menu)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range availability {
+			available[item.ProductID] = item.Available
+		}
+	}
+	visible := []models.MenuItem{}
+	for _, item := range menu {
+		if item.ArchivedAt != nil && !filter.IncludeArchived {
+			continue
+		}
+		if !inWindow[item.ID] {
+			continue
+		}
+		if filter.HideUnavailable && !available[item.ID] {
+			continue
+		}
+		if containsAllergen(item.Allergens, filter.ExcludeAllergens) {
+			continue
+		}
+		visible = append(visible, item)
+	}
+	return visible, nil
+}
+
+// Retrieves the menu grouped into nested active categories ordered by sort order; items without a category come last
+func (s *menuService) ServiceGetMenuGrouped(filter models.MenuFilter) ([]models.CategoryGroup, error) {
+	menu, err := s.ServiceGetMenuItem(filter)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.categoryRepo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[int][]models.MenuItem)
+	uncategorized := []models.MenuItem{}
+	for _, item := range menu {
+		if item.CategoryID == nil {
+			uncategorized = append(uncategorized, item)
+			continue
+		}
+		items[*item.CategoryID] = append(items[*item.CategoryID], item)
+	}
+	groups := buildCategoryGroups(categories, items, nil)
+	if len(uncategorized) > 0 {
+		groups = append(groups, models.CategoryGroup{
+			Name:          "Uncategorized",
+			Items:         uncategorized,
+			Subcategories: []models.CategoryGroup{},
+		})
+	}
+	return groups, nil
 }
 
-// Retrieves a specific menu item by ID, returning an error if not found
+// Builds the category tree under parentID, skipping inactive categories together with their subcategories
+func buildCategoryGroups(categories []models.Category, items map[int][]models.MenuItem, parentID *int) []models.CategoryGroup {
+	groups := []models.CategoryGroup{}
+	for _, category := range categories {
+		if category.Active != nil && !*category.Active {
+			continue
+		}
+		if (parentID == nil) != (category.ParentID == nil) {
+			continue
+		}
+		if parentID != nil && *parentID != *category.ParentID {
+			continue
+		}
+		categoryItems := items[category.CategoryID]
+		if categoryItems == nil {
+			categoryItems = []models.MenuItem{}
+		}
+		id := category.CategoryID
+		groups = append(groups, models.CategoryGroup{
+			CategoryID:    category.CategoryID,
+			Name:          category.Name,
+			SortOrder:     category.SortOrder,
+			Items:         categoryItems,
+			Subcategories: buildCategoryGroups(categories, items, &id),
+		})
+	}
+	return groups
+}
+
+// Resolves the category name of a menu item to its ID when no category ID was given
+func (s *menuService) resolveCategory(item models.MenuItem) (models.MenuItem, error) {
+	if item.CategoryID != nil {
+		category, err := s.categoryRepo.GetCategoryID(*item.CategoryID)
+		if err != nil {
+			return item, err
+		}
+		item.Category = category.Name
+		return item, nil
+	}
+	if strings.TrimSpace(item.Category) == "" {
+		return item, nil
+	}
+	category, err := s.categoryRepo.GetCategoryByName(item.Category)
+	if err != nil {
+		return item, err
+	}
+	item.CategoryID = &category.CategoryID
+	item.Category = category.Name
+	return item, nil
+}
+
+// Retrieves every menu item with the number of servings makeable from current inventory
+func (s *menuService) ServiceGetMenuAvailability() ([]models.MenuAvailability, error) {
+	menu, err := s.menuRepo.GetMenuRepo()
+	if err != nil {
+		return nil, err
+	}
+	return s.menuAvailability(menu)
+}
+
+// Reads inventory availability and derives bundles from their components: each slot allows as many servings as its
+// best option, and the bundle as many as its most limited slot and its own recipe
+func (s *menuService) menuAvailability(menu []models.MenuItem) ([]models.MenuAvailability, error) {
+	availability, err := s.menuRepo.GetMenuAvailability()
+	if err != nil {
+		return nil, err
+	}
+	byProduct := make(map[int]models.MenuAvailability)
+	for _, item := range availability {
+		byProduct[item.ProductID] = item
+	}
+	for _, item := range menu {
+		if len(item.BundleSlots) == 0 {
+			continue
+		}
+		bundle := byProduct[item.ID]
+		for _, slot := range item.BundleSlots {
+			var best *models.MenuAvailability
+			for _, productId := range slot.Options {
+				option, ok := byProduct[productId]
+				if !ok {
+					continue
+				}
+				if best == nil || option.Servings == nil || (best.Servings != nil && *option.Servings > *best.Servings) {
+					best = &option
+				}
+				if best.Servings == nil {
+					break
+				}
+			}
+			if best == nil || best.Servings == nil {
+				continue
+			}
+			servings := *best.Servings / max(slot.Quantity, 1)
+			if bundle.Servings == nil || servings < *bundle.Servings {
+				bundle.Servings = &servings
+				bundle.LimitingIngredient = best.LimitingIngredient
+			}
+		}
+		if bundle.Servings != nil {
+			bundle.Available = *bundle.Servings > 0
+		}
+		byProduct[item.ID] = bundle
+	}
+	for i := range availability {
+		availability[i] = byProduct[availability[i].ProductID]
+	}
+	return availability, nil
+}
+
+// Retrieves a specific menu item by ID with its recipe cost and margin, returning an error if not found
 func (s *menuService) ServiceGetMenuID(id int) (models.MenuItem, error) {
-	return s.menuRepo.GetMenuItemID(id)
+	item, err := s.menuRepo.GetMenuItemID(id)
+	if err != nil {
+		return item, err
+	}
+	cost, err := s.menuRepo.GetMenuItemCost(id)
+	if err != nil {
+		return item, err
+	}
+	margin := marginPercent(item.Price, cost)
+	item.Cost = &cost
+	item.MarginPercent = &margin
+	return item, nil
 }
 
 // Updates a specific menu item by ID with new data provided, validating changes
 func (s *menuService) ServicePutMenuID(id int, newEdit models.MenuItem) error {
-	err := s.CheckMenu(newEdit)
+	newEdit = setPrepDefaults(newEdit)
+	newEdit, err := s.resolveCategory(newEdit)
+	if err != nil {
+		return err
+	}
+	err = s.CheckMenu(newEdit)
+	if err != nil {
+		return err
+	}
+	newEdit, err = s.resolveUnits(newEdit)
+	if err != nil {
+		return err
+	}
+	old, err := s.menuRepo.GetMenuItemID(id)
+	if err != nil {
+		return err
+	}
+	newEdit.ID = id
+	versioned, err := s.publishLiveEdit("Edit "+newEdit.Name, func(items []models.MenuItem) ([]models.MenuItem, error) {
+		return replaceVersionItem(items, newEdit)
+	})
+	if err != nil {
+		return err
+	}
+	if !versioned {
+		err = s.menuRepo.UpdateMenu(id, newEdit)
+		if err != nil {
+			return err
+		}
+	}
+	if old.Price != newEdit.Price {
+		s.checkMarginAlert(id, old.Price, newEdit.Price)
+	}
+	return nil
+}
+
+// Fills in the stock unit for recipe lines without a unit and checks that the units of the recipe and of every
+// variant override convert to the units the ingredients are stocked in
+func (s *menuService) resolveUnits(item models.MenuItem) (models.MenuItem, error) {
+	book, err := loadUnits(s.unitRepo)
+	if err != nil {
+		return item, err
+	}
+	item.Ingredients, err = book.resolveIngredients(item.Ingredients)
+	if err != nil {
+		return item, err
+	}
+	variants := make([]models.MenuItemVariant, len(item.Variants))
+	for i, variant := range item.Variants {
+		variant.Ingredients, err = book.resolveIngredients(variant.Ingredients)
+		if err != nil {
+			return item, err
+		}
+		variants[i] = variant
+	}
+	if item.Variants != nil {
+		item.Variants = variants
+	}
+	return item, nil
+}
+
+// Records an alert when a price change pushes the margin of a menu item below the threshold
+func (s *menuService) checkMarginAlert(id int, oldPrice, newPrice float64) {
+	cost, err := s.menuRepo.GetMenuItemCost(id)
+	if err != nil {
+		slog.Error("Failed to compute menu item cost", slog.Int("product_id", id), slog.String("ERROR", err.Error()))
+		return
+	}
+	threshold := marginThreshold()
+	margin := marginPercent(newPrice, cost)
+	if margin >= threshold {
+		return
+	}
+	slog.Warn("Price change pushes margin below threshold",
+		slog.Int("product_id", id),
+		slog.Float64("margin_percent", margin),
+		slog.Float64("threshold", threshold),
+	)
+	err = s.menuRepo.PostMarginAlert(models.MarginAlert{
+		ProductID:     id,
+		OldPrice:      oldPrice,
+		NewPrice:      newPrice,
+		Cost:          cost,
+		MarginPercent: margin,
+		Threshold:     threshold,
+	})
+	if err != nil {
+		slog.Error("Failed to record margin alert", slog.Int("product_id", id), slog.String("ERROR", err.Error()))
+	}
+}
+
+// Archives a menu item by ID so it leaves the menu but stays in order history; permanent deletes it when no order references it
+func (s *menuService) ServiceDelete(id int, permanent bool) error {
+	if permanent {
+		return s.menuRepo.DeleteMenuItem(id)
+	}
+	item, err := s.menuRepo.GetMenuItemID(id)
 	if err != nil {
 		return err
 	}
-	return s.menuRepo.UpdateMenu(id, newEdit)
+	versioned, err := s.publishLiveEdit("Archive "+item.Name, func(items []models.MenuItem) ([]models.MenuItem, error) {
+		kept := []models.MenuItem{}
+		for _, existing := range items {
+			if existing.ID != id {
+				kept = append(kept, existing)
+			}
+		}
+		return kept, nil
+	})
+	if versioned || err != nil {
+		return err
+	}
+	return s.menuRepo.ArchiveMenuItem(id, true)
 }
 
-// Deletes a menu item by ID, returning an error if the ID is not found
-func (s *menuService) ServiceDelete(id int) error {
-	return s.menuRepo.DeleteMenuItem(id)
+// Returns an archived menu item to the menu
+func (s *menuService) ServiceRestore(id int) error {
+	item, err := s.menuRepo.GetMenuItemID(id)
+	if err != nil {
+		return err
+	}
+	versioned, err := s.publishLiveEdit("Restore "+item.Name, func(items []models.MenuItem) ([]models.MenuItem, error) {
+		for _, existing := range items {
+			if existing.ID == id {
+				return items, nil
+			}
+		}
+		return append(items, versionItem(item)), nil
+	})
+	if versioned || err != nil {
+		return err
+	}
+	return s.menuRepo.ArchiveMenuItem(id, false)
 }
 
 // Validates the fields of a new menu item to ensure all required fields are filled correctly
@@ -78,6 +441,12 @@ func (s *menuService) CheckMenu(newmenu models.MenuItem) error {
 	if newmenu.Price < 0.0 {
 		return errors.New("Price cannot be negative")
 	}
+	if newmenu.PrepTime != nil && *newmenu.PrepTime < 0 {
+		return errors.New("Prep time cannot be negative")
+	}
+	if newmenu.Station != "bar" && newmenu.Station != "kitchen" {
+		return errors.New("Station must be 'bar' or 'kitchen'")
+	}
 	for _, msq := range newmenu.Ingredients {
 		if msq.Quantity <= 0 {
 			return errors.New("Ingredients quantity cannot be 0 or negative")
@@ -86,6 +455,175 @@ func (s *menuService) CheckMenu(newmenu models.MenuItem) error {
 			return errors.New("Missing ingredients ID")
 		}
 	}
+	if err := checkAvailabilityWindows(newmenu.Availability); err != nil {
+		return err
+	}
+	for _, groupId := range newmenu.ModifierGroups {
+		if groupId <= 0 {
+			return errors.New("Invalid modifier group ID")
+		}
+	}
+	variantNames := make(map[string]bool)
+	for _, variant := range newmenu.Variants {
+		variantName := strings.TrimSpace(variant.Name)
+		if variantName == "" {
+			return errors.New("Missing variant name")
+		}
+		if variantNames[variantName] {
+			return errors.New("Duplicate variant name " + variantName)
+		}
+		variantNames[variantName] = true
+		if variant.Price <= 0 {
+			return errors.New("Variant price must be greater than zero")
+		}
+		if variant.RecipeMultiplier < 0 {
+			return errors.New("Recipe multiplier cannot be negative")
+		}
+		for _, msq := range variant.Ingredients {
+			if msq.Quantity <= 0 {
+				return errors.New("Variant ingredients quantity cannot be 0 or negative")
+			}
+			if msq.IngredientID == 0 {
+				return errors.New("Missing variant ingredients ID")
+			}
+		}
+	}
+	slotNames := make(map[string]bool)
+	for _, slot := range newmenu.BundleSlots {
+		slotName := strings.TrimSpace(slot.Name)
+		if slotName == "" {
+			return errors.New("Missing bundle slot name")
+		}
+		if slotNames[slotName] {
+			return errors.New("Duplicate bundle slot name " + slotName)
+		}
+		slotNames[slotName] = true
+		if slot.Quantity < 0 {
+			return errors.New("Bundle slot quantity cannot be negative")
+		}
+		if len(slot.Options) == 0 {
+			return errors.New("Bundle slot " + slotName + " needs at least one menu item")
+		}
+		for _, productId := range slot.Options {
+			if productId <= 0 {
+				return errors.New("Invalid bundle component ID")
+			}
+		}
+	}
 
 	return nil
 }
+
+// Fills in the default prep time and station for items that do not specify them; an explicit zero prep time is kept
+func setPrepDefaults(item models.MenuItem) models.MenuItem {
+	if item.PrepTime == nil {
+		prepTime := defaultPrepTime
+		item.PrepTime = &prepTime
+	}
+	item.Station = strings.ToLower(strings.TrimSpace(item.Station))
+	if item.Station == "" {
+		item.Station = defaultStation
+	}
+	return item
+}
+
+// Retrieves the price changes of a menu item logged by the price_change_trigger
+func (s *menuService) ServiceGetPriceHistory(id int) ([]models.PriceHistory, error) {
+	if _, err := s.menuRepo.GetMenuItemID(id); err != nil {
+		return nil, err
+	}
+	return s.menuRepo.GetPriceHistory(id)
+}
+
+// Schedules a price change of a menu item effective at a future RFC3339 timestamp
+func (s *menuService) ServicePostScheduledPrice(id int, change models.ScheduledPrice) error {
+	if change.NewPrice <= 0 {
+		return errors.New("Price must be greater than zero")
+	}
+	effectiveAt, err := time.Parse(time.RFC3339, change.EffectiveAt)
+	if err != nil {
+		return errors.New("effective_at must be an RFC3339 timestamp")
+	}
+	if !effectiveAt.After(time.Now()) {
+		return errors.New("effective_at must be in the future")
+	}
+	if _, err := s.menuRepo.GetMenuItemID(id); err != nil {
+		return err
+	}
+	change.ProductID = id
+	change.EffectiveAt = effectiveAt.Format(time.RFC3339)
+	_, err = s.menuRepo.PostScheduledPrice(change)
+	return err
+}
+
+// Retrieves the scheduled price changes of a menu item, applied and pending
+func (s *menuService) ServiceGetScheduledPrices(id int) ([]models.ScheduledPrice, error) {
+	return s.menuRepo.GetScheduledPrices(id)
+}
+
+// Cancels a pending scheduled price change
+func (s *menuService) ServiceDeleteScheduledPrice(id, scheduleId int) error {
+	return s.menuRepo.DeleteScheduledPrice(id, scheduleId)
+}
+
+// Periodically applies due scheduled price changes until the process exits
+func (s *menuService) RunPriceScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.applyDuePriceChanges()
+		<-ticker.C
+	}
+}
+
+// Failed attempts after which a scheduled price change is marked failed instead of being retried on every tick
+const maxPriceChangeAttempts = 5
+
+// Applies every due price change to the price alone, so a menu item that no longer passes the checks of a full update
+// still gets its price. A change whose menu item has left the menu is marked failed at once, other errors are retried
+// up to maxPriceChangeAttempts times
+func (s *menuService) applyDuePriceChanges() {
+	changes, err := s.menuRepo.GetDuePriceChanges()
+	if err != nil {
+		slog.Error("Failed to read scheduled price changes", slog.String("ERROR", err.Error()))
+		return
+	}
+	for _, change := range changes {
+		oldPrice, err := s.applyPriceChange(change)
+		attempts := maxPriceChangeAttempts
+		if err != nil {
+			if errors.Is(err, dal.ErrMenuItemNotOnMenu) {
+				attempts = 1
+			}
+			slog.Error("Failed to apply scheduled price change", slog.Int("schedule_id", change.ScheduleID), slog.String("ERROR", err.Error()))
+		} else {
+			slog.Info("Scheduled price change applied", slog.Int("schedule_id", change.ScheduleID), slog.Int("product_id", change.ProductID))
+			if oldPrice != change.NewPrice {
+				s.checkMarginAlert(change.ProductID, oldPrice, change.NewPrice)
+			}
+		}
+		if err := s.menuRepo.MarkScheduledPrice(change.ScheduleID, err, attempts); err != nil {
+			slog.Error("Failed to mark scheduled price change", slog.Int("schedule_id", change.ScheduleID), slog.String("ERROR", err.Error()))
+		}
+	}
+}
+
+// Sets the price of a scheduled change on the live menu, as a new menu version once versions are in use, and
+// returns the price it replaced
+func (s *menuService) applyPriceChange(change models.ScheduledPrice) (float64, error) {
+	var oldPrice float64
+	versioned, err := s.publishLiveEdit("Scheduled price change", func(items []models.MenuItem) ([]models.MenuItem, error) {
+		for i := range items {
+			if items[i].ID == change.ProductID {
+				oldPrice = items[i].Price
+				items[i].Price = change.NewPrice
+				return items, nil
+			}
+		}
+		return nil, dal.ErrMenuItemNotOnMenu
+	})
+	if versioned || err != nil {
+		return oldPrice, err
+	}
+	return s.menuRepo.UpdateMenuPrice(change.ProductID, change.NewPrice)
+}
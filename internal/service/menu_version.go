@@ -0,0 +1,405 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"frapuccino/internal/dal"
+	"frapuccino/models"
+)
+
+// Retrieves the menu versions without their items, newest first
+func (s *menuService) ServiceGetVersions() ([]models.MenuVersion, error) {
+	return s.versionRepo.GetVersions()
+}
+
+// Retrieves a menu version with its items
+func (s *menuService) ServiceGetVersion(id int) (models.MenuVersion, error) {
+	return s.versionRepo.GetVersion(id)
+}
+
+// Creates a draft copied from another version, or from the live menu when based_on is omitted. The first draft also
+// records the live menu as the initial published version so there is something to roll back to
+func (s *menuService) ServicePostVersion(version models.MenuVersion) (int, error) {
+	version.Name = strings.TrimSpace(version.Name)
+	if version.Name == "" {
+		return 0, errors.New("Missing name")
+	}
+	if version.BasedOn != nil {
+		base, err := s.versionRepo.GetVersion(*version.BasedOn)
+		if err != nil {
+			return 0, err
+		}
+		version.Items = base.Items
+	} else {
+		items, err := s.liveVersionItems()
+		if err != nil {
+			return 0, err
+		}
+		version.Items = items
+		published, err := s.publishedVersion()
+		if err != nil {
+			return 0, err
+		}
+		if published == nil {
+			initialId, err := s.versionRepo.PostVersion(models.MenuVersion{Name: "Initial menu", Status: "published", Items: items})
+			if err != nil {
+				return 0, err
+			}
+			published = &models.MenuVersion{VersionID: initialId}
+		}
+		version.BasedOn = &published.VersionID
+	}
+	version.Status = "draft"
+	return s.versionRepo.PostVersion(version)
+}
+
+// Adds a new menu item to a draft under a temporary negative ID, which is replaced by its product ID on publishing
+func (s *menuService) ServicePostVersionItem(versionId int, item models.MenuItem) (int, error) {
+	version, err := s.draftVersion(versionId)
+	if err != nil {
+		return 0, err
+	}
+	item.ID = -1
+	for _, existing := range version.Items {
+		if existing.ID <= item.ID {
+			item.ID = existing.ID - 1
+		}
+	}
+	item, err = s.checkVersionItem(item)
+	if err != nil {
+		return 0, err
+	}
+	return item.ID, s.versionRepo.UpdateVersionItems(versionId, append(version.Items, item))
+}
+
+// Replaces a menu item of a draft, validating it like a live menu edit
+func (s *menuService) ServicePutVersionItem(versionId, productId int, item models.MenuItem) error {
+	version, err := s.draftVersion(versionId)
+	if err != nil {
+		return err
+	}
+	item.ID = productId
+	item, err = s.checkVersionItem(item)
+	if err != nil {
+		return err
+	}
+	for i := range version.Items {
+		if version.Items[i].ID == productId {
+			version.Items[i] = item
+			return s.versionRepo.UpdateVersionItems(versionId, version.Items)
+		}
+	}
+	return fmt.Errorf("menu item with ID %d is not in menu version %d", productId, versionId)
+}
+
+// Removes a menu item from a draft; publishing the draft archives it
+func (s *menuService) ServiceDeleteVersionItem(versionId, productId int) error {
+	version, err := s.draftVersion(versionId)
+	if err != nil {
+		return err
+	}
+	items := []models.MenuItem{}
+	for _, item := range version.Items {
+		if item.ID != productId {
+			items = append(items, item)
+		}
+	}
+	if len(items) == len(version.Items) {
+		return fmt.Errorf("menu item with ID %d is not in menu version %d", productId, versionId)
+	}
+	return s.versionRepo.UpdateVersionItems(versionId, items)
+}
+
+// Deletes a draft
+func (s *menuService) ServiceDeleteVersion(id int) error {
+	return s.versionRepo.DeleteVersion(id)
+}
+
+// Compares a menu version with the live menu: items it would create, update, archive and bring back from the archive
+func (s *menuService) ServicePreviewVersion(id int) (models.MenuVersionPreview, error) {
+	preview := models.MenuVersionPreview{
+		VersionID: id,
+		Created:   []string{},
+		Updated:   []models.MenuImportDiff{},
+		Archived:  []string{},
+		Restored:  []string{},
+	}
+	version, err := s.versionRepo.GetVersion(id)
+	if err != nil {
+		return preview, err
+	}
+	menu, err := s.menuRepo.GetMenuRepo()
+	if err != nil {
+		return preview, err
+	}
+	sort.Slice(menu, func(i, j int) bool { return menu[i].ID < menu[j].ID })
+	live := make(map[int]models.MenuItem)
+	for _, item := range menu {
+		live[item.ID] = item
+	}
+	inVersion := make(map[int]bool)
+	for _, item := range version.Items {
+		inVersion[item.ID] = true
+		old, ok := live[item.ID]
+		if !ok {
+			preview.Created = append(preview.Created, item.Name)
+			continue
+		}
+		if old.ArchivedAt != nil {
+			preview.Restored = append(preview.Restored, item.Name)
+		}
+		if fields := versionItemChanges(old, item); len(fields) > 0 {
+			preview.Updated = append(preview.Updated, models.MenuImportDiff{ProductID: item.ID, Name: item.Name, Fields: fields})
+		}
+	}
+	for _, item := range menu {
+		if !inVersion[item.ID] && item.ArchivedAt == nil {
+			preview.Archived = append(preview.Archived, item.Name)
+		}
+	}
+	return preview, nil
+}
+
+// Publishes a draft or a retired version now, or schedules a draft for an RFC3339 time in the future
+func (s *menuService) ServicePublishVersion(id int, publishAt string) error {
+	if publishAt == "" {
+		return s.versionRepo.PublishVersion(id)
+	}
+	at, err := time.Parse(time.RFC3339, publishAt)
+	if err != nil {
+		return errors.New("publish_at must be an RFC3339 timestamp")
+	}
+	if !at.After(time.Now()) {
+		return errors.New("publish_at must be in the future")
+	}
+	scheduled := at.Format(time.RFC3339)
+	return s.versionRepo.SchedulePublish(id, &scheduled)
+}
+
+// Publishes again the version that was live before the current one
+func (s *menuService) ServiceRollbackVersion() (models.MenuVersion, error) {
+	versions, err := s.versionRepo.GetVersions()
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+	var previous *models.MenuVersion
+	for i, version := range versions {
+		if version.Status != "retired" || version.PublishedAt == nil {
+			continue
+		}
+		if previous == nil || *version.PublishedAt > *previous.PublishedAt {
+			previous = &versions[i]
+		}
+	}
+	if previous == nil {
+		return models.MenuVersion{}, errors.New("No previous menu version to roll back to")
+	}
+	return *previous, s.versionRepo.PublishVersion(previous.VersionID)
+}
+
+// Periodically publishes drafts whose publish time has come until the process exits
+func (s *menuService) RunVersionScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.publishDueVersions()
+		<-ticker.C
+	}
+}
+
+func (s *menuService) publishDueVersions() {
+	ids, err := s.versionRepo.GetDueVersions()
+	if err != nil {
+		slog.Error("Failed to read scheduled menu versions", slog.String("ERROR", err.Error()))
+		return
+	}
+	for _, id := range ids {
+		if err := s.versionRepo.PublishVersion(id); err != nil {
+			slog.Error("Failed to publish scheduled menu version", slog.Int("version_id", id), slog.String("ERROR", err.Error()))
+			continue
+		}
+		slog.Info("Scheduled menu version published", slog.Int("version_id", id))
+	}
+}
+
+// Once a menu version has been published, an edit of the live menu is published as a new version based on it, so the
+// version an order records always holds the prices and recipes it was sold under. The edit gets the live menu as
+// version items and returns them changed; only the items it changed, added or removed are published. Reports false
+// when no version has been published yet and the edit has to be written to the menu directly
+func (s *menuService) publishLiveEdit(name string, edit func(items []models.MenuItem) ([]models.MenuItem, error)) (bool, error) {
+	published, err := s.publishedVersion()
+	if err != nil || published == nil {
+		return false, err
+	}
+	live, err := s.liveVersionItems()
+	if err != nil {
+		return true, err
+	}
+	items, err := edit(append([]models.MenuItem{}, live...))
+	if err != nil {
+		return true, err
+	}
+	changed, removed := versionItemsDiff(live, items)
+	return true, s.versionRepo.PublishLiveEdit(name, changed, removed)
+}
+
+// Compares edited version items with the ones they were made from, returning the changed or added items and the
+// product IDs of the removed ones
+func versionItemsDiff(old, items []models.MenuItem) ([]models.MenuItem, []int) {
+	before := make(map[int]models.MenuItem)
+	for _, item := range old {
+		before[item.ID] = item
+	}
+	changed := []models.MenuItem{}
+	kept := make(map[int]bool)
+	for _, item := range items {
+		kept[item.ID] = true
+		if previous, ok := before[item.ID]; !ok || !sameJSON(previous, item) {
+			changed = append(changed, item)
+		}
+	}
+	removed := []int{}
+	for _, item := range old {
+		if !kept[item.ID] {
+			removed = append(removed, item.ID)
+		}
+	}
+	return changed, removed
+}
+
+// Replaces the live menu item with the given product ID among version items; archived items are not on the live menu
+func replaceVersionItem(items []models.MenuItem, item models.MenuItem) ([]models.MenuItem, error) {
+	for i := range items {
+		if items[i].ID == item.ID {
+			items[i] = versionItem(item)
+			return items, nil
+		}
+	}
+	return nil, fmt.Errorf("menu item with ID %d is archived, restore it first", item.ID)
+}
+
+// Reads a version and makes sure it can still be edited
+func (s *menuService) draftVersion(id int) (models.MenuVersion, error) {
+	version, err := s.versionRepo.GetVersion(id)
+	if err != nil {
+		return version, err
+	}
+	if version.Status != "draft" {
+		return version, dal.ErrVersionNotDraft
+	}
+	return version, nil
+}
+
+// Validates a draft item like a live menu item and keeps only the fields a version stores
+func (s *menuService) checkVersionItem(item models.MenuItem) (models.MenuItem, error) {
+	item = setPrepDefaults(item)
+	item, err := s.resolveCategory(item)
+	if err != nil {
+		return item, err
+	}
+	err = s.CheckMenu(item)
+	if err != nil {
+		return item, err
+	}
+	item, err = s.resolveUnits(item)
+	if err != nil {
+		return item, err
+	}
+	return versionItem(item), nil
+}
+
+// The live menu as version items, leaving out archived items
+func (s *menuService) liveVersionItems() ([]models.MenuItem, error) {
+	menu, err := s.menuRepo.GetMenuRepo()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(menu, func(i, j int) bool { return menu[i].ID < menu[j].ID })
+	items := []models.MenuItem{}
+	for _, item := range menu {
+		if item.ArchivedAt == nil {
+			items = append(items, versionItem(item))
+		}
+	}
+	return items, nil
+}
+
+func (s *menuService) publishedVersion() (*models.MenuVersion, error) {
+	versions, err := s.versionRepo.GetVersions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].Status == "published" {
+			return &versions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Strips the values derived from other tables, which a version does not store
+func versionItem(item models.MenuItem) models.MenuItem {
+	variants := make([]models.MenuItemVariant, len(item.Variants))
+	for i, variant := range item.Variants {
+		variant.Nutrition = nil
+		variants[i] = variant
+	}
+	return models.MenuItem{
+		ID:             item.ID,
+		Name:           item.Name,
+		Description:    item.Description,
+		Price:          item.Price,
+		CategoryID:     item.CategoryID,
+		Category:       item.Category,
+		PrepTime:       item.PrepTime,
+		Station:        item.Station,
+		Ingredients:    item.Ingredients,
+		Variants:       variants,
+		ModifierGroups: item.ModifierGroups,
+		Availability:   item.Availability,
+		BundleSlots:    item.BundleSlots,
+	}
+}
+
+// Lists the fields that differ between a live menu item and its version, including the parts import leaves alone
+func versionItemChanges(old, item models.MenuItem) []string {
+	fields := menuItemChanges(old, item)
+	if !sameJSON(versionItem(old).Variants, item.Variants) {
+		fields = append(fields, "variants")
+	}
+	if !sameJSON(old.ModifierGroups, item.ModifierGroups) {
+		fields = append(fields, "modifier_group_ids")
+	}
+	if !sameJSON(old.Availability, item.Availability) {
+		fields = append(fields, "availability")
+	}
+	if !sameJSON(old.BundleSlots, item.BundleSlots) {
+		fields = append(fields, "bundle_slots")
+	}
+	return fields
+}
+
+// Compares two values by their JSON form, treating nil and empty lists alike
+func sameJSON(a, b any) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	if string(left) == "null" {
+		left = []byte("[]")
+	}
+	if string(right) == "null" {
+		right = []byte("[]")
+	}
+	return string(left) == string(right)
+}
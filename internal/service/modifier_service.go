@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"frapuccino/internal/dal"
+	"frapuccino/models"
+)
+
+type ModifierService interface {
+	ServicePostModifierGroup(group models.ModifierGroup) error
+	ServiceGetModifierGroups() ([]models.ModifierGroup, error)
+	ServiceGetModifierGroupID(id int) (models.ModifierGroup, error)
+	ServicePutModifierGroupID(id int, group models.ModifierGroup) error
+	ServiceDeleteModifierGroup(id int) error
+}
+
+type modifierService struct {
+	modifierRepo dal.ModifierRepository
+	unitRepo     dal.UnitRepository
+}
+
+// Initializes and returns a new instance of modifierService with the provided repositories
+func NewModifierService(modifierRepo dal.ModifierRepository, unitRepo dal.UnitRepository) ModifierService {
+	return &modifierService{modifierRepo: modifierRepo, unitRepo: unitRepo}
+}
+
+// Adds a new modifier group with its options after validation
+func (s *modifierService) ServicePostModifierGroup(group models.ModifierGroup) error {
+	if err := s.CheckModifierGroup(group); err != nil {
+		return err
+	}
+	group, err := s.resolveUnits(group)
+	if err != nil {
+		return err
+	}
+	return s.modifierRepo.PostModifierGroup(group)
+}
+
+// Retrieves all modifier groups with their options
+func (s *modifierService) ServiceGetModifierGroups() ([]models.ModifierGroup, error) {
+	return s.modifierRepo.GetModifierGroups()
+}
+
+// Retrieves a specific modifier group by ID
+func (s *modifierService) ServiceGetModifierGroupID(id int) (models.ModifierGroup, error) {
+	return s.modifierRepo.GetModifierGroupID(id)
+}
+
+// Updates a modifier group by ID, replacing its options
+func (s *modifierService) ServicePutModifierGroupID(id int, group models.ModifierGroup) error {
+	if err := s.CheckModifierGroup(group); err != nil {
+		return err
+	}
+	group, err := s.resolveUnits(group)
+	if err != nil {
+		return err
+	}
+	return s.modifierRepo.UpdateModifierGroup(id, group)
+}
+
+// Deletes a modifier group by ID
+func (s *modifierService) ServiceDeleteModifierGroup(id int) error {
+	return s.modifierRepo.DeleteModifierGroup(id)
+}
+
+// Validates the selection rules and options of a modifier group
+func (s *modifierService) CheckModifierGroup(group models.ModifierGroup) error {
+	if strings.TrimSpace(group.Name) == "" {
+		return errors.New("Missing name")
+	}
+	if group.MinSelect < 0 {
+		return errors.New("Minimum selections cannot be negative")
+	}
+	if group.MaxSelect < group.MinSelect {
+		return errors.New("Maximum selections cannot be less than minimum selections")
+	}
+	if len(group.Options) < group.MinSelect {
+		return errors.New("Not enough options to satisfy minimum selections")
+	}
+	optionNames := make(map[string]bool)
+	for _, option := range group.Options {
+		optionName := strings.TrimSpace(option.Name)
+		if optionName == "" {
+			return errors.New("Missing option name")
+		}
+		if optionNames[optionName] {
+			return errors.New("Duplicate option name " + optionName)
+		}
+		optionNames[optionName] = true
+		for _, ingredient := range option.Ingredients {
+			if ingredient.IngredientID == 0 {
+				return errors.New("Missing ingredients ID")
+			}
+			if ingredient.Quantity == 0 {
+				return errors.New("Option ingredients quantity cannot be 0")
+			}
+		}
+	}
+	return nil
+}
+
+// Fills in the stock unit for option ingredients without a unit and checks that every unit converts to it
+func (s *modifierService) resolveUnits(group models.ModifierGroup) (models.ModifierGroup, error) {
+	book, err := loadUnits(s.unitRepo)
+	if err != nil {
+		return group, err
+	}
+	options := make([]models.ModifierOption, len(group.Options))
+	for i, option := range group.Options {
+		option.Ingredients, err = book.resolveIngredients(option.Ingredients)
+		if err != nil {
+			return group, err
+		}
+		options[i] = option
+	}
+	group.Options = options
+	return group, nil
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"errors"
+
+	"frapuccino/models"
+)
+
+// Ingredient nutrition is stored per unit and cannot be negative; only modifier swaps produce negative deltas
+func checkNutrition(nutrition models.Nutrition) error {
+	if nutrition.Kcal < 0 || nutrition.Sugar < 0 || nutrition.Fat < 0 || nutrition.Caffeine < 0 {
+		return errors.New("Nutrition values cannot be negative")
+	}
+	return nil
+}
@@ -2,20 +2,27 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
 	"frapuccino/internal/dal/orderRepo"
 	"frapuccino/models"
 )
 
 type OrderService interface {
-	ServicePostOrders(body models.Order) error
+	ServicePostOrders(body models.Order) (models.OrderETA, error)
 	ServicePutOrderID(id int, newEdit models.Order) error
 	CloseOrder(id int) error
 	ServiceDeleteOrdersID(id int) error
 	GetOrdersService() ([]models.Order, error)
 	GetIDOrdersService(id int) (models.Order, error)
 	CheckBodyOrder(body models.Order) error
+	GetOrderETAService(id int) (models.OrderETA, error)
+	GetStationsService() ([]models.Station, error)
+	PutStationService(station string, body models.Station) error
+	RunReservationScheduler(interval time.Duration)
 }
 
 type orderService struct {
@@ -30,15 +37,22 @@ func NewOrderService(orderRepo orderRepo.OrderRepository) OrderService {
 }
 
 // Creates a new order, validates the order details, and ensures no open orders exist
-func (s orderService) ServicePostOrders(body models.Order) error {
+func (s orderService) ServicePostOrders(body models.Order) (models.OrderETA, error) {
 	if err := s.CheckBodyOrder(body); err != nil {
-		return err
+		return models.OrderETA{}, err
 	}
 	body.Status = "open"
-	if err := s.orderRepo.WriteDBNewOrder(body); err != nil {
-		return err
+	id, err := s.orderRepo.WriteDBNewOrder(body, reservationHold())
+	if err != nil {
+		return models.OrderETA{}, err
 	}
-	return nil
+	// The order is already saved, so failing to read its ETA must not report it as rejected
+	eta, err := s.orderRepo.GetOrderETA(id)
+	if err != nil {
+		slog.Error("Failed to read order ETA", slog.Int("order_id", id), slog.String("ERROR", err.Error()))
+		return models.OrderETA{OrderID: id, Status: "open", AllergenWarnings: []string{}}, nil
+	}
+	return eta, nil
 }
 
 // Validates the fields of an order to ensure all required information is present
@@ -50,6 +64,9 @@ func (s orderService) CheckBodyOrder(body models.Order) error {
 	if body.Items == nil {
 		return errors.New("Missing items in menu")
 	}
+	rules := make(map[int][]models.ModifierGroup)
+	slots := make(map[int][]models.BundleSlot)
+	variants := make(map[int][]int)
 	for _, item := range body.Items {
 		if item.ProductID == 0 {
 			return errors.New("Missing product id")
@@ -57,7 +74,124 @@ func (s orderService) CheckBodyOrder(body models.Order) error {
 		if item.Quantity < 1 {
 			return errors.New("Quantity cannot be negative")
 		}
+		if _, ok := variants[item.ProductID]; !ok {
+			variantIds, err := s.orderRepo.GetVariantIDs(item.ProductID)
+			if err != nil {
+				return err
+			}
+			variants[item.ProductID] = variantIds
+		}
+		if err := checkVariant(item, variants[item.ProductID]); err != nil {
+			return err
+		}
+		if _, ok := rules[item.ProductID]; !ok {
+			groups, err := s.orderRepo.GetModifierRules(item.ProductID)
+			if err != nil {
+				return err
+			}
+			rules[item.ProductID] = groups
+		}
+		if err := checkModifiers(item, rules[item.ProductID]); err != nil {
+			return err
+		}
+		if _, ok := slots[item.ProductID]; !ok {
+			bundleSlots, err := s.orderRepo.GetBundleSlots(item.ProductID)
+			if err != nil {
+				return err
+			}
+			slots[item.ProductID] = bundleSlots
+		}
+		if err := checkComponents(item, slots[item.ProductID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verifies that a line of a menu item with variants picks one of them, and that a picked variant is the item's own
+func checkVariant(item models.OrderItem, variantIds []int) error {
+	if item.VariantID == nil {
+		if len(variantIds) > 0 {
+			return fmt.Errorf("Menu item %d requires a variant", item.ProductID)
+		}
+		return nil
+	}
+	for _, id := range variantIds {
+		if id == *item.VariantID {
+			return nil
+		}
+	}
+	return fmt.Errorf("Variant %d is not offered for menu item %d", *item.VariantID, item.ProductID)
+}
 
+// Verifies that every component is one of its slot's options and that each slot offering a choice has one selected
+func checkComponents(item models.OrderItem, slots []models.BundleSlot) error {
+	if len(slots) == 0 && len(item.Components) > 0 {
+		return fmt.Errorf("Menu item %d is not a bundle", item.ProductID)
+	}
+	chosen := make(map[int]int)
+	for _, component := range item.Components {
+		if _, ok := chosen[component.SlotID]; ok {
+			return fmt.Errorf("Bundle slot %d chosen more than once for menu item %d", component.SlotID, item.ProductID)
+		}
+		chosen[component.SlotID] = component.ProductID
+	}
+	for _, slot := range slots {
+		if len(slot.Options) == 0 {
+			return fmt.Errorf("%s has no menu items left for menu item %d", slot.Name, item.ProductID)
+		}
+		productId, ok := chosen[slot.SlotID]
+		if !ok {
+			if len(slot.Options) > 1 {
+				return fmt.Errorf("%s requires a choice for menu item %d", slot.Name, item.ProductID)
+			}
+			continue
+		}
+		delete(chosen, slot.SlotID)
+		valid := false
+		for _, option := range slot.Options {
+			if option == productId {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("Menu item %d is not an option of %s", productId, slot.Name)
+		}
+	}
+	for slotId := range chosen {
+		return fmt.Errorf("Bundle slot %d does not belong to menu item %d", slotId, item.ProductID)
+	}
+	return nil
+}
+
+// Verifies that the selected modifier options belong to the item's groups and respect each group's min/max selections
+func checkModifiers(item models.OrderItem, groups []models.ModifierGroup) error {
+	optionGroup := make(map[int]int)
+	for i, group := range groups {
+		for _, option := range group.Options {
+			optionGroup[option.OptionID] = i
+		}
+	}
+	selected := make([]int, len(groups))
+	seen := make(map[int]bool)
+	for _, optionID := range item.Modifiers {
+		if seen[optionID] {
+			return fmt.Errorf("Modifier option %d selected more than once for menu item %d", optionID, item.ProductID)
+		}
+		seen[optionID] = true
+		i, ok := optionGroup[optionID]
+		if !ok {
+			return fmt.Errorf("Modifier option %d is not available for menu item %d", optionID, item.ProductID)
+		}
+		selected[i]++
+	}
+	for i, group := range groups {
+		if selected[i] < group.MinSelect {
+			return fmt.Errorf("%s requires at least %d selection(s) for menu item %d", group.Name, group.MinSelect, item.ProductID)
+		}
+		if selected[i] > group.MaxSelect {
+			return fmt.Errorf("%s allows at most %d selection(s) for menu item %d", group.Name, group.MaxSelect, item.ProductID)
+		}
 	}
 	return nil
 }
@@ -67,13 +201,13 @@ func (s *orderService) ServicePutOrderID(id int, body models.Order) error {
 	if err := s.CheckBodyOrder(body); err != nil {
 		return err
 	}
-	if err := s.orderRepo.UpdateOrder(id, body); err != nil {
+	if err := s.orderRepo.UpdateOrder(id, body, reservationHold()); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Closes an open order by ID, updates inventory quantities, and writes changes
+// Closes an open order by ID, consuming the ingredients it reserved
 func (s *orderService) CloseOrder(id int) error {
 	err := s.orderRepo.OrderClose(id)
 	if err != nil {
@@ -99,3 +233,22 @@ func (s *orderService) GetOrdersService() ([]models.Order, error) {
 func (s *orderService) GetIDOrdersService(id int) (models.Order, error) {
 	return s.orderRepo.GetRepoId(id)
 }
+
+// Retrieves the estimated ready time of an order
+func (s *orderService) GetOrderETAService(id int) (models.OrderETA, error) {
+	return s.orderRepo.GetOrderETA(id)
+}
+
+// Retrieves all preparation stations with their active staff
+func (s *orderService) GetStationsService() ([]models.Station, error) {
+	return s.orderRepo.GetStations()
+}
+
+// Updates the number of active staff at a station, which shifts the ETA of open orders
+func (s *orderService) PutStationService(station string, body models.Station) error {
+	if body.ActiveStaff < 0 {
+		return errors.New("Active staff cannot be negative")
+	}
+	body.Station = strings.ToLower(strings.TrimSpace(station))
+	return s.orderRepo.UpdateStation(body)
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"frapuccino/internal/dal"
+	"frapuccino/models"
+)
+
+type PurchaseOrderService interface {
+	ServicePostPurchaseOrder(order models.PurchaseOrder) (int, error)
+	ServiceGetPurchaseOrders(status string) ([]models.PurchaseOrder, error)
+	ServiceGetPurchaseOrderID(id int) (models.PurchaseOrder, error)
+	ServicePutPurchaseOrderID(id int, order models.PurchaseOrder) error
+	ServiceDeletePurchaseOrder(id int) error
+	ServiceSendPurchaseOrder(id int) error
+	ServiceReceivePurchaseOrder(id int, receipt models.PurchaseOrderReceipt) (models.PurchaseOrder, error)
+	ServiceGetReorderSuggestions() ([]models.ReorderSuggestion, error)
+	ServicePostReorderSuggestions() ([]int, error)
+}
+
+type purchaseOrderService struct {
+	purchaseOrderRepo dal.PurchaseOrderRepository
+	supplierRepo      dal.SupplierRepository
+}
+
+// Initializes and returns a new instance of purchaseOrderService with the provided repositories
+func NewPurchaseOrderService(purchaseOrderRepo dal.PurchaseOrderRepository, supplierRepo dal.SupplierRepository) PurchaseOrderService {
+	return &purchaseOrderService{purchaseOrderRepo: purchaseOrderRepo, supplierRepo: supplierRepo}
+}
+
+// Creates a draft purchase order after validation
+func (s *purchaseOrderService) ServicePostPurchaseOrder(order models.PurchaseOrder) (int, error) {
+	if err := s.checkPurchaseOrder(order); err != nil {
+		return 0, err
+	}
+	return s.purchaseOrderRepo.PostPurchaseOrder(order)
+}
+
+// Retrieves purchase orders, optionally only those in one status
+func (s *purchaseOrderService) ServiceGetPurchaseOrders(status string) ([]models.PurchaseOrder, error) {
+	if status != "" && !validPurchaseOrderStatus(status) {
+		return nil, errors.New("Status must be draft, sent, partially_received or received")
+	}
+	return s.purchaseOrderRepo.GetPurchaseOrders(status)
+}
+
+// Retrieves a specific purchase order by ID with its lines
+func (s *purchaseOrderService) ServiceGetPurchaseOrderID(id int) (models.PurchaseOrder, error) {
+	return s.purchaseOrderRepo.GetPurchaseOrderID(id)
+}
+
+// Replaces a draft purchase order after validation
+func (s *purchaseOrderService) ServicePutPurchaseOrderID(id int, order models.PurchaseOrder) error {
+	if err := s.checkPurchaseOrder(order); err != nil {
+		return err
+	}
+	return s.purchaseOrderRepo.UpdatePurchaseOrder(id, order)
+}
+
+// Deletes a draft purchase order
+func (s *purchaseOrderService) ServiceDeletePurchaseOrder(id int) error {
+	return s.purchaseOrderRepo.DeletePurchaseOrder(id)
+}
+
+// Marks a draft purchase order as sent to the supplier
+func (s *purchaseOrderService) ServiceSendPurchaseOrder(id int) error {
+	return s.purchaseOrderRepo.SendPurchaseOrder(id)
+}
+
+// Books goods received against a sent purchase order and returns the updated order
+func (s *purchaseOrderService) ServiceReceivePurchaseOrder(id int, receipt models.PurchaseOrderReceipt) (models.PurchaseOrder, error) {
+	if len(receipt.Lines) == 0 {
+		return models.PurchaseOrder{}, errors.New("Missing received lines")
+	}
+	seen := make(map[int]bool)
+	for _, line := range receipt.Lines {
+		if line.IngredientID <= 0 {
+			return models.PurchaseOrder{}, errors.New("Missing ingredient ID")
+		}
+		if seen[line.IngredientID] {
+			return models.PurchaseOrder{}, errors.New("Ingredient is listed twice")
+		}
+		seen[line.IngredientID] = true
+		if line.Quantity <= 0 {
+			return models.PurchaseOrder{}, errors.New("Received quantity must be positive")
+		}
+		if err := checkLotExpiry(line.ExpiresAt); err != nil {
+			return models.PurchaseOrder{}, err
+		}
+	}
+	if err := s.purchaseOrderRepo.ReceivePurchaseOrder(id, receipt); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return s.purchaseOrderRepo.GetPurchaseOrderID(id)
+}
+
+// Groups the ingredients short of their par level by the cheapest supplier that delivers them
+func (s *purchaseOrderService) ServiceGetReorderSuggestions() ([]models.ReorderSuggestion, error) {
+	lines, err := s.purchaseOrderRepo.GetReorderSuggestions()
+	if err != nil {
+		return nil, err
+	}
+	suggestions := []models.ReorderSuggestion{}
+	// Ingredients no supplier delivers share the group keyed 0
+	index := make(map[int]int)
+	for _, line := range lines {
+		key := 0
+		if line.SupplierID != nil {
+			key = *line.SupplierID
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(suggestions)
+			index[key] = i
+			suggestions = append(suggestions, models.ReorderSuggestion{
+				SupplierID:   line.SupplierID,
+				SupplierName: line.SupplierName,
+				LeadTimeDays: line.LeadTimeDays,
+				Lines:        []models.ReorderSuggestionLine{},
+			})
+		}
+		suggestions[i].Lines = append(suggestions[i].Lines, line)
+	}
+	return suggestions, nil
+}
+
+// Turns the reorder suggestions into one draft purchase order per supplier and returns their IDs;
+// ingredients no supplier delivers are left out. The drafts are created together or not at all
+func (s *purchaseOrderService) ServicePostReorderSuggestions() ([]int, error) {
+	suggestions, err := s.ServiceGetReorderSuggestions()
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.PurchaseOrder{}
+	for _, suggestion := range suggestions {
+		if suggestion.SupplierID == nil {
+			continue
+		}
+		order := models.PurchaseOrder{SupplierID: *suggestion.SupplierID}
+		for _, line := range suggestion.Lines {
+			order.Lines = append(order.Lines, models.PurchaseOrderLine{
+				IngredientID: line.IngredientID,
+				Quantity:     line.SuggestedQuantity,
+			})
+		}
+		orders = append(orders, order)
+	}
+	return s.purchaseOrderRepo.PostPurchaseOrders(orders)
+}
+
+// Validates the supplier, expected date and lines of a purchase order
+func (s *purchaseOrderService) checkPurchaseOrder(order models.PurchaseOrder) error {
+	if order.SupplierID <= 0 {
+		return errors.New("Missing supplier ID")
+	}
+	if _, err := s.supplierRepo.GetSupplierID(order.SupplierID); err != nil {
+		return err
+	}
+	if order.ExpectedAt != nil {
+		if _, err := time.Parse(time.DateOnly, *order.ExpectedAt); err != nil {
+			return errors.New("Expected date must be in YYYY-MM-DD format")
+		}
+	}
+	if len(order.Lines) == 0 {
+		return errors.New("Missing purchase order lines")
+	}
+	seen := make(map[int]bool)
+	for _, line := range order.Lines {
+		if line.IngredientID <= 0 {
+			return errors.New("Missing ingredient ID")
+		}
+		if seen[line.IngredientID] {
+			return errors.New("Ingredient is listed twice")
+		}
+		seen[line.IngredientID] = true
+		if line.Quantity <= 0 {
+			return errors.New("Quantity must be positive")
+		}
+		if line.UnitCost < 0 {
+			return errors.New("Unit cost cannot be negative")
+		}
+	}
+	return nil
+}
+
+func validPurchaseOrderStatus(status string) bool {
+	switch status {
+	case "draft", "sent", "partially_received", "received":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// How long an open order holds its ingredients, set with ORDER_RESERVATION_TTL (e.g. 2h). Unset or zero keeps
+// deducting ingredients as soon as an order opens.
+func reservationHold() time.Duration {
+	hold, err := time.ParseDuration(os.Getenv("ORDER_RESERVATION_TTL"))
+	if err != nil || hold < 0 {
+		return 0
+	}
+	return hold
+}
+
+// RunReservationScheduler periodically rejects open orders whose reservation has run out until the process exits.
+func (s *orderService) RunReservationScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.expireReservations()
+		<-ticker.C
+	}
+}
+
+func (s *orderService) expireReservations() {
+	ids, err := s.orderRepo.ExpireReservations()
+	if err != nil {
+		slog.Error("Failed to release expired reservations", slog.String("ERROR", err.Error()))
+		return
+	}
+	for _, id := range ids {
+		slog.Info("Order reservation expired", slog.Int("order_id", id))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"frapuccino/internal/dal/orderRepo"
 	database "frapuccino/internal/dal/search_filter"
 	"frapuccino/models"
 )
@@ -20,12 +21,15 @@ type SearchFilterService interface {
 	NumberOfOrderedItemsService(startDate, endDate string) (map[string]int, error)
 	ReportsSearchService(req, filter, minPrice, maxPrice string) (models.SearchReports, error)
 	OrderedItemsByPeriodService(Period, month, year string) (models.PeriodResult, error)
-	GetLeftOversService(sortby, page, pageSize string) (models.LeftOvers, error)
+	GetLeftOversService(sortby, page, pageSize string, belowThreshold bool) (models.LeftOvers, error)
 	BulkOrderProcessingService(orders []models.Order) (*models.Common, error)
 }
 
-func NewSearchFilterHandler(searchFilterservice database.SearchFilterRepo) SearchFilterService {
-	return &searchFilterService{searchFilterService: searchFilterservice}
+func NewSearchFilterHandler(searchFilterservice database.SearchFilterRepo, orderRepo orderRepo.OrderRepository) SearchFilterService {
+	return &searchFilterService{
+		orderService:        orderService{orderRepo: orderRepo},
+		searchFilterService: searchFilterservice,
+	}
 }
 
 func (s searchFilterService) NumberOfOrderedItemsService(startDate, endDate string) (map[string]int, error) {
@@ -108,7 +112,7 @@ func (s searchFilterService) OrderedItemsByPeriodService(period, month, year str
 	return result, nil
 }
 
-func (s searchFilterService) GetLeftOversService(sortby, page, pageSize string) (models.LeftOvers, error) {
+func (s searchFilterService) GetLeftOversService(sortby, page, pageSize string, belowThreshold bool) (models.LeftOvers, error) {
 	pageInt, err := strconv.Atoi(page)
 	if err != nil || pageInt <= 0 {
 		pageInt = 1
@@ -121,7 +125,7 @@ func (s searchFilterService) GetLeftOversService(sortby, page, pageSize string)
 	if sortby != "quantity" && sortby != "price" {
 		sortby = "quantity"
 	}
-	res, err := s.searchFilterService.GetLeftOvers(sortby, pageInt, pageSizeInt)
+	res, err := s.searchFilterService.GetLeftOvers(sortby, pageInt, pageSizeInt, belowThreshold)
 	if err != nil {
 		return res, err
 	}
@@ -135,5 +139,5 @@ func (s searchFilterService) BulkOrderProcessingService(orders []models.Order) (
 			return nil, err
 		}
 	}
-	return s.searchFilterService.WriteDBNewOrders(orders)
+	return s.searchFilterService.WriteDBNewOrders(orders, reservationHold())
 }
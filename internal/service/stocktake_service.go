@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"frapuccino/internal/dal"
+	"frapuccino/models"
+)
+
+type StocktakeService interface {
+	ServicePostStocktake(stocktake models.Stocktake) (int, error)
+	ServiceGetStocktakes() ([]models.Stocktake, error)
+	ServiceGetStocktakeID(id int) (models.Stocktake, error)
+	ServiceSubmitCounts(id int, submission models.StocktakeSubmission) error
+	ServiceGetVariance(id int) (models.StocktakeVariance, error)
+	ServiceFinalizeStocktake(id int) (models.StocktakeVariance, error)
+	ServiceDeleteStocktake(id int) error
+}
+
+type stocktakeService struct {
+	stocktakeRepo dal.StocktakeRepository
+}
+
+// Initializes and returns a new instance of stocktakeService with the provided repository
+func NewStocktakeService(stocktakeRepo dal.StocktakeRepository) StocktakeService {
+	return &stocktakeService{stocktakeRepo: stocktakeRepo}
+}
+
+// Starts a stocktake with an optional note
+func (s *stocktakeService) ServicePostStocktake(stocktake models.Stocktake) (int, error) {
+	return s.stocktakeRepo.PostStocktake(strings.TrimSpace(stocktake.Note))
+}
+
+// Retrieves all stocktakes, newest first
+func (s *stocktakeService) ServiceGetStocktakes() ([]models.Stocktake, error) {
+	return s.stocktakeRepo.GetStocktakes()
+}
+
+// Retrieves a stocktake with its counts
+func (s *stocktakeService) ServiceGetStocktakeID(id int) (models.Stocktake, error) {
+	return s.stocktakeRepo.GetStocktakeID(id)
+}
+
+// Records one counter's counts in an open stocktake
+func (s *stocktakeService) ServiceSubmitCounts(id int, submission models.StocktakeSubmission) error {
+	submission.Counter = strings.TrimSpace(submission.Counter)
+	if submission.Counter == "" {
+		return errors.New("Missing counter")
+	}
+	if len(submission.Counts) == 0 {
+		return errors.New("Missing counts")
+	}
+	seen := make(map[int]bool)
+	for _, count := range submission.Counts {
+		if count.IngredientID <= 0 {
+			return errors.New("Missing ingredient ID")
+		}
+		if seen[count.IngredientID] {
+			return errors.New("Ingredient is counted twice")
+		}
+		seen[count.IngredientID] = true
+		if math.IsNaN(count.CountedQuantity) || math.IsInf(count.CountedQuantity, 0) || count.CountedQuantity < 0 {
+			return errors.New("Counted quantity must be a non-negative number")
+		}
+	}
+	return s.stocktakeRepo.SubmitCounts(id, submission)
+}
+
+// Reports theoretical against counted stock of a stocktake in units and money
+func (s *stocktakeService) ServiceGetVariance(id int) (models.StocktakeVariance, error) {
+	stocktake, err := s.stocktakeRepo.GetStocktakeID(id)
+	if err != nil {
+		return models.StocktakeVariance{}, err
+	}
+	lines, err := s.stocktakeRepo.GetVarianceLines(id)
+	if err != nil {
+		return models.StocktakeVariance{}, err
+	}
+	variance := models.StocktakeVariance{StocktakeID: id, Status: stocktake.Status, Lines: lines}
+	for i := range variance.Lines {
+		line := &variance.Lines[i]
+		line.Variance = line.CountedQuantity - line.TheoreticalQuantity
+		line.VarianceValue = line.Variance * line.UnitCost
+		if line.VarianceValue < 0 {
+			variance.ShrinkageValue -= line.VarianceValue
+		} else {
+			variance.SurplusValue += line.VarianceValue
+		}
+		variance.VarianceValue += line.VarianceValue
+	}
+	return variance, nil
+}
+
+// Replaces the stock of the counted ingredients with their counts and returns the final variance
+func (s *stocktakeService) ServiceFinalizeStocktake(id int) (models.StocktakeVariance, error) {
+	if err := s.stocktakeRepo.FinalizeStocktake(id); err != nil {
+		return models.StocktakeVariance{}, err
+	}
+	return s.ServiceGetVariance(id)
+}
+
+// Cancels an open stocktake without changing the stock
+func (s *stocktakeService) ServiceDeleteStocktake(id int) error {
+	return s.stocktakeRepo.DeleteStocktake(id)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"frapuccino/internal/dal"
+	"frapuccino/models"
+)
+
+type SupplierService interface {
+	ServicePostSupplier(supplier models.Supplier) (int, error)
+	ServiceGetSuppliers() ([]models.Supplier, error)
+	ServiceGetSupplierID(id int) (models.Supplier, error)
+	ServicePutSupplierID(id int, supplier models.Supplier) error
+	ServiceDeleteSupplier(id int) error
+}
+
+type supplierService struct {
+	supplierRepo dal.SupplierRepository
+}
+
+// Initializes and returns a new instance of supplierService with the provided repository
+func NewSupplierService(supplierRepo dal.SupplierRepository) SupplierService {
+	return &supplierService{supplierRepo: supplierRepo}
+}
+
+// Adds a new supplier after validation
+func (s *supplierService) ServicePostSupplier(supplier models.Supplier) (int, error) {
+	supplier, err := checkSupplier(supplier)
+	if err != nil {
+		return 0, err
+	}
+	return s.supplierRepo.PostSupplier(supplier)
+}
+
+// Retrieves all suppliers with the ingredients they deliver
+func (s *supplierService) ServiceGetSuppliers() ([]models.Supplier, error) {
+	return s.supplierRepo.GetSuppliers()
+}
+
+// Retrieves a specific supplier by ID
+func (s *supplierService) ServiceGetSupplierID(id int) (models.Supplier, error) {
+	return s.supplierRepo.GetSupplierID(id)
+}
+
+// Updates a supplier by ID, replacing its ingredient list
+func (s *supplierService) ServicePutSupplierID(id int, supplier models.Supplier) error {
+	supplier, err := checkSupplier(supplier)
+	if err != nil {
+		return err
+	}
+	return s.supplierRepo.UpdateSupplier(id, supplier)
+}
+
+// Deletes a supplier no purchase order refers to
+func (s *supplierService) ServiceDeleteSupplier(id int) error {
+	return s.supplierRepo.DeleteSupplier(id)
+}
+
+// Validates a supplier and trims its contact details
+func checkSupplier(supplier models.Supplier) (models.Supplier, error) {
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	supplier.ContactName = strings.TrimSpace(supplier.ContactName)
+	supplier.Email = strings.TrimSpace(supplier.Email)
+	supplier.Phone = strings.TrimSpace(supplier.Phone)
+	if supplier.Name == "" {
+		return supplier, errors.New("Missing name")
+	}
+	if supplier.Email != "" && !strings.Contains(supplier.Email, "@") {
+		return supplier, errors.New("Invalid email")
+	}
+	if supplier.LeadTimeDays < 0 {
+		return supplier, errors.New("Lead time cannot be negative")
+	}
+	seen := make(map[int]bool)
+	for _, ingredient := range supplier.Ingredients {
+		if ingredient.IngredientID <= 0 {
+			return supplier, errors.New("Missing ingredient ID")
+		}
+		if seen[ingredient.IngredientID] {
+			return supplier, errors.New("Ingredient is listed twice")
+		}
+		seen[ingredient.IngredientID] = true
+		if ingredient.UnitCost < 0 {
+			return supplier, errors.New("Unit cost cannot be negative")
+		}
+	}
+	return supplier, nil
+}
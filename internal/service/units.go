@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+
+	"frapuccino/internal/dal"
+	"frapuccino/models"
+)
+
+// The units registry together with the unit each ingredient is stocked in
+type unitBook struct {
+	units map[string]models.Unit
+	stock map[int]string
+}
+
+func loadUnits(unitRepo dal.UnitRepository) (unitBook, error) {
+	book := unitBook{units: make(map[string]models.Unit)}
+	units, err := unitRepo.GetUnits()
+	if err != nil {
+		return book, err
+	}
+	for _, unit := range units {
+		book.units[unit.Unit] = unit
+	}
+	book.stock, err = unitRepo.GetStockUnits()
+	return book, err
+}
+
+func (b unitBook) checkUnit(unit string) error {
+	if _, ok := b.units[unit]; !ok {
+		return fmt.Errorf("Unknown unit %s", unit)
+	}
+	return nil
+}
+
+// Units convert into each other when both are registered with the same dimension
+func (b unitBook) compatible(from, to string) bool {
+	f, ok := b.units[from]
+	if !ok {
+		return false
+	}
+	t, ok := b.units[to]
+	return ok && f.Dimension == t.Dimension
+}
+
+// Gives recipe lines without a unit the stock unit of their ingredient and checks that every unit converts to it
+func (b unitBook) resolveIngredients(ingredients []models.MenuItemIngredient) ([]models.MenuItemIngredient, error) {
+	if ingredients == nil {
+		return nil, nil
+	}
+	resolved := make([]models.MenuItemIngredient, len(ingredients))
+	for i, ingredient := range ingredients {
+		stockUnit, ok := b.stock[ingredient.IngredientID]
+		if !ok {
+			return nil, fmt.Errorf("Unknown ingredient ID %d", ingredient.IngredientID)
+		}
+		if ingredient.Unit == "" {
+			ingredient.Unit = stockUnit
+		}
+		if err := b.checkUnit(ingredient.Unit); err != nil {
+			return nil, err
+		}
+		if !b.compatible(ingredient.Unit, stockUnit) {
+			return nil, fmt.Errorf("Ingredient ID %d is stocked in %s, which %s cannot be converted to", ingredient.IngredientID, stockUnit, ingredient.Unit)
+		}
+		resolved[i] = ingredient
+	}
+	return resolved, nil
+}
@@ -0,0 +1,53 @@
+-- Добавляет время приготовления и станцию позициям меню, станции с числом сотрудников и ожидаемое время
+-- готовности заказов. Для баз, созданных до этого; выполняется перед 001_categories.sql.
+BEGIN;
+
+CREATE TYPE station_type AS ENUM ('bar', 'kitchen');
+
+ALTER TABLE menu_items ADD COLUMN IF NOT EXISTS prep_time INT NOT NULL DEFAULT 60;
+ALTER TABLE menu_items ADD COLUMN IF NOT EXISTS station station_type NOT NULL DEFAULT 'bar';
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS estimated_ready_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS stations (
+    station station_type PRIMARY KEY,
+    active_staff INT NOT NULL DEFAULT 1 CHECK (active_staff >= 0)
+);
+
+INSERT INTO stations (station, active_staff) VALUES ('bar', 1), ('kitchen', 1)
+ON CONFLICT (station) DO NOTHING;
+
+--Пересчёт ожидаемого времени готовности открытых заказов по очереди на каждой станции (NULL - все открытые заказы).
+CREATE OR REPLACE FUNCTION refresh_order_eta(p_order_id INT)
+RETURNS VOID AS $$
+BEGIN
+    WITH station_work AS (
+        SELECT o.order_id, o.created_at, mi.station, SUM(mi.prep_time * oi.quantity) AS work
+        FROM orders o
+        JOIN order_items oi ON o.order_id = oi.order_id
+        JOIN menu_items mi ON oi.product_id = mi.product_id
+        WHERE o.status = 'open'
+        GROUP BY o.order_id, o.created_at, mi.station
+    ),
+    queued AS (
+        SELECT order_id, station,
+            SUM(work) OVER (PARTITION BY station ORDER BY created_at, order_id) AS cumulative
+        FROM station_work
+    ),
+    eta AS (
+        SELECT q.order_id, MAX(q.cumulative::FLOAT / GREATEST(s.active_staff, 1)) AS seconds
+        FROM queued q
+        JOIN stations s ON q.station = s.station
+        GROUP BY q.order_id
+    )
+    UPDATE orders
+    SET estimated_ready_at = CURRENT_TIMESTAMP + make_interval(secs => eta.seconds)
+    FROM eta
+    WHERE orders.order_id = eta.order_id
+        AND (p_order_id IS NULL OR orders.order_id = p_order_id);
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT refresh_order_eta(NULL);
+
+COMMIT;
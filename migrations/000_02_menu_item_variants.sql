@@ -0,0 +1,80 @@
+-- Добавляет варианты позиций меню со своей ценой и рецептом (коэффициент к базовому рецепту или свой рецепт)
+-- и вариант в строках заказа. Для баз, созданных до этого; выполняется после 000_01_prep_times_and_stations.sql.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS menu_item_variants (
+    variant_id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL CHECK (price > 0),
+    recipe_multiplier FLOAT NOT NULL DEFAULT 1 CHECK (recipe_multiplier > 0),
+    UNIQUE (product_id, name)
+);
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS variant_id INT REFERENCES menu_item_variants(variant_id);
+
+CREATE TABLE IF NOT EXISTS menu_item_variant_ingredients (
+    variant_id INT REFERENCES menu_item_variants(variant_id) ON DELETE CASCADE,
+    ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL,
+    PRIMARY KEY (variant_id, ingredient_id)
+);
+
+--Рецепт позиции меню с учётом варианта: переопределённый рецепт варианта или базовый рецепт, умноженный на коэффициент.
+CREATE OR REPLACE FUNCTION recipe_for(p_product_id INT, p_variant_id INT)
+RETURNS TABLE (ingredient_id INT, quantity FLOAT) AS $$
+    SELECT vi.ingredient_id, vi.quantity
+    FROM menu_item_variant_ingredients vi
+    WHERE vi.variant_id = p_variant_id
+    UNION ALL
+    SELECT mii.ingredient_id, mii.quantity * COALESCE(v.recipe_multiplier, 1)
+    FROM menu_item_ingredients mii
+    LEFT JOIN menu_item_variants v ON v.variant_id = p_variant_id
+    WHERE mii.product_id = p_product_id
+        AND NOT EXISTS (
+            SELECT 1 FROM menu_item_variant_ingredients vi WHERE vi.variant_id = p_variant_id
+        );
+$$ LANGUAGE sql STABLE;
+
+--Ингредиенты, необходимые для каждой строки заказа.
+CREATE OR REPLACE VIEW order_line_ingredients AS
+SELECT oi.order_item_id, oi.order_id, r.ingredient_id, r.quantity * oi.quantity AS quantity
+FROM order_items oi
+CROSS JOIN LATERAL recipe_for(oi.product_id, oi.variant_id) r;
+
+--Цена и сумма каждой строки заказа с учётом варианта.
+CREATE OR REPLACE VIEW order_line_totals AS
+SELECT
+    oi.order_item_id,
+    oi.order_id,
+    oi.product_id,
+    oi.variant_id,
+    oi.quantity,
+    mi.name || COALESCE(' (' || v.name || ')', '') AS item_name,
+    COALESCE(v.price, mi.price) AS unit_price,
+    COALESCE(v.price, mi.price) * oi.quantity AS line_total
+FROM order_items oi
+JOIN menu_items mi ON oi.product_id = mi.product_id
+LEFT JOIN menu_item_variants v ON oi.variant_id = v.variant_id;
+
+--Вариант в строке заказа должен принадлежать заказанной позиции меню.
+CREATE OR REPLACE FUNCTION check_order_item_variant()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.variant_id IS NOT NULL AND NOT EXISTS (
+        SELECT 1 FROM menu_item_variants
+        WHERE variant_id = NEW.variant_id AND product_id = NEW.product_id
+    ) THEN
+        RAISE EXCEPTION 'Variant % does not belong to menu item %', NEW.variant_id, NEW.product_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS order_item_variant_check ON order_items;
+CREATE TRIGGER order_item_variant_check
+BEFORE INSERT OR UPDATE ON order_items
+FOR EACH ROW
+EXECUTE FUNCTION check_order_item_variant();
+
+COMMIT;
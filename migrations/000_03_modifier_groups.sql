@@ -0,0 +1,71 @@
+-- Добавляет группы модификаторов с правилами выбора, их влияние на цену и ингредиенты строк заказа.
+-- Для баз, созданных до этого; выполняется после 000_02_menu_item_variants.sql.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS modifier_groups (
+    group_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    min_select INT NOT NULL DEFAULT 0 CHECK (min_select >= 0),
+    max_select INT NOT NULL DEFAULT 1 CHECK (max_select >= min_select)
+);
+
+CREATE TABLE IF NOT EXISTS modifier_options (
+    option_id SERIAL PRIMARY KEY,
+    group_id INT REFERENCES modifier_groups(group_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    UNIQUE (group_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS modifier_option_ingredients (
+    option_id INT REFERENCES modifier_options(option_id) ON DELETE CASCADE,
+    ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL,
+    PRIMARY KEY (option_id, ingredient_id)
+);
+
+CREATE TABLE IF NOT EXISTS menu_item_modifier_groups (
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    group_id INT REFERENCES modifier_groups(group_id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, group_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_item_modifiers (
+    order_item_id INT REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    option_id INT REFERENCES modifier_options(option_id),
+    PRIMARY KEY (order_item_id, option_id)
+);
+
+--Ингредиенты, необходимые для каждой строки заказа.
+CREATE OR REPLACE VIEW order_line_ingredients AS
+SELECT oi.order_item_id, oi.order_id, r.ingredient_id, r.quantity * oi.quantity AS quantity
+FROM order_items oi
+CROSS JOIN LATERAL recipe_for(oi.product_id, oi.variant_id) r
+UNION ALL
+SELECT oi.order_item_id, oi.order_id, moi.ingredient_id, moi.quantity * oi.quantity AS quantity
+FROM order_items oi
+JOIN order_item_modifiers oim ON oi.order_item_id = oim.order_item_id
+JOIN modifier_option_ingredients moi ON oim.option_id = moi.option_id;
+
+--Цена и сумма каждой строки заказа с учётом варианта и модификаторов.
+CREATE OR REPLACE VIEW order_line_totals AS
+SELECT
+    oi.order_item_id,
+    oi.order_id,
+    oi.product_id,
+    oi.variant_id,
+    oi.quantity,
+    mi.name || COALESCE(' (' || v.name || ')', '') AS item_name,
+    COALESCE(v.price, mi.price) + COALESCE(m.price_delta, 0) AS unit_price,
+    (COALESCE(v.price, mi.price) + COALESCE(m.price_delta, 0)) * oi.quantity AS line_total
+FROM order_items oi
+JOIN menu_items mi ON oi.product_id = mi.product_id
+LEFT JOIN menu_item_variants v ON oi.variant_id = v.variant_id
+LEFT JOIN LATERAL (
+    SELECT SUM(mo.price_delta) AS price_delta
+    FROM order_item_modifiers oim
+    JOIN modifier_options mo ON oim.option_id = mo.option_id
+    WHERE oim.order_item_id = oi.order_item_id
+) m ON TRUE;
+
+COMMIT;
@@ -0,0 +1,17 @@
+-- Добавляет запланированные изменения цен позиций меню, которые применяет фоновый планировщик.
+-- Для баз, созданных до этого; выполняется после 000_03_modifier_groups.sql.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS scheduled_price_changes (
+    schedule_id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    new_price DECIMAL(10, 2) NOT NULL CHECK (new_price > 0),
+    effective_at TIMESTAMP NOT NULL,
+    applied_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_price_changes_due ON scheduled_price_changes(effective_at) WHERE applied_at IS NULL;
+
+COMMIT;
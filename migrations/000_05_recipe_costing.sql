@@ -0,0 +1,34 @@
+-- Переводит цену ингредиента в десятичную, добавляет себестоимость позиций меню по рецепту и журнал
+-- предупреждений о низкой марже. Для баз, созданных до этого; выполняется после 000_04_scheduled_price_changes.sql.
+BEGIN;
+
+UPDATE inventory SET price = 0 WHERE price IS NULL;
+ALTER TABLE inventory
+    ALTER COLUMN price TYPE DECIMAL(10, 2),
+    ALTER COLUMN price SET DEFAULT 0,
+    ALTER COLUMN price SET NOT NULL;
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_price_check;
+ALTER TABLE inventory ADD CONSTRAINT inventory_price_check CHECK (price >= 0);
+
+CREATE TABLE IF NOT EXISTS margin_alerts (
+    alert_id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    old_price DECIMAL(10, 2) NOT NULL,
+    new_price DECIMAL(10, 2) NOT NULL,
+    cost DECIMAL(10, 2) NOT NULL,
+    margin_percent FLOAT NOT NULL,
+    threshold FLOAT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+--Себестоимость позиции меню по рецепту и цене ингредиентов.
+CREATE OR REPLACE VIEW menu_item_costs AS
+SELECT
+    mi.product_id,
+    COALESCE(SUM(mii.quantity * i.price), 0) AS cost
+FROM menu_items mi
+LEFT JOIN menu_item_ingredients mii ON mi.product_id = mii.product_id
+LEFT JOIN inventory i ON mii.ingredient_id = i.ingredient_id
+GROUP BY mi.product_id;
+
+COMMIT;
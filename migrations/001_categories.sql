@@ -0,0 +1,32 @@
+-- Переносит свободные строковые категории menu_items.category в таблицу categories.
+-- Для баз, созданных до появления categories; "Coffee" и " coffee " сливаются в одну категорию.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS categories (
+    category_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    parent_id INT REFERENCES categories(category_id),
+    sort_order INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CHECK (parent_id IS NULL OR parent_id <> category_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name ON categories (LOWER(name));
+
+INSERT INTO categories (name)
+SELECT DISTINCT ON (LOWER(TRIM(category))) TRIM(category)
+FROM menu_items
+WHERE category IS NOT NULL AND TRIM(category) <> ''
+ORDER BY LOWER(TRIM(category)), TRIM(category)
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE menu_items ADD COLUMN IF NOT EXISTS category_id INT REFERENCES categories(category_id);
+
+UPDATE menu_items mi
+SET category_id = c.category_id
+FROM categories c
+WHERE LOWER(TRIM(mi.category)) = LOWER(c.name);
+
+ALTER TABLE menu_items DROP COLUMN category;
+
+COMMIT;
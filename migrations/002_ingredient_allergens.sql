@@ -0,0 +1,27 @@
+-- Переносит аллергены с позиций меню на ингредиенты склада.
+-- Аллергены позиций теперь вычисляются из рецепта (представление menu_item_allergens), поэтому каждый
+-- ингредиент рецепта получает аллергены всех позиций, в которых он используется. Так аллергены позиций
+-- не теряются, но ингредиент может получить лишние; их нужно уточнить через PUT /inventory/{id}.
+BEGIN;
+
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS allergens TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE inventory i
+SET allergens = ARRAY(
+    SELECT DISTINCT allergen
+    FROM UNNEST(i.allergens || m.allergens) AS allergen
+    ORDER BY allergen
+)
+FROM (
+    SELECT mii.ingredient_id, ARRAY_AGG(DISTINCT LOWER(TRIM(allergen))) AS allergens
+    FROM menu_item_ingredients mii
+    JOIN menu_items mi ON mii.product_id = mi.product_id
+    CROSS JOIN UNNEST(mi.allergens) AS allergen
+    WHERE TRIM(allergen) <> ''
+    GROUP BY mii.ingredient_id
+) m
+WHERE i.ingredient_id = m.ingredient_id;
+
+ALTER TABLE menu_items DROP COLUMN IF EXISTS allergens;
+
+COMMIT;
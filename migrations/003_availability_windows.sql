@@ -0,0 +1,52 @@
+-- Добавляет окна доступности позиций меню и категорий и функцию проверки menu_item_available_at.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS availability_windows (
+    window_id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    category_id INT REFERENCES categories(category_id) ON DELETE CASCADE,
+    days_of_week INT[],
+    start_time TIME,
+    end_time TIME,
+    start_date DATE,
+    end_date DATE,
+    CHECK ((product_id IS NULL) <> (category_id IS NULL)),
+    CHECK ((start_time IS NULL) = (end_time IS NULL)),
+    CHECK (days_of_week <@ ARRAY[1, 2, 3, 4, 5, 6, 7]),
+    CHECK (start_date IS NULL OR end_date IS NULL OR start_date <= end_date)
+);
+
+CREATE OR REPLACE FUNCTION menu_item_available_at(p_product_id INT, p_at TIMESTAMP)
+RETURNS BOOLEAN AS $$
+    WITH RECURSIVE scopes AS (
+        SELECT mi.product_id, NULL::INT AS category_id, mi.category_id AS parent_id
+        FROM menu_items mi
+        WHERE mi.product_id = p_product_id
+        UNION ALL
+        SELECT NULL::INT, c.category_id, c.parent_id
+        FROM categories c
+        JOIN scopes s ON c.category_id = s.parent_id
+    )
+    SELECT NOT EXISTS (
+        SELECT 1
+        FROM scopes s
+        WHERE EXISTS (
+            SELECT 1 FROM availability_windows w
+            WHERE w.product_id = s.product_id OR w.category_id = s.category_id
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM availability_windows w
+            WHERE (w.product_id = s.product_id OR w.category_id = s.category_id)
+            AND (w.days_of_week IS NULL OR EXTRACT(ISODOW FROM p_at)::INT = ANY(w.days_of_week))
+            AND (w.start_date IS NULL OR p_at::DATE >= w.start_date)
+            AND (w.end_date IS NULL OR p_at::DATE <= w.end_date)
+            AND (
+                w.start_time IS NULL
+                OR (w.start_time <= w.end_time AND p_at::TIME >= w.start_time AND p_at::TIME < w.end_time)
+                OR (w.start_time > w.end_time AND (p_at::TIME >= w.start_time OR p_at::TIME < w.end_time))
+            )
+        )
+    );
+$$ LANGUAGE sql STABLE;
+
+COMMIT;
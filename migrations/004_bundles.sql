@@ -0,0 +1,122 @@
+-- Добавляет комбо-наборы: слоты наборов, выбранные компоненты строк заказа и продажи по компонентам.
+-- Представления себестоимости, аллергенов и ингредиентов строк заказа пересоздаются с учётом компонентов.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS bundle_slots (
+    slot_id SERIAL PRIMARY KEY,
+    bundle_id INT NOT NULL REFERENCES menu_items(product_id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    UNIQUE (bundle_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS bundle_slot_options (
+    slot_id INT NOT NULL REFERENCES bundle_slots(slot_id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES menu_items(product_id),
+    PRIMARY KEY (slot_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_item_components (
+    order_item_component_id SERIAL PRIMARY KEY,
+    order_item_id INT NOT NULL REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    slot_id INT REFERENCES bundle_slots(slot_id) ON DELETE SET NULL,
+    product_id INT NOT NULL REFERENCES menu_items(product_id),
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
+
+CREATE OR REPLACE VIEW menu_item_costs AS
+WITH recipe_costs AS (
+    SELECT
+        mi.product_id,
+        COALESCE(SUM(mii.quantity * i.price), 0) AS cost
+    FROM menu_items mi
+    LEFT JOIN menu_item_ingredients mii ON mi.product_id = mii.product_id
+    LEFT JOIN inventory i ON mii.ingredient_id = i.ingredient_id
+    GROUP BY mi.product_id
+),
+--Для слота комбо-набора берётся самый дорогой из вариантов.
+slot_costs AS (
+    SELECT s.bundle_id, s.quantity * MAX(rc.cost) AS cost
+    FROM bundle_slots s
+    JOIN bundle_slot_options o ON s.slot_id = o.slot_id
+    JOIN recipe_costs rc ON o.product_id = rc.product_id
+    GROUP BY s.bundle_id, s.slot_id, s.quantity
+)
+SELECT
+    rc.product_id,
+    rc.cost + COALESCE((SELECT SUM(sc.cost) FROM slot_costs sc WHERE sc.bundle_id = rc.product_id), 0) AS cost
+FROM recipe_costs rc;
+
+CREATE OR REPLACE VIEW menu_item_allergens AS
+SELECT
+    mi.product_id,
+    ARRAY(
+        SELECT DISTINCT allergen
+        FROM (
+            SELECT mii.ingredient_id
+            FROM menu_item_ingredients mii
+            WHERE mii.product_id = mi.product_id AND mii.quantity > 0
+            UNION
+            SELECT vi.ingredient_id
+            FROM menu_item_variant_ingredients vi
+            JOIN menu_item_variants v ON vi.variant_id = v.variant_id
+            WHERE v.product_id = mi.product_id AND vi.quantity > 0
+            UNION
+            SELECT cmi.ingredient_id
+            FROM bundle_slots bs
+            JOIN bundle_slot_options bo ON bs.slot_id = bo.slot_id
+            JOIN menu_item_ingredients cmi ON bo.product_id = cmi.product_id
+            WHERE bs.bundle_id = mi.product_id AND cmi.quantity > 0
+        ) r
+        JOIN inventory i ON r.ingredient_id = i.ingredient_id
+        CROSS JOIN UNNEST(i.allergens) AS allergen
+        ORDER BY allergen
+    ) AS allergens,
+    ARRAY(
+        SELECT DISTINCT allergen
+        FROM menu_item_modifier_groups mg
+        JOIN modifier_options mo ON mg.group_id = mo.group_id
+        JOIN modifier_option_ingredients moi ON mo.option_id = moi.option_id AND moi.quantity > 0
+        JOIN inventory i ON moi.ingredient_id = i.ingredient_id
+        CROSS JOIN UNNEST(i.allergens) AS allergen
+        WHERE mg.product_id = mi.product_id
+        ORDER BY allergen
+    ) AS modifier_allergens
+FROM menu_items mi;
+
+CREATE OR REPLACE VIEW order_line_ingredients AS
+SELECT oi.order_item_id, oi.order_id, r.ingredient_id, r.quantity * oi.quantity AS quantity
+FROM order_items oi
+CROSS JOIN LATERAL recipe_for(oi.product_id, oi.variant_id) r
+UNION ALL
+SELECT oi.order_item_id, oi.order_id, moi.ingredient_id, moi.quantity * oi.quantity AS quantity
+FROM order_items oi
+JOIN order_item_modifiers oim ON oi.order_item_id = oim.order_item_id
+JOIN modifier_option_ingredients moi ON oim.option_id = moi.option_id
+UNION ALL
+SELECT oi.order_item_id, oi.order_id, r.ingredient_id, r.quantity * oic.quantity * oi.quantity AS quantity
+FROM order_items oi
+JOIN order_item_components oic ON oi.order_item_id = oic.order_item_id
+CROSS JOIN LATERAL recipe_for(oic.product_id, NULL) r;
+
+CREATE OR REPLACE VIEW order_line_component_sales AS
+SELECT t.order_item_id, t.order_id, t.product_id, t.item_name, t.quantity, t.line_total AS revenue, NULL::INT AS bundle_id
+FROM order_line_totals t
+WHERE NOT EXISTS (SELECT 1 FROM order_item_components oic WHERE oic.order_item_id = t.order_item_id)
+UNION ALL
+SELECT
+    t.order_item_id,
+    t.order_id,
+    mi.product_id,
+    mi.name,
+    oic.quantity * t.quantity,
+    COALESCE(
+        t.line_total * mi.price * oic.quantity / NULLIF(SUM(mi.price * oic.quantity) OVER (PARTITION BY t.order_item_id), 0),
+        t.line_total / COUNT(*) OVER (PARTITION BY t.order_item_id)
+    ),
+    t.product_id
+FROM order_line_totals t
+JOIN order_item_components oic ON t.order_item_id = oic.order_item_id
+JOIN menu_items mi ON oic.product_id = mi.product_id;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Добавляет архивирование позиций меню и убирает каскадное удаление строк заказов вместе с позицией.
+BEGIN;
+
+ALTER TABLE menu_items ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_product_id_fkey;
+ALTER TABLE order_items ADD CONSTRAINT order_items_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES menu_items(product_id);
+
+COMMIT;
@@ -0,0 +1,18 @@
+-- Запрещает удалять ингредиент, пока его используют рецепты, варианты или модификаторы, и добавляет архивирование склада.
+BEGIN;
+
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+ALTER TABLE menu_item_ingredients DROP CONSTRAINT IF EXISTS menu_item_ingredients_ingredient_id_fkey;
+ALTER TABLE menu_item_ingredients ADD CONSTRAINT menu_item_ingredients_ingredient_id_fkey
+    FOREIGN KEY (ingredient_id) REFERENCES inventory(ingredient_id);
+
+ALTER TABLE menu_item_variant_ingredients DROP CONSTRAINT IF EXISTS menu_item_variant_ingredients_ingredient_id_fkey;
+ALTER TABLE menu_item_variant_ingredients ADD CONSTRAINT menu_item_variant_ingredients_ingredient_id_fkey
+    FOREIGN KEY (ingredient_id) REFERENCES inventory(ingredient_id);
+
+ALTER TABLE modifier_option_ingredients DROP CONSTRAINT IF EXISTS modifier_option_ingredients_ingredient_id_fkey;
+ALTER TABLE modifier_option_ingredients ADD CONSTRAINT modifier_option_ingredients_ingredient_id_fkey
+    FOREIGN KEY (ingredient_id) REFERENCES inventory(ingredient_id);
+
+COMMIT;
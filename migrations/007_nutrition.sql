@@ -0,0 +1,56 @@
+-- Добавляет пищевую ценность ингредиентов склада и представления, считающие её для порций позиций меню,
+-- опций модификаторов и строк заказа. После миграции значения ингредиентов заполняются через PUT /inventory/{id}.
+BEGIN;
+
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS kcal FLOAT NOT NULL DEFAULT 0 CHECK (kcal >= 0),
+    ADD COLUMN IF NOT EXISTS sugar FLOAT NOT NULL DEFAULT 0 CHECK (sugar >= 0),
+    ADD COLUMN IF NOT EXISTS fat FLOAT NOT NULL DEFAULT 0 CHECK (fat >= 0),
+    ADD COLUMN IF NOT EXISTS caffeine FLOAT NOT NULL DEFAULT 0 CHECK (caffeine >= 0);
+
+--Пищевая ценность порции позиции меню: базовый рецепт (variant_id NULL) и каждый вариант.
+CREATE VIEW menu_item_nutrition AS
+SELECT
+    s.product_id,
+    s.variant_id,
+    COALESCE(SUM(r.quantity * i.kcal), 0) AS kcal,
+    COALESCE(SUM(r.quantity * i.sugar), 0) AS sugar,
+    COALESCE(SUM(r.quantity * i.fat), 0) AS fat,
+    COALESCE(SUM(r.quantity * i.caffeine), 0) AS caffeine
+FROM (
+    SELECT product_id, NULL::INT AS variant_id FROM menu_items
+    UNION ALL
+    SELECT product_id, variant_id FROM menu_item_variants
+) s
+LEFT JOIN LATERAL recipe_for(s.product_id, s.variant_id) r ON TRUE
+LEFT JOIN inventory i ON r.ingredient_id = i.ingredient_id
+GROUP BY s.product_id, s.variant_id;
+
+--Изменение пищевой ценности порции при выборе опции модификатора; для замен может быть отрицательным.
+CREATE VIEW modifier_option_nutrition AS
+SELECT
+    mo.option_id,
+    COALESCE(SUM(moi.quantity * i.kcal), 0) AS kcal,
+    COALESCE(SUM(moi.quantity * i.sugar), 0) AS sugar,
+    COALESCE(SUM(moi.quantity * i.fat), 0) AS fat,
+    COALESCE(SUM(moi.quantity * i.caffeine), 0) AS caffeine
+FROM modifier_options mo
+LEFT JOIN modifier_option_ingredients moi ON mo.option_id = moi.option_id
+LEFT JOIN inventory i ON moi.ingredient_id = i.ingredient_id
+GROUP BY mo.option_id;
+
+--Пищевая ценность одной порции строки заказа с учётом варианта, модификаторов и компонентов комбо-набора.
+CREATE VIEW order_line_nutrition AS
+SELECT
+    li.order_item_id,
+    li.order_id,
+    SUM(li.quantity * i.kcal) / NULLIF(oi.quantity, 0) AS kcal,
+    SUM(li.quantity * i.sugar) / NULLIF(oi.quantity, 0) AS sugar,
+    SUM(li.quantity * i.fat) / NULLIF(oi.quantity, 0) AS fat,
+    SUM(li.quantity * i.caffeine) / NULLIF(oi.quantity, 0) AS caffeine
+FROM order_line_ingredients li
+JOIN order_items oi ON li.order_item_id = oi.order_item_id
+JOIN inventory i ON li.ingredient_id = i.ingredient_id
+GROUP BY li.order_item_id, li.order_id, oi.quantity;
+
+COMMIT;
@@ -0,0 +1,27 @@
+-- Добавляет версии меню (черновик, публикация, откат) и версию меню, по которой оценён заказ.
+-- Существующие заказы остаются без версии; первая версия создаётся вместе с первым черновиком.
+BEGIN;
+
+CREATE TYPE menu_version_status AS ENUM ('draft', 'published', 'retired');
+
+CREATE TABLE IF NOT EXISTS menu_versions (
+    version_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    status menu_version_status NOT NULL DEFAULT 'draft',
+    based_on INT REFERENCES menu_versions(version_id) ON DELETE SET NULL,
+    items JSONB NOT NULL DEFAULT '[]',
+    publish_at TIMESTAMP,
+    published_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_menu_versions_published ON menu_versions (status) WHERE status = 'published';
+
+CREATE OR REPLACE FUNCTION current_menu_version()
+RETURNS INT AS $$
+    SELECT version_id FROM menu_versions WHERE status = 'published';
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS menu_version_id INT DEFAULT current_menu_version() REFERENCES menu_versions(version_id);
+
+COMMIT;
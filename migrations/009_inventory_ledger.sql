@@ -0,0 +1,61 @@
+-- Подключает журнал движения склада: триггер записывает каждое изменение остатка в inventory_transactions.
+-- Прежние записи журнала остаются как есть; восстановление остатка на момент времени опирается на движения после него.
+BEGIN;
+
+ALTER TABLE inventory_transactions ADD COLUMN IF NOT EXISTS order_id INT;
+ALTER TABLE inventory_transactions ADD COLUMN IF NOT EXISTS quantity_after FLOAT;
+
+CREATE INDEX IF NOT EXISTS idx_inventory_transactions_ingredient ON inventory_transactions (ingredient_id, created_at);
+
+--Причина и заказ для движений склада до конца текущей транзакции; их читает inventory_movement_trigger.
+CREATE OR REPLACE FUNCTION set_inventory_movement(p_reason TEXT, p_order_id INT)
+RETURNS VOID AS $$
+BEGIN
+    PERFORM set_config('inventory.reason', p_reason, true);
+    PERFORM set_config('inventory.order_id', COALESCE(p_order_id::TEXT, ''), true);
+END;
+$$ LANGUAGE plpgsql;
+
+--Автоматическая запись в inventory_transactions при любом изменении остатка. Без заданной причины
+--новый ингредиент записывается как 'initial stock', изменение остатка как 'manual update'.
+CREATE OR REPLACE FUNCTION log_inventory_movement()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta FLOAT := NEW.quantity - CASE WHEN TG_OP = 'INSERT' THEN 0 ELSE OLD.quantity END;
+BEGIN
+    IF delta <> 0 THEN
+        INSERT INTO inventory_transactions (ingredient_id, quantity_change, reason, order_id, quantity_after)
+        VALUES (
+            NEW.ingredient_id,
+            delta,
+            COALESCE(
+                NULLIF(current_setting('inventory.reason', true), ''),
+                CASE WHEN TG_OP = 'INSERT' THEN 'initial stock' ELSE 'manual update' END
+            ),
+            NULLIF(current_setting('inventory.order_id', true), '')::INT,
+            NEW.quantity
+        );
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS inventory_movement_trigger ON inventory;
+CREATE TRIGGER inventory_movement_trigger
+AFTER INSERT OR UPDATE OF quantity ON inventory
+FOR EACH ROW
+EXECUTE FUNCTION log_inventory_movement();
+
+--Остаток ингредиента на момент p_at: текущий остаток минус все движения после этого момента.
+CREATE OR REPLACE FUNCTION inventory_level_at(p_ingredient_id INT, p_at TIMESTAMP)
+RETURNS FLOAT AS $$
+    SELECT i.quantity - COALESCE((
+        SELECT SUM(t.quantity_change)
+        FROM inventory_transactions t
+        WHERE t.ingredient_id = i.ingredient_id AND t.created_at > p_at
+    ), 0)
+    FROM inventory i
+    WHERE i.ingredient_id = p_ingredient_id;
+$$ LANGUAGE sql STABLE;
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- Добавляет таблицу пересчётов остатка: абсолютный остаток задаётся только пересчётом с сохранением расхождения.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS stock_counts (
+    count_id SERIAL PRIMARY KEY,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    expected_quantity FLOAT NOT NULL,
+    counted_quantity FLOAT NOT NULL CHECK (counted_quantity >= 0),
+    variance FLOAT GENERATED ALWAYS AS (counted_quantity - expected_quantity) STORED,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMIT;
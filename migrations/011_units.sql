@@ -0,0 +1,176 @@
+-- Добавляет справочник единиц измерения и единицы в рецептах. Существующие строки рецептов получают единицу склада
+-- ингредиента; единицы склада, которых нет в справочнике, добавляются как отдельные размерности без перевода.
+BEGIN;
+
+--Справочник единиц измерения: единицы одной размерности переводятся друг в друга через коэффициент к базовой единице.
+CREATE TABLE IF NOT EXISTS units (
+    unit VARCHAR(20) PRIMARY KEY,
+    dimension VARCHAR(20) NOT NULL,
+    factor FLOAT NOT NULL CHECK (factor > 0)
+);
+
+INSERT INTO units (unit, dimension, factor) VALUES
+('g', 'mass', 1),
+('kg', 'mass', 1000),
+('ml', 'volume', 1),
+('l', 'volume', 1000),
+('liters', 'volume', 1000),
+('pieces', 'count', 1)
+ON CONFLICT (unit) DO NOTHING;
+
+INSERT INTO units (unit, dimension, factor)
+SELECT DISTINCT unit, unit, 1 FROM inventory
+ON CONFLICT (unit) DO NOTHING;
+
+ALTER TABLE inventory ADD CONSTRAINT inventory_unit_fkey FOREIGN KEY (unit) REFERENCES units(unit);
+
+ALTER TABLE menu_item_ingredients
+    ADD COLUMN unit VARCHAR(20) REFERENCES units(unit),
+    ADD COLUMN stock_quantity FLOAT;
+UPDATE menu_item_ingredients mii SET unit = i.unit, stock_quantity = mii.quantity FROM inventory i WHERE mii.ingredient_id = i.ingredient_id;
+ALTER TABLE menu_item_ingredients ALTER COLUMN unit SET NOT NULL, ALTER COLUMN stock_quantity SET NOT NULL;
+
+ALTER TABLE menu_item_variant_ingredients
+    ADD COLUMN unit VARCHAR(20) REFERENCES units(unit),
+    ADD COLUMN stock_quantity FLOAT;
+UPDATE menu_item_variant_ingredients vi SET unit = i.unit, stock_quantity = vi.quantity FROM inventory i WHERE vi.ingredient_id = i.ingredient_id;
+ALTER TABLE menu_item_variant_ingredients ALTER COLUMN unit SET NOT NULL, ALTER COLUMN stock_quantity SET NOT NULL;
+
+ALTER TABLE modifier_option_ingredients
+    ADD COLUMN unit VARCHAR(20) REFERENCES units(unit),
+    ADD COLUMN stock_quantity FLOAT;
+UPDATE modifier_option_ingredients moi SET unit = i.unit, stock_quantity = moi.quantity FROM inventory i WHERE moi.ingredient_id = i.ingredient_id;
+ALTER TABLE modifier_option_ingredients ALTER COLUMN unit SET NOT NULL, ALTER COLUMN stock_quantity SET NOT NULL;
+
+--Перевод количества между единицами одной размерности; для несовместимых единиц - ошибка.
+CREATE OR REPLACE FUNCTION convert_unit(p_quantity FLOAT, p_from VARCHAR, p_to VARCHAR)
+RETURNS FLOAT AS $$
+DECLARE
+    converted FLOAT;
+BEGIN
+    SELECT p_quantity * f.factor / t.factor INTO converted
+    FROM units f
+    JOIN units t ON f.dimension = t.dimension
+    WHERE f.unit = p_from AND t.unit = p_to;
+    IF converted IS NULL AND p_quantity IS NOT NULL THEN
+        RAISE EXCEPTION 'Unit % cannot be converted to %', p_from, p_to;
+    END IF;
+    RETURN converted;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+--Строка рецепта без единицы получает единицу склада ингредиента; количество переводится в единицу склада.
+CREATE OR REPLACE FUNCTION set_recipe_stock_quantity()
+RETURNS TRIGGER AS $$
+DECLARE
+    stock_unit VARCHAR(20);
+BEGIN
+    SELECT unit INTO stock_unit FROM inventory WHERE ingredient_id = NEW.ingredient_id;
+    NEW.unit := COALESCE(NEW.unit, stock_unit);
+    NEW.stock_quantity := convert_unit(NEW.quantity, NEW.unit, stock_unit);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER recipe_stock_quantity_trigger
+BEFORE INSERT OR UPDATE ON menu_item_ingredients
+FOR EACH ROW
+EXECUTE FUNCTION set_recipe_stock_quantity();
+
+CREATE TRIGGER variant_stock_quantity_trigger
+BEFORE INSERT OR UPDATE ON menu_item_variant_ingredients
+FOR EACH ROW
+EXECUTE FUNCTION set_recipe_stock_quantity();
+
+CREATE TRIGGER modifier_stock_quantity_trigger
+BEFORE INSERT OR UPDATE ON modifier_option_ingredients
+FOR EACH ROW
+EXECUTE FUNCTION set_recipe_stock_quantity();
+
+--При смене единицы склада ингредиента рецепты пересчитывают stock_quantity в новую единицу.
+CREATE OR REPLACE FUNCTION refresh_recipe_stock_quantities()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE menu_item_ingredients SET unit = unit WHERE ingredient_id = NEW.ingredient_id;
+    UPDATE menu_item_variant_ingredients SET unit = unit WHERE ingredient_id = NEW.ingredient_id;
+    UPDATE modifier_option_ingredients SET unit = unit WHERE ingredient_id = NEW.ingredient_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER inventory_unit_trigger
+AFTER UPDATE OF unit ON inventory
+FOR EACH ROW
+WHEN (OLD.unit IS DISTINCT FROM NEW.unit)
+EXECUTE FUNCTION refresh_recipe_stock_quantities();
+
+--Рецепт позиции меню с учётом варианта: переопределённый рецепт варианта или базовый рецепт, умноженный на коэффициент.
+CREATE OR REPLACE FUNCTION recipe_for(p_product_id INT, p_variant_id INT)
+RETURNS TABLE (ingredient_id INT, quantity FLOAT) AS $$
+    SELECT vi.ingredient_id, vi.stock_quantity
+    FROM menu_item_variant_ingredients vi
+    WHERE vi.variant_id = p_variant_id
+    UNION ALL
+    SELECT mii.ingredient_id, mii.stock_quantity * COALESCE(v.recipe_multiplier, 1)
+    FROM menu_item_ingredients mii
+    LEFT JOIN menu_item_variants v ON v.variant_id = p_variant_id
+    WHERE mii.product_id = p_product_id
+        AND NOT EXISTS (
+            SELECT 1 FROM menu_item_variant_ingredients vi WHERE vi.variant_id = p_variant_id
+        );
+$$ LANGUAGE sql STABLE;
+
+--Себестоимость позиции меню по рецепту и цене ингредиентов.
+CREATE OR REPLACE VIEW menu_item_costs AS
+WITH recipe_costs AS (
+    SELECT
+        mi.product_id,
+        COALESCE(SUM(mii.stock_quantity * i.price), 0) AS cost
+    FROM menu_items mi
+    LEFT JOIN menu_item_ingredients mii ON mi.product_id = mii.product_id
+    LEFT JOIN inventory i ON mii.ingredient_id = i.ingredient_id
+    GROUP BY mi.product_id
+),
+--Для слота комбо-набора берётся самый дорогой из вариантов.
+slot_costs AS (
+    SELECT s.bundle_id, s.quantity * MAX(rc.cost) AS cost
+    FROM bundle_slots s
+    JOIN bundle_slot_options o ON s.slot_id = o.slot_id
+    JOIN recipe_costs rc ON o.product_id = rc.product_id
+    GROUP BY s.bundle_id, s.slot_id, s.quantity
+)
+SELECT
+    rc.product_id,
+    rc.cost + COALESCE((SELECT SUM(sc.cost) FROM slot_costs sc WHERE sc.bundle_id = rc.product_id), 0) AS cost
+FROM recipe_costs rc;
+
+--Изменение пищевой ценности порции при выборе опции модификатора; для замен может быть отрицательным.
+CREATE OR REPLACE VIEW modifier_option_nutrition AS
+SELECT
+    mo.option_id,
+    COALESCE(SUM(moi.stock_quantity * i.kcal), 0) AS kcal,
+    COALESCE(SUM(moi.stock_quantity * i.sugar), 0) AS sugar,
+    COALESCE(SUM(moi.stock_quantity * i.fat), 0) AS fat,
+    COALESCE(SUM(moi.stock_quantity * i.caffeine), 0) AS caffeine
+FROM modifier_options mo
+LEFT JOIN modifier_option_ingredients moi ON mo.option_id = moi.option_id
+LEFT JOIN inventory i ON moi.ingredient_id = i.ingredient_id
+GROUP BY mo.option_id;
+
+--Ингредиенты, необходимые для каждой строки заказа, включая рецепты компонентов комбо-набора.
+CREATE OR REPLACE VIEW order_line_ingredients AS
+SELECT oi.order_item_id, oi.order_id, r.ingredient_id, r.quantity * oi.quantity AS quantity
+FROM order_items oi
+CROSS JOIN LATERAL recipe_for(oi.product_id, oi.variant_id) r
+UNION ALL
+SELECT oi.order_item_id, oi.order_id, moi.ingredient_id, moi.stock_quantity * oi.quantity AS quantity
+FROM order_items oi
+JOIN order_item_modifiers oim ON oi.order_item_id = oim.order_item_id
+JOIN modifier_option_ingredients moi ON oim.option_id = moi.option_id
+UNION ALL
+SELECT oi.order_item_id, oi.order_id, r.ingredient_id, r.quantity * oic.quantity * oi.quantity AS quantity
+FROM order_items oi
+JOIN order_item_components oic ON oi.order_item_id = oic.order_item_id
+CROSS JOIN LATERAL recipe_for(oic.product_id, NULL) r;
+
+COMMIT;
@@ -0,0 +1,59 @@
+-- Добавляет точки заказа и нормы запаса ингредиентов и события нехватки, которые триггер записывает, когда
+-- изменение остатка опускает его до точки заказа.
+BEGIN;
+
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS reorder_point FLOAT CHECK (reorder_point >= 0),
+    ADD COLUMN IF NOT EXISTS par_level FLOAT CHECK (par_level >= 0),
+    ADD CONSTRAINT inventory_par_level_check CHECK (par_level IS NULL OR reorder_point IS NULL OR par_level >= reorder_point);
+
+--События нехватки: остаток ингредиента опустился до точки заказа или ниже.
+CREATE TABLE IF NOT EXISTS stock_alerts (
+    alert_id SERIAL PRIMARY KEY,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL,
+    reorder_point FLOAT NOT NULL,
+    par_level FLOAT,
+    reason VARCHAR(50),
+    order_id INT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+--Когда изменение остатка пересекает точку заказа сверху вниз, записывается событие в stock_alerts
+--и публикуется уведомление в канал stock_alerts.
+CREATE OR REPLACE FUNCTION raise_stock_alert()
+RETURNS TRIGGER AS $$
+DECLARE
+    alert stock_alerts%ROWTYPE;
+BEGIN
+    INSERT INTO stock_alerts (ingredient_id, quantity, reorder_point, par_level, reason, order_id)
+    VALUES (
+        NEW.ingredient_id,
+        NEW.quantity,
+        NEW.reorder_point,
+        NEW.par_level,
+        NULLIF(current_setting('inventory.reason', true), ''),
+        NULLIF(current_setting('inventory.order_id', true), '')::INT
+    )
+    RETURNING * INTO alert;
+    PERFORM pg_notify('stock_alerts', json_build_object(
+        'alert_id', alert.alert_id,
+        'ingredient_id', alert.ingredient_id,
+        'name', NEW.name,
+        'quantity', alert.quantity,
+        'unit', NEW.unit,
+        'reorder_point', alert.reorder_point,
+        'par_level', alert.par_level,
+        'reason', alert.reason,
+        'order_id', alert.order_id
+    )::TEXT);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_alert_trigger
+AFTER UPDATE OF quantity ON inventory
+FOR EACH ROW
+WHEN (NEW.reorder_point IS NOT NULL AND NEW.quantity <= NEW.reorder_point AND OLD.quantity > NEW.reorder_point)
+EXECUTE FUNCTION raise_stock_alert();
+
+COMMIT;
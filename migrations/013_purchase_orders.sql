@@ -0,0 +1,56 @@
+-- Добавляет поставщиков с ингредиентами и ценами, заказы поставщикам со статусами и приёмку товара.
+BEGIN;
+
+CREATE TYPE purchase_order_status AS ENUM ('draft', 'sent', 'partially_received', 'received');
+
+--Поставщики и ингредиенты, которые они поставляют, с ценой за единицу склада.
+CREATE TABLE IF NOT EXISTS suppliers (
+    supplier_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    contact_name VARCHAR(100) NOT NULL DEFAULT '',
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    lead_time_days INT NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_suppliers_name ON suppliers (LOWER(name));
+
+CREATE TABLE IF NOT EXISTS supplier_ingredients (
+    supplier_id INT REFERENCES suppliers(supplier_id) ON DELETE CASCADE,
+    ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    unit_cost DECIMAL(10, 2) NOT NULL CHECK (unit_cost >= 0),
+    PRIMARY KEY (supplier_id, ingredient_id)
+);
+
+--Заказы поставщикам: черновик -> отправлен -> частично получен -> получен. Количество в единице склада ингредиента.
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    purchase_order_id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(supplier_id),
+    status purchase_order_status NOT NULL DEFAULT 'draft',
+    expected_at DATE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    received_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+    line_id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(purchase_order_id) ON DELETE CASCADE,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id),
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10, 2) NOT NULL CHECK (unit_cost >= 0),
+    received_quantity FLOAT NOT NULL DEFAULT 0 CHECK (received_quantity >= 0 AND received_quantity <= quantity),
+    UNIQUE (purchase_order_id, ingredient_id)
+);
+
+--Каждая приёмка строки заказа поставщику: сколько пришло и по какой цене.
+CREATE TABLE IF NOT EXISTS purchase_order_receipts (
+    receipt_id SERIAL PRIMARY KEY,
+    line_id INT NOT NULL REFERENCES purchase_order_lines(line_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10, 2) NOT NULL,
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMIT;
@@ -0,0 +1,36 @@
+-- Добавляет сессии инвентаризации с пересчётами от нескольких счётчиков и привязывает к ним пересчёты остатка
+-- вместе с себестоимостью единицы для оценки расхождений в деньгах.
+BEGIN;
+
+CREATE TYPE stocktake_status AS ENUM ('open', 'finalized');
+
+CREATE TABLE IF NOT EXISTS stocktakes (
+    stocktake_id SERIAL PRIMARY KEY,
+    status stocktake_status NOT NULL DEFAULT 'open',
+    note TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finalized_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktakes_open ON stocktakes (status) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS stocktake_entries (
+    entry_id SERIAL PRIMARY KEY,
+    stocktake_id INT NOT NULL REFERENCES stocktakes(stocktake_id) ON DELETE CASCADE,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    counter VARCHAR(100) NOT NULL,
+    counted_quantity FLOAT NOT NULL CHECK (counted_quantity >= 0),
+    counted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (stocktake_id, ingredient_id, counter)
+);
+
+ALTER TABLE stock_counts
+    ADD COLUMN IF NOT EXISTS stocktake_id INT REFERENCES stocktakes(stocktake_id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS unit_cost DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+--Пересчёты до миграции оцениваются по текущей цене ингредиента.
+UPDATE stock_counts c SET unit_cost = i.price FROM inventory i WHERE c.ingredient_id = i.ingredient_id;
+
+CREATE INDEX IF NOT EXISTS idx_stock_counts_stocktake ON stock_counts (stocktake_id);
+
+COMMIT;
@@ -0,0 +1,104 @@
+-- Добавляет партии ингредиентов со сроком годности и себестоимостью, списываемые по FIFO или FEFO, и триггер,
+-- который держит сумму остатков партий равной inventory.quantity.
+BEGIN;
+
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS consumption VARCHAR(4) NOT NULL DEFAULT 'fifo' CHECK (consumption IN ('fifo', 'fefo'));
+
+--Партии ингредиента: поступление, срок годности и себестоимость единицы. Сумма остатков партий
+--совпадает с inventory.quantity, её поддерживает триггер inventory_lot_trigger.
+CREATE TABLE IF NOT EXISTS inventory_lots (
+    lot_id SERIAL PRIMARY KEY,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL CHECK (quantity >= 0),
+    remaining FLOAT NOT NULL CHECK (remaining >= 0),
+    unit_cost DECIMAL(10, 4) NOT NULL DEFAULT 0,
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATE,
+    --Время списания просроченного остатка партии.
+    expired_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_lots_open ON inventory_lots (ingredient_id, received_at) WHERE remaining > 0;
+
+--Текущий остаток становится одной партией без срока годности по текущей цене.
+INSERT INTO inventory_lots (ingredient_id, quantity, remaining, unit_cost)
+SELECT i.ingredient_id, i.quantity, i.quantity, i.price
+FROM inventory i
+WHERE i.quantity > 0 AND NOT EXISTS (SELECT 1 FROM inventory_lots l WHERE l.ingredient_id = i.ingredient_id);
+
+--Срок годности партии, которую создаст следующее поступление в этой транзакции; NULL - без срока.
+CREATE OR REPLACE FUNCTION set_inventory_lot(p_expires_at DATE)
+RETURNS VOID AS $$
+BEGIN
+    PERFORM set_config('inventory.lot_expires_at', COALESCE(p_expires_at::TEXT, ''), true);
+END;
+$$ LANGUAGE plpgsql;
+
+--Поддерживает партии при любом изменении остатка: поступление (новый ингредиент, delivery, purchase order)
+--создаёт партию по текущей цене, прочий прирост возвращается в последнюю партию, расход списывается
+--с партий по правилу ингредиента. Смена единицы пересчитывает партии. При inventory.lot_sync = 'off'
+--партии уже изменены вызывающим кодом.
+CREATE OR REPLACE FUNCTION sync_inventory_lots()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta FLOAT;
+    reason TEXT := COALESCE(NULLIF(current_setting('inventory.reason', true), ''), '');
+    expires DATE := NULLIF(current_setting('inventory.lot_expires_at', true), '')::DATE;
+    needed FLOAT;
+    taken FLOAT;
+    lot RECORD;
+    latest INT;
+BEGIN
+    IF current_setting('inventory.lot_sync', true) = 'off' THEN
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.unit IS DISTINCT FROM OLD.unit THEN
+        UPDATE inventory_lots
+        SET quantity = convert_unit(quantity, OLD.unit, NEW.unit),
+            remaining = convert_unit(remaining, OLD.unit, NEW.unit),
+            unit_cost = unit_cost / convert_unit(1, OLD.unit, NEW.unit)
+        WHERE ingredient_id = NEW.ingredient_id;
+        RETURN NEW;
+    END IF;
+    delta := NEW.quantity - CASE WHEN TG_OP = 'INSERT' THEN 0 ELSE OLD.quantity END;
+    IF delta > 0 THEN
+        IF TG_OP = 'UPDATE' AND reason NOT IN ('delivery', 'purchase order') THEN
+            SELECT lot_id INTO latest
+            FROM inventory_lots
+            WHERE ingredient_id = NEW.ingredient_id AND expired_at IS NULL
+            ORDER BY received_at DESC, lot_id DESC
+            LIMIT 1;
+        END IF;
+        IF latest IS NULL THEN
+            INSERT INTO inventory_lots (ingredient_id, quantity, remaining, unit_cost, expires_at)
+            VALUES (NEW.ingredient_id, delta, delta, NEW.price, expires);
+        ELSE
+            UPDATE inventory_lots SET remaining = remaining + delta WHERE lot_id = latest;
+        END IF;
+    ELSIF delta < 0 THEN
+        needed := -delta;
+        FOR lot IN
+            SELECT lot_id, remaining
+            FROM inventory_lots
+            WHERE ingredient_id = NEW.ingredient_id AND remaining > 0
+            ORDER BY CASE WHEN NEW.consumption = 'fefo' THEN expires_at END NULLS LAST, received_at, lot_id
+            FOR UPDATE
+        LOOP
+            EXIT WHEN needed <= 0;
+            taken := LEAST(lot.remaining, needed);
+            UPDATE inventory_lots SET remaining = remaining - taken WHERE lot_id = lot.lot_id;
+            needed := needed - taken;
+        END LOOP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS inventory_lot_trigger ON inventory;
+CREATE TRIGGER inventory_lot_trigger
+AFTER INSERT OR UPDATE OF quantity, unit ON inventory
+FOR EACH ROW
+EXECUTE FUNCTION sync_inventory_lots();
+
+COMMIT;
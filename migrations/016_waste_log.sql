@@ -0,0 +1,29 @@
+-- Добавляет журнал списаний с кодами причин и списанными ингредиентами по себестоимости.
+BEGIN;
+
+--Журнал списаний: пролитое, переделанные напитки, испорченное и просроченное. Списывается ингредиент
+--или порция позиции меню целиком по рецепту; название сохраняется на случай удаления.
+CREATE TABLE IF NOT EXISTS waste_log (
+    waste_id SERIAL PRIMARY KEY,
+    ingredient_id INT REFERENCES inventory(ingredient_id) ON DELETE SET NULL,
+    product_id INT REFERENCES menu_items(product_id) ON DELETE SET NULL,
+    variant_id INT REFERENCES menu_item_variants(variant_id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('spill', 'remake', 'spoilage', 'expired', 'breakage', 'other')),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_waste_log_created ON waste_log (created_at);
+
+--Списанные ингредиенты каждой записи журнала с себестоимостью единицы на момент списания.
+CREATE TABLE IF NOT EXISTS waste_lines (
+    waste_id INT NOT NULL REFERENCES waste_log(waste_id) ON DELETE CASCADE,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10, 4) NOT NULL DEFAULT 0,
+    PRIMARY KEY (waste_id, ingredient_id)
+);
+
+COMMIT;
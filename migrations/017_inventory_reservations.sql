@@ -0,0 +1,32 @@
+-- Добавляет резерв ингредиентов под открытые заказы и остатки с учетом резерва.
+BEGIN;
+
+--Срок резерва ингредиентов открытого заказа; NULL - ингредиенты списаны при открытии заказа.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS reserved_until TIMESTAMP;
+
+--Резерв ингредиентов под открытые заказы: закрытие заказа списывает резерв, отмена или истечение срока
+--его снимает.
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+    order_id INT NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    ingredient_id INT NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity FLOAT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (order_id, ingredient_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_reservations_ingredient ON inventory_reservations (ingredient_id);
+
+--Остаток на складе, резерв открытых заказов и доступный для новых заказов остаток.
+CREATE OR REPLACE VIEW inventory_stock AS
+SELECT
+    i.ingredient_id,
+    i.quantity AS on_hand,
+    COALESCE(r.reserved, 0) AS reserved,
+    i.quantity - COALESCE(r.reserved, 0) AS available
+FROM inventory i
+LEFT JOIN (
+    SELECT ingredient_id, SUM(quantity) AS reserved
+    FROM inventory_reservations
+    GROUP BY ingredient_id
+) r ON i.ingredient_id = r.ingredient_id;
+
+COMMIT;
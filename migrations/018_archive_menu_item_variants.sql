@@ -0,0 +1,22 @@
+-- Снимает варианты с меню вместо удаления, чтобы публикация и откат версий меню не ломались на вариантах из заказов.
+BEGIN;
+
+--Время снятия варианта с меню; вариант остаётся для истории заказов и версий меню.
+ALTER TABLE menu_item_variants ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+--Вариант в строке заказа должен принадлежать заказанной позиции меню; снятый с меню вариант нельзя заказать.
+CREATE OR REPLACE FUNCTION check_order_item_variant()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.variant_id IS NOT NULL AND NOT EXISTS (
+        SELECT 1 FROM menu_item_variants
+        WHERE variant_id = NEW.variant_id AND product_id = NEW.product_id
+            AND (archived_at IS NULL OR TG_OP = 'UPDATE')
+    ) THEN
+        RAISE EXCEPTION 'Variant % does not belong to menu item %', NEW.variant_id, NEW.product_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- Отмечает запланированные изменения цен, которые не удалось применить, чтобы планировщик не повторял их бесконечно.
+BEGIN;
+
+--Число неудачных попыток применить изменение; после последней попытки заполняется failed_at.
+ALTER TABLE scheduled_price_changes ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE scheduled_price_changes ADD COLUMN IF NOT EXISTS failed_at TIMESTAMP;
+
+DROP INDEX IF EXISTS idx_scheduled_price_changes_due;
+CREATE INDEX idx_scheduled_price_changes_due ON scheduled_price_changes(effective_at) WHERE applied_at IS NULL AND failed_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,33 @@
+-- Хранит цену ингредиента с четырьмя знаками, чтобы при переходе на меньшую единицу цена за грамм или миллилитр
+-- не округлялась; представление себестоимости пересоздаётся, так как зависит от типа столбца.
+BEGIN;
+
+DROP VIEW IF EXISTS menu_item_costs;
+
+ALTER TABLE inventory ALTER COLUMN price TYPE DECIMAL(10, 4);
+
+--Себестоимость позиции меню по рецепту и цене ингредиентов.
+CREATE VIEW menu_item_costs AS
+WITH recipe_costs AS (
+    SELECT
+        mi.product_id,
+        COALESCE(SUM(mii.stock_quantity * i.price), 0) AS cost
+    FROM menu_items mi
+    LEFT JOIN menu_item_ingredients mii ON mi.product_id = mii.product_id
+    LEFT JOIN inventory i ON mii.ingredient_id = i.ingredient_id
+    GROUP BY mi.product_id
+),
+--Для слота комбо-набора берётся самый дорогой из вариантов.
+slot_costs AS (
+    SELECT s.bundle_id, s.quantity * MAX(rc.cost) AS cost
+    FROM bundle_slots s
+    JOIN bundle_slot_options o ON s.slot_id = o.slot_id
+    JOIN recipe_costs rc ON o.product_id = rc.product_id
+    GROUP BY s.bundle_id, s.slot_id, s.quantity
+)
+SELECT
+    rc.product_id,
+    rc.cost + COALESCE((SELECT SUM(sc.cost) FROM slot_costs sc WHERE sc.bundle_id = rc.product_id), 0) AS cost
+FROM recipe_costs rc;
+
+COMMIT;
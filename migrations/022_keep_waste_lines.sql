@@ -0,0 +1,27 @@
+-- Сохраняет строки журнала списаний при удалении ингредиента: строка хранит название и единицу,
+-- а ссылка на ингредиент обнуляется.
+BEGIN;
+
+ALTER TABLE waste_lines ADD COLUMN IF NOT EXISTS name VARCHAR(100);
+ALTER TABLE waste_lines ADD COLUMN IF NOT EXISTS unit VARCHAR(20);
+
+UPDATE waste_lines l
+SET name = i.name, unit = i.unit
+FROM inventory i
+WHERE l.ingredient_id = i.ingredient_id AND l.name IS NULL;
+
+ALTER TABLE waste_lines ALTER COLUMN name SET NOT NULL;
+ALTER TABLE waste_lines ALTER COLUMN unit SET NOT NULL;
+
+ALTER TABLE waste_lines ADD COLUMN IF NOT EXISTS line_id SERIAL;
+ALTER TABLE waste_lines DROP CONSTRAINT IF EXISTS waste_lines_pkey;
+ALTER TABLE waste_lines ADD PRIMARY KEY (line_id);
+ALTER TABLE waste_lines ALTER COLUMN ingredient_id DROP NOT NULL;
+ALTER TABLE waste_lines DROP CONSTRAINT IF EXISTS waste_lines_ingredient_id_fkey;
+ALTER TABLE waste_lines
+    ADD CONSTRAINT waste_lines_ingredient_id_fkey
+    FOREIGN KEY (ingredient_id) REFERENCES inventory(ingredient_id) ON DELETE SET NULL;
+ALTER TABLE waste_lines DROP CONSTRAINT IF EXISTS waste_lines_waste_id_ingredient_id_key;
+ALTER TABLE waste_lines ADD CONSTRAINT waste_lines_waste_id_ingredient_id_key UNIQUE (waste_id, ingredient_id);
+
+COMMIT;
@@ -0,0 +1,9 @@
+-- Хранит цены поставщиков, строк заказов поставщикам и приёмок с четырьмя знаками, как цену ингредиента,
+-- чтобы цена за грамм или миллилитр не округлялась и приёмка не меняла цену ингредиента при записи.
+BEGIN;
+
+ALTER TABLE supplier_ingredients ALTER COLUMN unit_cost TYPE DECIMAL(10, 4);
+ALTER TABLE purchase_order_lines ALTER COLUMN unit_cost TYPE DECIMAL(10, 4);
+ALTER TABLE purchase_order_receipts ALTER COLUMN unit_cost TYPE DECIMAL(10, 4);
+
+COMMIT;
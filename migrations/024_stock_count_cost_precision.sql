@@ -0,0 +1,7 @@
+-- Хранит себестоимость единицы в пересчётах с четырьмя знаками, как цену ингредиента, чтобы расхождение
+-- в граммах или миллилитрах не оценивалось в ноль.
+BEGIN;
+
+ALTER TABLE stock_counts ALTER COLUMN unit_cost TYPE DECIMAL(10, 4);
+
+COMMIT;
@@ -0,0 +1,69 @@
+-- Смена единицы ингредиента больше не записывается в журнал движением: журнал ингредиента пересчитывается
+-- в новую единицу. Прежние записи 'unit change' удаляются, а записи до них пересчитываются в единицу после смены
+-- по отношению остатка после смены к остатку до неё.
+BEGIN;
+
+DO $$
+DECLARE
+    change RECORD;
+    factor FLOAT;
+BEGIN
+    FOR change IN
+        SELECT transaction_id, ingredient_id
+        FROM inventory_transactions
+        WHERE reason = 'unit change'
+        ORDER BY transaction_id DESC
+    LOOP
+        SELECT quantity_after / NULLIF(quantity_after - quantity_change, 0) INTO factor
+        FROM inventory_transactions
+        WHERE transaction_id = change.transaction_id;
+        IF factor IS NOT NULL THEN
+            UPDATE inventory_transactions
+            SET quantity_change = quantity_change * factor,
+                quantity_after = quantity_after * factor
+            WHERE ingredient_id = change.ingredient_id AND transaction_id < change.transaction_id;
+        END IF;
+        DELETE FROM inventory_transactions WHERE transaction_id = change.transaction_id;
+    END LOOP;
+END;
+$$;
+
+--Автоматическая запись в inventory_transactions при любом изменении остатка. Без заданной причины
+--новый ингредиент записывается как 'initial stock', изменение остатка как 'manual update'. Смена единицы
+--не является движением: вместо записи журнал ингредиента пересчитывается в новую единицу.
+CREATE OR REPLACE FUNCTION log_inventory_movement()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta FLOAT := NEW.quantity - CASE WHEN TG_OP = 'INSERT' THEN 0 ELSE OLD.quantity END;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.unit IS DISTINCT FROM OLD.unit THEN
+        UPDATE inventory_transactions
+        SET quantity_change = convert_unit(quantity_change, OLD.unit, NEW.unit),
+            quantity_after = convert_unit(quantity_after, OLD.unit, NEW.unit)
+        WHERE ingredient_id = NEW.ingredient_id;
+        RETURN NEW;
+    END IF;
+    IF delta <> 0 THEN
+        INSERT INTO inventory_transactions (ingredient_id, quantity_change, reason, order_id, quantity_after)
+        VALUES (
+            NEW.ingredient_id,
+            delta,
+            COALESCE(
+                NULLIF(current_setting('inventory.reason', true), ''),
+                CASE WHEN TG_OP = 'INSERT' THEN 'initial stock' ELSE 'manual update' END
+            ),
+            NULLIF(current_setting('inventory.order_id', true), '')::INT,
+            NEW.quantity
+        );
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS inventory_movement_trigger ON inventory;
+CREATE TRIGGER inventory_movement_trigger
+AFTER INSERT OR UPDATE OF quantity, unit ON inventory
+FOR EACH ROW
+EXECUTE FUNCTION log_inventory_movement();
+
+COMMIT;
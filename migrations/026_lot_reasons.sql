@@ -0,0 +1,84 @@
+-- Партия хранит причину поступления, которое её создало, чтобы средняя цена закупок отбирала поставки
+-- и приёмки заказов поставщикам по самой партии, а не по совпадению времени с записью журнала. Причина
+-- существующих партий восстанавливается по журналу, как раньше.
+BEGIN;
+
+ALTER TABLE inventory_lots ADD COLUMN IF NOT EXISTS reason TEXT;
+
+UPDATE inventory_lots l
+SET reason = t.reason
+FROM inventory_transactions t
+WHERE l.reason IS NULL
+    AND t.ingredient_id = l.ingredient_id
+    AND t.created_at = l.received_at
+    AND t.quantity_change > 0;
+
+--Поддерживает партии при любом изменении остатка: поступление (новый ингредиент, delivery, purchase order)
+--создаёт партию по текущей цене с причиной поступления, прочий прирост возвращается в последнюю партию, расход списывается
+--с партий по правилу ингредиента. Просроченные, но ещё не списанные партии расходует только списание;
+--если партий не хватает, изменение отклоняется. Смена единицы пересчитывает партии. При inventory.lot_sync = 'off'
+--партии уже изменены вызывающим кодом.
+CREATE OR REPLACE FUNCTION sync_inventory_lots()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta FLOAT;
+    reason TEXT := COALESCE(NULLIF(current_setting('inventory.reason', true), ''), '');
+    expires DATE := NULLIF(current_setting('inventory.lot_expires_at', true), '')::DATE;
+    needed FLOAT;
+    taken FLOAT;
+    lot RECORD;
+    latest INT;
+BEGIN
+    IF current_setting('inventory.lot_sync', true) = 'off' THEN
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.unit IS DISTINCT FROM OLD.unit THEN
+        UPDATE inventory_lots
+        SET quantity = convert_unit(quantity, OLD.unit, NEW.unit),
+            remaining = convert_unit(remaining, OLD.unit, NEW.unit),
+            unit_cost = unit_cost / convert_unit(1, OLD.unit, NEW.unit)
+        WHERE ingredient_id = NEW.ingredient_id;
+        RETURN NEW;
+    END IF;
+    delta := NEW.quantity - CASE WHEN TG_OP = 'INSERT' THEN 0 ELSE OLD.quantity END;
+    IF delta > 0 THEN
+        IF TG_OP = 'UPDATE' AND reason NOT IN ('delivery', 'purchase order') THEN
+            SELECT lot_id INTO latest
+            FROM inventory_lots
+            WHERE ingredient_id = NEW.ingredient_id AND expired_at IS NULL
+            ORDER BY received_at DESC, lot_id DESC
+            LIMIT 1;
+        END IF;
+        IF latest IS NULL THEN
+            INSERT INTO inventory_lots (ingredient_id, quantity, remaining, unit_cost, expires_at, reason)
+            VALUES (
+                NEW.ingredient_id, delta, delta, NEW.price, expires,
+                COALESCE(NULLIF(reason, ''), CASE WHEN TG_OP = 'INSERT' THEN 'initial stock' ELSE 'manual update' END)
+            );
+        ELSE
+            UPDATE inventory_lots SET remaining = remaining + delta WHERE lot_id = latest;
+        END IF;
+    ELSIF delta < 0 THEN
+        needed := -delta;
+        FOR lot IN
+            SELECT lot_id, remaining
+            FROM inventory_lots
+            WHERE ingredient_id = NEW.ingredient_id AND remaining > 0
+                AND (expires_at IS NULL OR expires_at >= CURRENT_DATE OR reason = 'waste')
+            ORDER BY CASE WHEN NEW.consumption = 'fefo' THEN expires_at END NULLS LAST, received_at, lot_id
+            FOR UPDATE
+        LOOP
+            EXIT WHEN needed <= 0;
+            taken := LEAST(lot.remaining, needed);
+            UPDATE inventory_lots SET remaining = remaining - taken WHERE lot_id = lot.lot_id;
+            needed := needed - taken;
+        END LOOP;
+        IF needed > 0 THEN
+            RAISE EXCEPTION 'not enough unexpired stock in the lots of ingredient %', NEW.ingredient_id;
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
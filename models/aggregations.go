@@ -4,6 +4,7 @@ type Total struct {
 	TotalSales float64 `json:"total_sales"`
 }
 type Popular struct {
-	PopularSales string `json:"popular_item"`
-	Quantity     int    `json:"quantity"`
+	PopularSales string  `json:"popular_item"`
+	Quantity     int     `json:"quantity"`
+	Revenue      float64 `json:"revenue"`
 }
@@ -0,0 +1,18 @@
+package models
+
+type MenuAvailability struct {
+	ProductID          int                 `json:"product_id"`
+	Name               string              `json:"name"`
+	Price              float64             `json:"price"`
+	Available          bool                `json:"available"`
+	Servings           *int                `json:"servings"`
+	LimitingIngredient *LimitingIngredient `json:"limiting_ingredient"`
+}
+
+type LimitingIngredient struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	PerServing   float64 `json:"per_serving"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// A period in which a menu item or category can be ordered; omitted fields do not restrict
+type AvailabilityWindow struct {
+	DaysOfWeek []int   `json:"days_of_week,omitempty"` // ISO days, 1 = Monday
+	StartTime  *string `json:"start_time,omitempty"`   // "15:04"
+	EndTime    *string `json:"end_time,omitempty"`
+	StartDate  *string `json:"start_date,omitempty"` // "2006-01-02"
+	EndDate    *string `json:"end_date,omitempty"`
+}
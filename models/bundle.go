@@ -0,0 +1,15 @@
+package models
+
+// A slot of a bundle menu item filled with one of the listed menu items; a single option makes a fixed component
+type BundleSlot struct {
+	SlotID   int    `json:"slot_id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Options  []int  `json:"menu_item_ids"`
+}
+
+// The menu item chosen for a bundle slot on an order line
+type OrderComponent struct {
+	SlotID    int `json:"slot_id"`
+	ProductID int `json:"menu_item_id"`
+}
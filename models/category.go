@@ -0,0 +1,18 @@
+package models
+
+type Category struct {
+	CategoryID   int                  `json:"category_id"`
+	Name         string               `json:"name"`
+	ParentID     *int                 `json:"parent_id"`
+	SortOrder    int                  `json:"sort_order"`
+	Active       *bool                `json:"active"`
+	Availability []AvailabilityWindow `json:"availability"`
+}
+
+type CategoryGroup struct {
+	CategoryID    int             `json:"category_id"`
+	Name          string          `json:"name"`
+	SortOrder     int             `json:"sort_order"`
+	Items         []MenuItem      `json:"items"`
+	Subcategories []CategoryGroup `json:"subcategories"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// A recipe, variant override or modifier option that uses an inventory ingredient
+type IngredientUsage struct {
+	Source    string  `json:"source"` // recipe, variant or modifier
+	ProductID *int    `json:"product_id,omitempty"`
+	VariantID *int    `json:"variant_id,omitempty"`
+	OptionID  *int    `json:"option_id,omitempty"`
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	Unit      string  `json:"unit"`
+}
+
+// Replaces an ingredient with a substitute in every recipe; quantities are multiplied by the ratio
+type IngredientReplacement struct {
+	SubstituteID int     `json:"substitute_id"`
+	Ratio        float64 `json:"ratio"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// A signed change of an ingredient's stock: delivery adds, waste removes, correction goes either way
+type InventoryAdjustment struct {
+	Delta     float64 `json:"delta"`
+	Reason    string  `json:"reason"`
+	ExpiresAt *string `json:"expires_at,omitempty"` // expiry date of the lot a delivery creates
+}
+
+// A counted stock level of an ingredient replacing the recorded one; variance is counted minus expected
+type StockCount struct {
+	CountID          int     `json:"count_id"`
+	IngredientID     int     `json:"ingredient_id"`
+	ExpectedQuantity float64 `json:"expected_quantity"`
+	CountedQuantity  float64 `json:"counted_quantity"`
+	Variance         float64 `json:"variance"`
+	CreatedAt        string  `json:"created_at"`
+}
@@ -1,8 +1,17 @@
 package models
 
 type InventoryItem struct {
-	IngredientID int     `json:"ingredient_id"`
-	Name         string  `json:"name"`
-	Quantity     float64 `json:"quantity"`
-	Unit         string  `json:"unit"`
+	IngredientID int       `json:"ingredient_id"`
+	Name         string    `json:"name"`
+	Quantity     float64   `json:"quantity"`  // on hand
+	Reserved     float64   `json:"reserved"`  // held by open orders
+	Available    float64   `json:"available"` // on hand less reserved, what new orders can use
+	Unit         string    `json:"unit"`
+	Price        float64   `json:"price"`
+	Allergens    []string  `json:"allergens"`
+	Nutrition    Nutrition `json:"nutrition"`
+	ReorderPoint *float64  `json:"reorder_point"` // stock at or below which the ingredient is reordered
+	ParLevel     *float64  `json:"par_level"`     // stock a reorder brings the ingredient back up to
+	Consumption  string    `json:"consumption"`   // order lots are used in: fifo or fefo
+	ArchivedAt   *string   `json:"archived_at,omitempty"`
 }
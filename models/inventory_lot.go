@@ -0,0 +1,16 @@
+package models
+
+// A received quantity of an ingredient with its own expiry date and unit cost, consumed FIFO or FEFO
+type InventoryLot struct {
+	LotID        int     `json:"lot_id"`
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name,omitempty"`
+	Unit         string  `json:"unit,omitempty"`
+	Quantity     float64 `json:"quantity"`  // quantity received
+	Remaining    float64 `json:"remaining"` // quantity still in stock
+	UnitCost     float64 `json:"unit_cost"`
+	Value        float64 `json:"value"` // remaining quantity at the lot's unit cost
+	ReceivedAt   string  `json:"received_at"`
+	ExpiresAt    *string `json:"expires_at"`
+	ExpiredAt    *string `json:"expired_at,omitempty"` // when the rest of the lot was written off as expired
+}
@@ -0,0 +1,29 @@
+package models
+
+// One stock movement of an ingredient: a signed quantity change with its reason and, for order movements, the order
+type InventoryTransaction struct {
+	TransactionID  int      `json:"transaction_id"`
+	IngredientID   int      `json:"ingredient_id"`
+	QuantityChange float64  `json:"quantity_change"`
+	QuantityAfter  *float64 `json:"quantity_after,omitempty"`
+	Reason         string   `json:"reason"`
+	OrderID        *int     `json:"order_id,omitempty"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+// The movements of an ingredient in a period with the stock level reconstructed at its start and end
+type InventoryLedger struct {
+	IngredientID    int                    `json:"ingredient_id"`
+	From            *string                `json:"from,omitempty"`
+	To              *string                `json:"to,omitempty"`
+	OpeningQuantity float64                `json:"opening_quantity"`
+	ClosingQuantity float64                `json:"closing_quantity"`
+	Transactions    []InventoryTransaction `json:"transactions"`
+}
+
+// The stock level of an ingredient at a point in time
+type InventoryLevel struct {
+	IngredientID int     `json:"ingredient_id"`
+	At           *string `json:"at,omitempty"`
+	Quantity     float64 `json:"quantity"`
+}
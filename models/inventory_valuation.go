@@ -0,0 +1,17 @@
+package models
+
+type InventoryValuation struct {
+	AsOf   *string               `json:"as_of"`
+	Method string                `json:"method"` // average, fifo or last
+	Total  float64               `json:"total"`
+	Items  []IngredientValuation `json:"items"`
+}
+
+type IngredientValuation struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Quantity     float64 `json:"quantity"`
+	UnitCost     float64 `json:"unit_cost"`
+	Value        float64 `json:"value"`
+}
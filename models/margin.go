@@ -0,0 +1,23 @@
+package models
+
+type MenuMargin struct {
+	ProductID      int     `json:"product_id"`
+	Name           string  `json:"name"`
+	Price          float64 `json:"price"`
+	Cost           float64 `json:"cost"`
+	Margin         float64 `json:"margin"`
+	MarginPercent  float64 `json:"margin_percent"`
+	BelowThreshold bool    `json:"below_threshold"`
+}
+
+type MarginAlert struct {
+	AlertID       int     `json:"alert_id"`
+	ProductID     int     `json:"product_id"`
+	Name          string  `json:"name"`
+	OldPrice      float64 `json:"old_price"`
+	NewPrice      float64 `json:"new_price"`
+	Cost          float64 `json:"cost"`
+	MarginPercent float64 `json:"margin_percent"`
+	Threshold     float64 `json:"threshold"`
+	CreatedAt     string  `json:"created_at"`
+}
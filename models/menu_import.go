@@ -0,0 +1,42 @@
+package models
+
+// A menu item as exported and imported: the category and recipe ingredients are referenced by name
+type MenuExportItem struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Price       float64                `json:"price"`
+	Category    string                 `json:"category"`
+	PrepTime    *int                   `json:"prep_time"`
+	Station     string                 `json:"station"`
+	Ingredients []MenuExportIngredient `json:"ingredients"`
+}
+
+type MenuExportIngredient struct {
+	Ingredient string  `json:"ingredient"`
+	Quantity   float64 `json:"quantity"`
+	Unit       string  `json:"unit,omitempty"`
+}
+
+// Outcome of a menu import, or of its dry run when nothing was written
+type MenuImportResult struct {
+	DryRun            bool              `json:"dry_run"`
+	Created           []string          `json:"created"`
+	Updated           []MenuImportDiff  `json:"updated"`
+	Unchanged         []string          `json:"unchanged"`
+	CategoriesCreated []string          `json:"categories_created"`
+	Errors            []MenuImportError `json:"errors,omitempty"`
+}
+
+// The fields of an existing menu item an import changes
+type MenuImportDiff struct {
+	ProductID int      `json:"product_id"`
+	Name      string   `json:"name"`
+	Fields    []string `json:"fields"`
+}
+
+// A validation error of one imported row; rows count from 1, CSV rows by their line in the file
+type MenuImportError struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
@@ -1,15 +1,46 @@
 package models
 
 type MenuItem struct {
-	ID          int                  `json:"product_id"`
-	Name        string               `json:"name"`
-	Description string               `json:"description"`
-	Price       float64              `json:"price"`
-	Category    string               `json:"category"`
-	Allergens   []string             `json:"allergens"`
-	Ingredients []MenuItemIngredient `json:"ingredients"`
+	ID                int                  `json:"product_id"`
+	Name              string               `json:"name"`
+	Description       string               `json:"description"`
+	Price             float64              `json:"price"`
+	CategoryID        *int                 `json:"category_id"`
+	Category          string               `json:"category"`
+	Allergens         []string             `json:"allergens"`
+	ModifierAllergens []string             `json:"modifier_allergens"`
+	PrepTime          *int                 `json:"prep_time"` // seconds, the default when omitted
+	Station           string               `json:"station"`
+	ArchivedAt        *string              `json:"archived_at,omitempty"`
+	Ingredients       []MenuItemIngredient `json:"ingredients"`
+	Variants          []MenuItemVariant    `json:"variants"`
+	ModifierGroups    []int                `json:"modifier_group_ids"`
+	Availability      []AvailabilityWindow `json:"availability"`
+	BundleSlots       []BundleSlot         `json:"bundle_slots,omitempty"`
+	Cost              *float64             `json:"cost,omitempty"`
+	MarginPercent     *float64             `json:"margin_percent,omitempty"`
+	Nutrition         *Nutrition           `json:"nutrition,omitempty"`
+	ModifierNutrition []OptionNutrition    `json:"modifier_nutrition,omitempty"`
 }
 type MenuItemIngredient struct {
 	IngredientID int     `json:"ingredient_id"`
 	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"` // the ingredient's stock unit when omitted
+}
+
+type MenuItemVariant struct {
+	VariantID        int                  `json:"variant_id"`
+	Name             string               `json:"name"`
+	Price            float64              `json:"price"`
+	RecipeMultiplier float64              `json:"recipe_multiplier"`
+	Ingredients      []MenuItemIngredient `json:"ingredients,omitempty"`
+	Nutrition        *Nutrition           `json:"nutrition,omitempty"`
+}
+
+// Options for listing the menu
+type MenuFilter struct {
+	HideUnavailable  bool
+	ExcludeAllergens []string
+	At               *string // local time to check availability windows against, now when nil
+	IncludeArchived  bool
 }
@@ -0,0 +1,23 @@
+package models
+
+// A version of the menu. Drafts are edited apart from the live menu; publishing copies the items into the menu, and
+// a retired version can be published again to roll back. Items in a draft that are not on the menu yet have negative IDs
+type MenuVersion struct {
+	VersionID   int        `json:"version_id"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"` // draft, published or retired
+	BasedOn     *int       `json:"based_on,omitempty"`
+	PublishAt   *string    `json:"publish_at,omitempty"` // RFC3339 on input
+	PublishedAt *string    `json:"published_at,omitempty"`
+	CreatedAt   string     `json:"created_at"`
+	Items       []MenuItem `json:"items,omitempty"`
+}
+
+// What publishing a menu version would change on the live menu
+type MenuVersionPreview struct {
+	VersionID int              `json:"version_id"`
+	Created   []string         `json:"created"`
+	Updated   []MenuImportDiff `json:"updated"`
+	Archived  []string         `json:"archived"`
+	Restored  []string         `json:"restored"`
+}
@@ -0,0 +1,16 @@
+package models
+
+type ModifierGroup struct {
+	GroupID   int              `json:"group_id"`
+	Name      string           `json:"name"`
+	MinSelect int              `json:"min_select"`
+	MaxSelect int              `json:"max_select"`
+	Options   []ModifierOption `json:"options"`
+}
+
+type ModifierOption struct {
+	OptionID    int                  `json:"option_id"`
+	Name        string               `json:"name"`
+	PriceDelta  float64              `json:"price_delta"`
+	Ingredients []MenuItemIngredient `json:"ingredients"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// Nutrition values per unit of an ingredient, or per serving of a menu item: kcal, sugar and fat in grams, caffeine in mg
+type Nutrition struct {
+	Kcal     float64 `json:"kcal"`
+	Sugar    float64 `json:"sugar"`
+	Fat      float64 `json:"fat"`
+	Caffeine float64 `json:"caffeine"`
+}
+
+// What picking a modifier option adds to (or, for swaps, removes from) a serving
+type OptionNutrition struct {
+	OptionID  int       `json:"option_id"`
+	Name      string    `json:"name"`
+	Nutrition Nutrition `json:"nutrition"`
+}
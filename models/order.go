@@ -1,18 +1,35 @@
 package models
 
 type Order struct {
-	ID           int         `json:"order_id"`
-	CustomerName string      `json:"customer_name"`
-	Items        []OrderItem `json:"items"`
-	Status       string      `json:"status"`
-	CreatedAt    string      `json:"created_at"`
+	ID               int         `json:"order_id"`
+	CustomerName     string      `json:"customer_name"`
+	Items            []OrderItem `json:"items"`
+	Status           string      `json:"status"`
+	CreatedAt        string      `json:"created_at"`
+	EstimatedReadyAt *string     `json:"estimated_ready_at"`
+	ReservedUntil    *string     `json:"reserved_until,omitempty"` // when an open order's ingredient reservation runs out
+	AllergenWarnings []string    `json:"allergen_warnings,omitempty"`
+	MenuVersionID    *int        `json:"menu_version_id,omitempty"`
 }
 
 type OrderItem struct {
-	ProductID int `json:"menu_item_id"`
-	Quantity  int `json:"quantity"`
+	ProductID  int              `json:"menu_item_id"`
+	VariantID  *int             `json:"variant_id,omitempty"`
+	Quantity   int              `json:"quantity"`
+	Modifiers  []int            `json:"modifiers,omitempty"`
+	Components []OrderComponent `json:"components,omitempty"`
+	Allergens  []string         `json:"allergens,omitempty"`
+	Nutrition  *Nutrition       `json:"nutrition,omitempty"`
 }
 
 type OrderRequest struct {
 	Orders []Order `json:"orders"`
 }
+
+type OrderETA struct {
+	OrderID          int      `json:"order_id"`
+	Status           string   `json:"status"`
+	EstimatedReadyAt string   `json:"estimated_ready_at"`
+	EtaSeconds       int      `json:"eta_seconds"`
+	AllergenWarnings []string `json:"allergen_warnings"`
+}
@@ -0,0 +1,20 @@
+package models
+
+type PriceHistory struct {
+	HistoryID int     `json:"history_id"`
+	ProductID int     `json:"product_id"`
+	OldPrice  float64 `json:"old_price"`
+	NewPrice  float64 `json:"new_price"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type ScheduledPrice struct {
+	ScheduleID  int     `json:"schedule_id"`
+	ProductID   int     `json:"product_id"`
+	NewPrice    float64 `json:"new_price"`
+	EffectiveAt string  `json:"effective_at"`
+	AppliedAt   *string `json:"applied_at"`
+	LastError   *string `json:"last_error"`
+	Attempts    int     `json:"attempts"`  // failed attempts to apply the change
+	FailedAt    *string `json:"failed_at"` // set once the change has failed too often to be retried
+}
@@ -0,0 +1,60 @@
+package models
+
+// An order to a supplier, moving from draft to sent, partially_received and received
+type PurchaseOrder struct {
+	PurchaseOrderID int                 `json:"purchase_order_id"`
+	SupplierID      int                 `json:"supplier_id"`
+	SupplierName    string              `json:"supplier_name"`
+	Status          string              `json:"status"`
+	ExpectedAt      *string             `json:"expected_at"` // date the delivery is expected, from the lead time when sent without one
+	CreatedAt       string              `json:"created_at"`
+	SentAt          *string             `json:"sent_at"`
+	ReceivedAt      *string             `json:"received_at"`
+	Lines           []PurchaseOrderLine `json:"lines"`
+	Total           float64             `json:"total"`
+}
+
+// Quantities are in the ingredient's stock unit; the unit cost defaults to the supplier's price
+type PurchaseOrderLine struct {
+	IngredientID     int     `json:"ingredient_id"`
+	Name             string  `json:"name,omitempty"`
+	Unit             string  `json:"unit,omitempty"`
+	Quantity         float64 `json:"quantity"`
+	UnitCost         float64 `json:"unit_cost"`
+	ReceivedQuantity float64 `json:"received_quantity"`
+}
+
+// Goods received against a sent purchase order
+type PurchaseOrderReceipt struct {
+	Lines []PurchaseOrderReceiptLine `json:"lines"`
+}
+
+type PurchaseOrderReceiptLine struct {
+	IngredientID int     `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+	ExpiresAt    *string `json:"expires_at,omitempty"` // expiry date of the lot the received goods form
+}
+
+// Ingredients to reorder from one supplier; supplier_id is null for ingredients no supplier delivers
+type ReorderSuggestion struct {
+	SupplierID   *int                    `json:"supplier_id"`
+	SupplierName string                  `json:"supplier_name"`
+	LeadTimeDays *int                    `json:"lead_time_days"`
+	Lines        []ReorderSuggestionLine `json:"lines"`
+}
+
+// An ingredient whose stock and open purchase orders fall short of its par level
+type ReorderSuggestionLine struct {
+	IngredientID      int      `json:"ingredient_id"`
+	Name              string   `json:"name"`
+	Unit              string   `json:"unit"`
+	Quantity          float64  `json:"quantity"`
+	OnOrder           float64  `json:"on_order"`
+	ReorderPoint      *float64 `json:"reorder_point"`
+	ParLevel          float64  `json:"par_level"`
+	SuggestedQuantity float64  `json:"suggested_quantity"`
+	UnitCost          *float64 `json:"unit_cost"`
+	SupplierID        *int     `json:"-"`
+	SupplierName      string   `json:"-"`
+	LeadTimeDays      *int     `json:"-"`
+}
@@ -34,6 +34,6 @@ type LeftOvers struct {
 }
 type Data struct {
 	Name     string  `json:"name"`
-	Quantity int     `json:"quantity"`
+	Quantity float64 `json:"quantity"`
 	Price    float64 `json:"price"`
 }
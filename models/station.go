@@ -0,0 +1,15 @@
+package models
+
+type Station struct {
+	Station     string `json:"station"`
+	ActiveStaff int    `json:"active_staff"`
+}
+
+type PrepTimeReport struct {
+	OrderID          int     `json:"order_id"`
+	CustomerName     string  `json:"customer_name"`
+	EstimatedSeconds int     `json:"estimated_seconds"`
+	ActualSeconds    int     `json:"actual_seconds"`
+	Difference       int     `json:"difference_seconds"`
+	Accuracy         float64 `json:"accuracy_percent"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// An ingredient whose stock is at or below its reorder point; reorder_quantity brings it back to its par level
+type LowStockItem struct {
+	IngredientID    int      `json:"ingredient_id"`
+	Name            string   `json:"name"`
+	Quantity        float64  `json:"quantity"`
+	Unit            string   `json:"unit"`
+	ReorderPoint    float64  `json:"reorder_point"`
+	ParLevel        *float64 `json:"par_level"`
+	ReorderQuantity *float64 `json:"reorder_quantity,omitempty"`
+	AlertedAt       *string  `json:"alerted_at"` // when the stock last crossed the reorder point
+}
+
+// Raised when a stock change takes an ingredient from above its reorder point to at or below it
+type StockAlert struct {
+	AlertID      int      `json:"alert_id"`
+	IngredientID int      `json:"ingredient_id"`
+	Name         string   `json:"name"`
+	Quantity     float64  `json:"quantity"`
+	Unit         string   `json:"unit"`
+	ReorderPoint float64  `json:"reorder_point"`
+	ParLevel     *float64 `json:"par_level"`
+	Reason       *string  `json:"reason"`
+	OrderID      *int     `json:"order_id"`
+	CreatedAt    string   `json:"created_at"`
+}
@@ -0,0 +1,68 @@
+package models
+
+// A physical count of the stock; counts are collected while it is open and replace the stock when it is finalized
+type Stocktake struct {
+	StocktakeID   int              `json:"stocktake_id"`
+	Status        string           `json:"status"`
+	Note          string           `json:"note"`
+	StartedAt     string           `json:"started_at"`
+	FinalizedAt   *string          `json:"finalized_at"`
+	CountedItems  int              `json:"counted_items"`
+	VarianceValue *float64         `json:"variance_value"` // net money value of the variance once finalized
+	Entries       []StocktakeEntry `json:"entries,omitempty"`
+}
+
+// One counter's count of an ingredient in a stocktake
+type StocktakeEntry struct {
+	IngredientID    int     `json:"ingredient_id"`
+	Name            string  `json:"name,omitempty"`
+	Unit            string  `json:"unit,omitempty"`
+	Counter         string  `json:"counter"`
+	CountedQuantity float64 `json:"counted_quantity"`
+	CountedAt       string  `json:"counted_at"`
+}
+
+// Counted quantities submitted by one counter; a counter's repeated count of an ingredient replaces the earlier one
+type StocktakeSubmission struct {
+	Counter string                    `json:"counter"`
+	Counts  []StocktakeSubmissionLine `json:"counts"`
+}
+
+type StocktakeSubmissionLine struct {
+	IngredientID    int     `json:"ingredient_id"`
+	CountedQuantity float64 `json:"counted_quantity"`
+}
+
+// Theoretical against counted stock of a stocktake in units and money. While the stocktake is open the theoretical
+// quantity is the current stock; once finalized it is the stock the count replaced
+type StocktakeVariance struct {
+	StocktakeID    int                     `json:"stocktake_id"`
+	Status         string                  `json:"status"`
+	Lines          []StocktakeVarianceLine `json:"lines"`
+	ShrinkageValue float64                 `json:"shrinkage_value"` // value of the stock found missing, as a positive amount
+	SurplusValue   float64                 `json:"surplus_value"`
+	VarianceValue  float64                 `json:"variance_value"`
+}
+
+// The counted quantity sums the counts of all counters
+type StocktakeVarianceLine struct {
+	IngredientID        int     `json:"ingredient_id"`
+	Name                string  `json:"name"`
+	Unit                string  `json:"unit"`
+	TheoreticalQuantity float64 `json:"theoretical_quantity"`
+	CountedQuantity     float64 `json:"counted_quantity"`
+	Variance            float64 `json:"variance"`
+	UnitCost            float64 `json:"unit_cost"`
+	VarianceValue       float64 `json:"variance_value"`
+	Counters            int     `json:"counters"`
+}
+
+// Variance found by one finalized stocktake, for following shrinkage over time
+type ShrinkageReport struct {
+	StocktakeID    int     `json:"stocktake_id"`
+	FinalizedAt    string  `json:"finalized_at"`
+	CountedItems   int     `json:"counted_items"`
+	ShrinkageValue float64 `json:"shrinkage_value"`
+	SurplusValue   float64 `json:"surplus_value"`
+	VarianceValue  float64 `json:"variance_value"`
+}
@@ -0,0 +1,19 @@
+package models
+
+type Supplier struct {
+	SupplierID   int                  `json:"supplier_id"`
+	Name         string               `json:"name"`
+	ContactName  string               `json:"contact_name"`
+	Email        string               `json:"email"`
+	Phone        string               `json:"phone"`
+	LeadTimeDays int                  `json:"lead_time_days"`
+	Ingredients  []SupplierIngredient `json:"ingredients"`
+}
+
+// An ingredient a supplier delivers, priced per unit of the ingredient's stock unit
+type SupplierIngredient struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name,omitempty"`
+	Unit         string  `json:"unit,omitempty"`
+	UnitCost     float64 `json:"unit_cost"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// A unit of measure; units of the same dimension convert through their factor to the dimension's base unit
+type Unit struct {
+	Unit      string  `json:"unit"`
+	Dimension string  `json:"dimension"`
+	Factor    float64 `json:"factor"`
+}
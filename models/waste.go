@@ -0,0 +1,51 @@
+package models
+
+// Stock thrown away: an ingredient, or servings of a menu item whose whole recipe is deducted
+type WasteEntry struct {
+	WasteID      int         `json:"waste_id"`
+	IngredientID *int        `json:"ingredient_id,omitempty"`
+	MenuItemID   *int        `json:"menu_item_id,omitempty"`
+	VariantID    *int        `json:"variant_id,omitempty"`
+	Name         string      `json:"name"`
+	Quantity     float64     `json:"quantity"` // in the ingredient's stock unit, or servings of a menu item
+	Reason       string      `json:"reason"`   // spill, remake, spoilage, expired, breakage or other
+	Note         string      `json:"note"`
+	Lines        []WasteLine `json:"lines"`
+	Cost         float64     `json:"cost"`
+	CreatedAt    string      `json:"created_at"`
+}
+
+// An ingredient deducted for a waste entry, valued at its cost when it was thrown away.
+// The ingredient ID is left out once the ingredient is deleted
+type WasteLine struct {
+	IngredientID int     `json:"ingredient_id,omitempty"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Quantity     float64 `json:"quantity"`
+	UnitCost     float64 `json:"unit_cost"`
+	Cost         float64 `json:"cost"`
+}
+
+// Cost of waste between two times by reason and by day, week or month
+type WasteReport struct {
+	From     *string            `json:"from"`
+	To       *string            `json:"to"`
+	Period   string             `json:"period"`
+	Total    float64            `json:"total"`
+	ByReason []WasteReasonTotal `json:"by_reason"`
+	ByPeriod []WastePeriodTotal `json:"by_period"`
+}
+
+type WasteReasonTotal struct {
+	Reason  string  `json:"reason"`
+	Entries int     `json:"entries"`
+	Cost    float64 `json:"cost"`
+}
+
+// Cost of one reason's waste in a period starting at period_start
+type WastePeriodTotal struct {
+	PeriodStart string  `json:"period_start"`
+	Reason      string  `json:"reason"`
+	Entries     int     `json:"entries"`
+	Cost        float64 `json:"cost"`
+}